@@ -0,0 +1,146 @@
+package cache
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/badjware/gitforgefs/fstree"
+)
+
+type FetchContentFunc func() (map[string]fstree.GroupSource, map[string]fstree.RepositorySource, error)
+
+// ContentCache holds the content of a group fetched from a forge.
+// Once the content is older than the configured ttl, it is refreshed in the background while the stale content
+// keeps being served, so that only the very first listing of a group blocks on the forge API.
+type ContentCache struct {
+	mux sync.Mutex
+
+	logger *slog.Logger
+
+	ttl        time.Duration
+	fetchedAt  time.Time
+	refreshing bool
+	// content restored from the metadata cache is always refreshed on first use
+	restored bool
+	// invalidated content is fetched again on next use, and only served if it cannot be fetched
+	invalidated bool
+
+	groups       map[string]fstree.GroupSource
+	repositories map[string]fstree.RepositorySource
+}
+
+// NewContentCache creates an empty content cache. A ttl of 0 means the content never expires.
+func NewContentCache(logger *slog.Logger, ttl time.Duration) *ContentCache {
+	return &ContentCache{
+		logger: logger,
+		ttl:    ttl,
+	}
+}
+
+func (c *ContentCache) TTL() time.Duration {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	return c.ttl
+}
+
+func (c *ContentCache) SetTTL(ttl time.Duration) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.ttl = ttl
+}
+
+// Get returns the cached content, calling fetch to populate the cache if it is empty or invalidated.
+// If the cached content is expired, it is returned as-is and a refresh is started in the background.
+// If fetch fails, the content last fetched, if any, is returned along with the error.
+func (c *ContentCache) Get(fetch FetchContentFunc) (map[string]fstree.GroupSource, map[string]fstree.RepositorySource, error) {
+	// Only a single routine can fetch the content at the time.
+	// We lock for the whole duration of the initial fetch to avoid fetching the same data from the API
+	// multiple times if concurrent calls where to occur.
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if c.groups == nil || c.repositories == nil || c.invalidated {
+		groups, repositories, err := fetch()
+		if err != nil {
+			return c.groups, c.repositories, err
+		}
+		c.set(groups, repositories)
+	} else if !c.refreshing && (c.restored || c.ttl > 0 && time.Since(c.fetchedAt) > c.ttl) {
+		c.restored = false
+		c.refreshing = true
+		go c.refresh(fetch)
+	}
+	return c.groups, c.repositories, nil
+}
+
+// Peek returns the cached content without ever fetching it. The returned maps are nil if the cache is empty.
+func (c *ContentCache) Peek() (map[string]fstree.GroupSource, map[string]fstree.RepositorySource) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	return c.groups, c.repositories
+}
+
+// Restore populates the cache with content previously fetched at fetchedAt.
+// The restored content is served as-is and is refreshed in the background the first time it is used.
+func (c *ContentCache) Restore(groups map[string]fstree.GroupSource, repositories map[string]fstree.RepositorySource, fetchedAt time.Time) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.groups = groups
+	c.repositories = repositories
+	c.fetchedAt = fetchedAt
+	c.restored = true
+}
+
+// Set replaces the cached content. It is used for groups whose content is derived from the content of another group.
+func (c *ContentCache) Set(groups map[string]fstree.GroupSource, repositories map[string]fstree.RepositorySource) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.set(groups, repositories)
+	c.restored = false
+}
+
+// Invalidate forces the next call to Get to fetch the content again. The cached content is kept, to be served if it
+// cannot be fetched.
+func (c *ContentCache) Invalidate() {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.invalidated = true
+	c.restored = false
+}
+
+func (c *ContentCache) refresh(fetch FetchContentFunc) {
+	groups, repositories, err := fetch()
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.refreshing = false
+	if err != nil {
+		// keep serving the stale content, we will try again once the ttl expires again
+		c.logger.Warn("Failed to refresh expired content, serving stale content", "error", err.Error())
+		c.fetchedAt = time.Now()
+		return
+	}
+	c.set(groups, repositories)
+}
+
+func (c *ContentCache) set(groups map[string]fstree.GroupSource, repositories map[string]fstree.RepositorySource) {
+	c.groups = groups
+	c.repositories = repositories
+	c.fetchedAt = time.Now()
+	c.invalidated = false
+}
+
+func (c *ContentCache) snapshot() (map[string]fstree.GroupSource, map[string]fstree.RepositorySource, time.Time) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	return c.groups, c.repositories, c.fetchedAt
+}
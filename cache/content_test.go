@@ -0,0 +1,108 @@
+package cache_test
+
+import (
+	"errors"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/badjware/gitforgefs/cache"
+	"github.com/badjware/gitforgefs/fstree"
+)
+
+func countingFetch(count *atomic.Int32, err error) cache.FetchContentFunc {
+	return func() (map[string]fstree.GroupSource, map[string]fstree.RepositorySource, error) {
+		n := count.Add(1)
+		if err != nil && n > 1 {
+			return nil, nil, err
+		}
+		return map[string]fstree.GroupSource{}, make(map[string]fstree.RepositorySource, n), nil
+	}
+}
+
+func TestContentCache(t *testing.T) {
+	tests := map[string]struct {
+		ttl        time.Duration
+		fetchErr   error
+		invalidate bool
+		expected   int32
+	}{
+		"NoExpiry": {
+			ttl:      0,
+			expected: 1,
+		},
+		"NotExpired": {
+			ttl:      time.Hour,
+			expected: 1,
+		},
+		"Expired": {
+			ttl:      time.Nanosecond,
+			expected: 2,
+		},
+		"ExpiredRefreshFails": {
+			ttl:      time.Nanosecond,
+			fetchErr: errors.New("unreachable"),
+			expected: 2,
+		},
+		"Invalidated": {
+			ttl:        0,
+			invalidate: true,
+			expected:   2,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var count atomic.Int32
+			c := cache.NewContentCache(slog.Default(), test.ttl)
+			fetch := countingFetch(&count, test.fetchErr)
+
+			if _, _, err := c.Get(fetch); err != nil {
+				t.Fatalf("Get() returned error: %v", err)
+			}
+			if test.invalidate {
+				c.Invalidate()
+			}
+			time.Sleep(time.Millisecond)
+			// Expired content must be served without waiting for the refresh
+			if groups, repositories, err := c.Get(fetch); groups == nil || repositories == nil || err != nil {
+				t.Fatalf("Get() returned %v, %v, %v; expected cached content", groups, repositories, err)
+			}
+
+			deadline := time.Now().Add(time.Second)
+			for count.Load() < test.expected && time.Now().Before(deadline) {
+				time.Sleep(time.Millisecond)
+			}
+			if got := count.Load(); got != test.expected {
+				t.Fatalf("content was fetched %v times; expected %v", got, test.expected)
+			}
+		})
+	}
+}
+
+func TestContentCacheInvalidatedUnreachable(t *testing.T) {
+	var count atomic.Int32
+	c := cache.NewContentCache(slog.Default(), 0)
+	fetch := countingFetch(&count, errors.New("unreachable"))
+
+	if _, _, err := c.Get(fetch); err != nil {
+		t.Fatalf("Get() returned error: %v", err)
+	}
+	c.Invalidate()
+	// the content last fetched is returned along with the error
+	groups, repositories, err := c.Get(fetch)
+	if err == nil {
+		t.Fatalf("Get() returned no error; expected the fetch error")
+	}
+	if groups == nil || repositories == nil {
+		t.Fatalf("Get() returned %v, %v; expected the content last fetched", groups, repositories)
+	}
+	// the content is fetched again on the next call
+	c.Get(fetch)
+	if got := count.Load(); got != 3 {
+		t.Fatalf("content was fetched %v times; expected 3", got)
+	}
+}
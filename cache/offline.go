@@ -0,0 +1,132 @@
+package cache
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/badjware/gitforgefs/fstree"
+)
+
+// offlineForge serves the content of a forge saved in a store, without ever calling the api of the forge
+type offlineForge struct {
+	rootContent map[string]fstree.GroupSource
+	groups      map[uint64]*offlineGroup
+}
+
+type offlineGroup struct {
+	id           uint64
+	groups       map[string]fstree.GroupSource
+	repositories map[string]fstree.RepositorySource
+}
+
+type offlineRepository struct {
+	id            uint64
+	fullPath      string
+	cloneURL      string
+	defaultBranch string
+	metadata      *fstree.RepositoryMetadata
+}
+
+// NewOfflineForge creates a forge serving the content last saved in the store
+func NewOfflineForge(logger *slog.Logger, store *Store) (*offlineForge, error) {
+	if store == nil {
+		return nil, fmt.Errorf("the offline mode requires fs.persist_cache")
+	}
+	snapshot, err := store.read()
+	if err != nil {
+		return nil, err
+	}
+	if snapshot == nil || snapshot.Version != snapshotVersion {
+		return nil, fmt.Errorf("no usable metadata cache in %v, the filesystem must be mounted online at least once", store.path)
+	}
+
+	forge := &offlineForge{
+		rootContent: map[string]fstree.GroupSource{},
+		groups:      make(map[uint64]*offlineGroup, len(snapshot.Groups)),
+	}
+	// create the groups first so they can be referenced as child groups
+	for gid, groupSnapshot := range snapshot.Groups {
+		forge.groups[gid] = &offlineGroup{
+			id:           gid,
+			groups:       make(map[string]fstree.GroupSource, len(groupSnapshot.Groups)),
+			repositories: make(map[string]fstree.RepositorySource, len(groupSnapshot.Repositories)),
+		}
+	}
+	childGids := map[uint64]bool{}
+	for gid, groupSnapshot := range snapshot.Groups {
+		group := forge.groups[gid]
+		for name, childGid := range groupSnapshot.Groups {
+			if childGroup, found := forge.groups[childGid]; found {
+				group.groups[name] = childGroup
+				childGids[childGid] = true
+			}
+		}
+		for name, repositorySnapshot := range groupSnapshot.Repositories {
+			group.repositories[name] = &offlineRepository{
+				id:            repositorySnapshot.ID,
+				fullPath:      repositorySnapshot.FullPath,
+				cloneURL:      repositorySnapshot.CloneURL,
+				defaultBranch: repositorySnapshot.DefaultBranch,
+				metadata:      repositorySnapshot.Metadata,
+			}
+		}
+	}
+
+	if snapshot.Root != nil {
+		for name, gid := range snapshot.Root {
+			if group, found := forge.groups[gid]; found {
+				forge.rootContent[name] = group
+			}
+		}
+	} else {
+		// the root groups were not saved by older versions, use the groups that are not the child of another group
+		for gid, groupSnapshot := range snapshot.Groups {
+			if !childGids[gid] {
+				forge.rootContent[groupSnapshot.Name] = forge.groups[gid]
+			}
+		}
+	}
+
+	logger.Info("Serving the metadata cache offline", "path", store.path, "groups", len(forge.groups))
+	return forge, nil
+}
+
+func (f *offlineForge) FetchRootGroupContent() (map[string]fstree.GroupSource, error) {
+	return f.rootContent, nil
+}
+
+func (f *offlineForge) FetchGroupContent(gid uint64) (map[string]fstree.GroupSource, map[string]fstree.RepositorySource, error) {
+	group, found := f.groups[gid]
+	if !found {
+		return nil, nil, fmt.Errorf("invalid gid: %v", gid)
+	}
+	return group.groups, group.repositories, nil
+}
+
+func (g *offlineGroup) GetGroupID() uint64 {
+	return g.id
+}
+
+func (g *offlineGroup) InvalidateContentCache() {
+	// nothing to refresh while offline
+}
+
+func (r *offlineRepository) GetRepositoryID() uint64 {
+	return r.id
+}
+
+func (r *offlineRepository) GetRepositoryPath() string {
+	return r.fullPath
+}
+
+func (r *offlineRepository) GetCloneURL() string {
+	return r.cloneURL
+}
+
+func (r *offlineRepository) GetDefaultBranch() string {
+	return r.defaultBranch
+}
+
+func (r *offlineRepository) GetMetadata() *fstree.RepositoryMetadata {
+	return r.metadata
+}
@@ -0,0 +1,53 @@
+package cache_test
+
+import (
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"github.com/badjware/gitforgefs/cache"
+	"github.com/badjware/gitforgefs/fstree"
+)
+
+func TestOfflineForge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".cache", "test.json")
+
+	if _, err := cache.NewOfflineForge(slog.Default(), cache.NewStore(slog.Default(), path)); err == nil {
+		t.Fatalf("NewOfflineForge() without a metadata cache returned no error")
+	}
+
+	content := cache.NewContentCache(slog.Default(), 0)
+	content.Get(func() (map[string]fstree.GroupSource, map[string]fstree.RepositorySource, error) {
+		return map[string]fstree.GroupSource{"subgroup": &testGroup{id: 2}},
+			map[string]fstree.RepositorySource{"repo": &testRepository{id: 3}},
+			nil
+	})
+	store := cache.NewStore(slog.Default(), path)
+	store.Load("https://example.com")
+	store.Track(1, "group", "group", content)
+	store.Track(2, "subgroup", "group", cache.NewContentCache(slog.Default(), 0))
+	store.SetRoot(map[string]fstree.GroupSource{"renamed-group": &testGroup{id: 1}})
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close() returned error: %v", err)
+	}
+
+	forge, err := cache.NewOfflineForge(slog.Default(), cache.NewStore(slog.Default(), path))
+	if err != nil {
+		t.Fatalf("NewOfflineForge() returned error: %v", err)
+	}
+	rootContent, _ := forge.FetchRootGroupContent()
+	if len(rootContent) != 1 || rootContent["renamed-group"] == nil || rootContent["renamed-group"].GetGroupID() != 1 {
+		t.Fatalf("FetchRootGroupContent() returned %v; expected renamed-group with id 1", rootContent)
+	}
+	groups, repositories, err := forge.FetchGroupContent(1)
+	if err != nil || groups["subgroup"] == nil || groups["subgroup"].GetGroupID() != 2 {
+		t.Fatalf("FetchGroupContent(1) returned groups %v; expected subgroup with id 2; error: %v", groups, err)
+	}
+	repository, found := repositories["repo"]
+	if !found || repository.GetRepositoryID() != 3 || repository.GetCloneURL() != "https://example.com/test.git" || repository.GetDefaultBranch() != "main" {
+		t.Fatalf("FetchGroupContent(1) returned repositories %v; expected repo with id 3", repositories)
+	}
+	if metadata := repository.(fstree.RepositoryMetadataSource).GetMetadata(); metadata == nil || metadata.Description != "test" {
+		t.Fatalf("GetMetadata() of repo returned %v; expected the saved metadata", metadata)
+	}
+}
@@ -0,0 +1,314 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/badjware/gitforgefs/fstree"
+)
+
+const (
+	snapshotVersion = 1
+
+	flushInterval = 30 * time.Second
+)
+
+type Snapshot struct {
+	Version  int               `json:"version"`
+	Source   string            `json:"source"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// Root holds the id of the root groups, by name
+	Root   map[string]uint64         `json:"root,omitempty"`
+	Groups map[uint64]*GroupSnapshot `json:"groups"`
+}
+
+type GroupSnapshot struct {
+	ID   uint64 `json:"id"`
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+
+	// FetchedAt is zero if the content of the group was never fetched
+	FetchedAt    time.Time                     `json:"fetched_at,omitempty"`
+	Groups       map[string]uint64             `json:"groups,omitempty"`
+	Repositories map[string]RepositorySnapshot `json:"repositories,omitempty"`
+}
+
+type RepositorySnapshot struct {
+	ID            uint64                     `json:"id"`
+	FullPath      string                     `json:"full_path,omitempty"`
+	CloneURL      string                     `json:"clone_url"`
+	DefaultBranch string                     `json:"default_branch"`
+	Metadata      *fstree.RepositoryMetadata `json:"metadata,omitempty"`
+}
+
+type trackedGroup struct {
+	name    string
+	kind    string
+	content *ContentCache
+}
+
+// Store persists the content of the groups of a forge on disk, so that the filesystem can be served from the
+// last known content on startup while it is being refreshed from the forge.
+// A nil Store is valid and does nothing.
+type Store struct {
+	logger *slog.Logger
+
+	path   string
+	source string
+
+	mux       sync.Mutex
+	metadata  map[string]string
+	root      map[string]uint64
+	tracked   map[uint64]trackedGroup
+	flushedAt time.Time
+
+	done chan struct{}
+}
+
+// NewStore creates a store saving its content to path.
+func NewStore(logger *slog.Logger, path string) *Store {
+	return &Store{
+		logger: logger,
+
+		path: path,
+
+		metadata: map[string]string{},
+		tracked:  map[uint64]trackedGroup{},
+
+		done: make(chan struct{}),
+	}
+}
+
+// Load reads the snapshot saved on disk. source identifies the forge the content comes from, a saved snapshot is
+// discarded if its source doesn't match. It returns nil if there is no usable snapshot.
+func (s *Store) Load(source string) *Snapshot {
+	if s == nil {
+		return nil
+	}
+
+	s.mux.Lock()
+	s.source = source
+	s.mux.Unlock()
+
+	snapshot, err := s.read()
+	if err != nil {
+		s.logger.Warn(err.Error())
+		return nil
+	}
+	if snapshot == nil {
+		return nil
+	}
+	if snapshot.Version != snapshotVersion || snapshot.Source != source {
+		s.logger.Info("Metadata cache is outdated, ignoring", "path", s.path)
+		return nil
+	}
+
+	s.mux.Lock()
+	for key, value := range snapshot.Metadata {
+		s.metadata[key] = value
+	}
+	s.root = snapshot.Root
+	s.mux.Unlock()
+
+	s.logger.Info("Loaded metadata cache", "path", s.path, "groups", len(snapshot.Groups))
+	return snapshot
+}
+
+// read reads the snapshot saved on disk, whatever its source. It returns nil if there is no snapshot.
+func (s *Store) read() (*Snapshot, error) {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to open metadata cache %v: %v", s.path, err)
+	}
+	defer f.Close()
+
+	snapshot := &Snapshot{}
+	if err := json.NewDecoder(f).Decode(snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse metadata cache %v, ignoring: %v", s.path, err)
+	}
+	return snapshot, nil
+}
+
+// Track registers a group whose content should be saved in the store.
+func (s *Store) Track(gid uint64, name string, kind string, content *ContentCache) {
+	if s == nil {
+		return
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.tracked[gid] = trackedGroup{
+		name:    name,
+		kind:    kind,
+		content: content,
+	}
+}
+
+func (s *Store) GetMetadata(key string) (string, bool) {
+	if s == nil {
+		return "", false
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	value, found := s.metadata[key]
+	return value, found
+}
+
+func (s *Store) SetMetadata(key string, value string) {
+	if s == nil {
+		return
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.metadata[key] = value
+	// force the next flush
+	s.flushedAt = time.Time{}
+}
+
+// SetRoot records the root groups of the forge, so they can be served offline
+func (s *Store) SetRoot(rootContent map[string]fstree.GroupSource) {
+	if s == nil {
+		return
+	}
+
+	root := make(map[string]uint64, len(rootContent))
+	for name, group := range rootContent {
+		root[name] = group.GetGroupID()
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if !maps.Equal(s.root, root) {
+		s.root = root
+		// force the next flush
+		s.flushedAt = time.Time{}
+	}
+}
+
+// Start periodically saves the content of the store on disk until Close is called.
+func (s *Store) Start() {
+	if s == nil {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(flushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.Flush(); err != nil {
+					s.logger.Warn(err.Error())
+				}
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the periodic saves and saves the content of the store one last time.
+func (s *Store) Close() error {
+	if s == nil {
+		return nil
+	}
+
+	close(s.done)
+	return s.Flush()
+}
+
+// Flush saves the content of the store on disk, if it changed since the last flush.
+func (s *Store) Flush() error {
+	if s == nil {
+		return nil
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	snapshot := &Snapshot{
+		Version:  snapshotVersion,
+		Source:   s.source,
+		Metadata: s.metadata,
+		Root:     s.root,
+		Groups:   make(map[uint64]*GroupSnapshot, len(s.tracked)),
+	}
+	dirty := s.flushedAt.IsZero()
+	for gid, tracked := range s.tracked {
+		groups, repositories, fetchedAt := tracked.content.snapshot()
+		if fetchedAt.After(s.flushedAt) {
+			dirty = true
+		}
+		snapshot.Groups[gid] = newGroupSnapshot(gid, tracked.name, tracked.kind, groups, repositories, fetchedAt)
+	}
+	if !dirty {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create metadata cache directory: %v", err)
+	}
+	// write to a temporary file first so a crash never leaves a truncated cache behind
+	tmpPath := s.path + ".tmp"
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to serialize metadata cache: %v", err)
+	}
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write metadata cache: %v", err)
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("failed to write metadata cache: %v", err)
+	}
+	s.flushedAt = time.Now()
+	s.logger.Debug("Saved metadata cache", "path", s.path, "groups", len(snapshot.Groups))
+
+	return nil
+}
+
+func newGroupSnapshot(gid uint64, name string, kind string, groups map[string]fstree.GroupSource, repositories map[string]fstree.RepositorySource, fetchedAt time.Time) *GroupSnapshot {
+	groupSnapshot := &GroupSnapshot{
+		ID:   gid,
+		Name: name,
+		Kind: kind,
+	}
+	if groups == nil || repositories == nil {
+		return groupSnapshot
+	}
+
+	groupSnapshot.FetchedAt = fetchedAt
+	groupSnapshot.Groups = make(map[string]uint64, len(groups))
+	for groupName, group := range groups {
+		groupSnapshot.Groups[groupName] = group.GetGroupID()
+	}
+	groupSnapshot.Repositories = make(map[string]RepositorySnapshot, len(repositories))
+	for repositoryName, repository := range repositories {
+		repositorySnapshot := RepositorySnapshot{
+			ID:            repository.GetRepositoryID(),
+			CloneURL:      repository.GetCloneURL(),
+			DefaultBranch: repository.GetDefaultBranch(),
+		}
+		if metadataSource, ok := repository.(fstree.RepositoryMetadataSource); ok {
+			repositorySnapshot.Metadata = metadataSource.GetMetadata()
+		}
+		if pathSource, ok := repository.(fstree.RepositoryPathSource); ok {
+			repositorySnapshot.FullPath = pathSource.GetRepositoryPath()
+		}
+		groupSnapshot.Repositories[repositoryName] = repositorySnapshot
+	}
+	return groupSnapshot
+}
@@ -0,0 +1,84 @@
+package cache_test
+
+import (
+	"log/slog"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/badjware/gitforgefs/cache"
+	"github.com/badjware/gitforgefs/fstree"
+)
+
+type testGroup struct {
+	id uint64
+}
+
+func (g *testGroup) GetGroupID() uint64      { return g.id }
+func (g *testGroup) InvalidateContentCache() {}
+
+type testRepository struct {
+	id uint64
+}
+
+func (r *testRepository) GetRepositoryID() uint64  { return r.id }
+func (r *testRepository) GetCloneURL() string      { return "https://example.com/test.git" }
+func (r *testRepository) GetDefaultBranch() string { return "main" }
+func (r *testRepository) GetMetadata() *fstree.RepositoryMetadata {
+	return &fstree.RepositoryMetadata{Description: "test", DefaultBranch: "main", Stars: 1}
+}
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".cache", "test.json")
+
+	content := cache.NewContentCache(slog.Default(), 0)
+	content.Get(func() (map[string]fstree.GroupSource, map[string]fstree.RepositorySource, error) {
+		return map[string]fstree.GroupSource{"subgroup": &testGroup{id: 2}},
+			map[string]fstree.RepositorySource{"repo": &testRepository{id: 3}},
+			nil
+	})
+
+	store := cache.NewStore(slog.Default(), path)
+	if snapshot := store.Load("https://example.com"); snapshot != nil {
+		t.Fatalf("Load() returned %v; expected nil", snapshot)
+	}
+	store.Track(1, "group", "group", content)
+	store.Track(2, "subgroup", "group", cache.NewContentCache(slog.Default(), 0))
+	store.SetMetadata("key", "value")
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close() returned error: %v", err)
+	}
+
+	if snapshot := cache.NewStore(slog.Default(), path).Load("https://other.example.com"); snapshot != nil {
+		t.Fatalf("Load() with a different source returned %v; expected nil", snapshot)
+	}
+
+	restoredStore := cache.NewStore(slog.Default(), path)
+	snapshot := restoredStore.Load("https://example.com")
+	if snapshot == nil {
+		t.Fatalf("Load() returned nil; expected a snapshot")
+	}
+	if value, _ := restoredStore.GetMetadata("key"); value != "value" {
+		t.Fatalf("GetMetadata(key) returned %v; expected value", value)
+	}
+	expected := &cache.GroupSnapshot{
+		ID:        1,
+		Name:      "group",
+		Kind:      "group",
+		FetchedAt: snapshot.Groups[1].FetchedAt,
+		Groups:    map[string]uint64{"subgroup": 2},
+		Repositories: map[string]cache.RepositorySnapshot{"repo": {
+			ID:            3,
+			CloneURL:      "https://example.com/test.git",
+			DefaultBranch: "main",
+			Metadata:      &fstree.RepositoryMetadata{Description: "test", DefaultBranch: "main", Stars: 1},
+		}},
+	}
+	if got := snapshot.Groups[1]; !reflect.DeepEqual(got, expected) || time.Since(got.FetchedAt) > time.Minute {
+		t.Fatalf("Load() restored %v; expected %v", got, expected)
+	}
+	if got := snapshot.Groups[2]; got == nil || !got.FetchedAt.IsZero() {
+		t.Fatalf("Load() restored %v; expected a group without content", got)
+	}
+}
@@ -0,0 +1,191 @@
+package cache
+
+import (
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"sync"
+
+	"github.com/badjware/gitforgefs/fstree"
+)
+
+const (
+	// SubdirectoryKind is the kind of the subdirectories holding the subdirectories of a grouping
+	SubdirectoryKind = "subdirectory"
+
+	// ids of subdirectories are in a range that is not used by the ids of the groups of the forges
+	subdirectoryIDFlag = uint64(1) << 47
+)
+
+// Subdirectory is a virtual directory grouping some of the repositories of a group, such as by team or by topic.
+// Its content is derived from the content of the group.
+type Subdirectory struct {
+	ID   uint64
+	Name string
+	Kind string
+
+	// Parent is the group the content of the subdirectory is derived from
+	Parent fstree.GroupSource
+
+	// hold subdirectory content
+	content *ContentCache
+}
+
+func (s *Subdirectory) GetGroupID() uint64 {
+	return s.ID
+}
+
+func (s *Subdirectory) InvalidateContentCache() {
+	// the content of the subdirectory is derived from the content of its parent
+	s.Parent.InvalidateContentCache()
+}
+
+// FetchContent returns the content of the subdirectory, populated by fetching the content of its parent with
+// fetchParent. If the parent cannot be fetched, the content last derived from it, if any, is returned along with the
+// error.
+func (s *Subdirectory) FetchContent(fetchParent FetchContentFunc) (map[string]fstree.GroupSource, map[string]fstree.RepositorySource, error) {
+	if _, _, err := fetchParent(); err != nil {
+		groups, repositories := s.content.Peek()
+		return groups, repositories, err
+	}
+	groups, repositories := s.content.Peek()
+	if groups == nil || repositories == nil {
+		// the subdirectory is no longer part of its parent
+		return make(map[string]fstree.GroupSource), make(map[string]fstree.RepositorySource), nil
+	}
+	return groups, repositories, nil
+}
+
+// subdirectoryID returns a stable id for the subdirectory at path in the group with id gid
+func subdirectoryID(gid uint64, path string) uint64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%v/%v", gid, path)
+	return subdirectoryIDFlag | (h.Sum64() & (subdirectoryIDFlag - 1))
+}
+
+// Grouping lists the repositories of each member of a subdirectory, such as the repositories of each team
+type Grouping struct {
+	// the name of the subdirectory, eg: "teams"
+	Name string
+	// the kind of the subdirectory of each member, eg: "team"
+	Kind string
+	// the ids of the repositories, by member name
+	Members map[string][]uint64
+}
+
+// Subdirectories holds the subdirectories of the groups of a forge
+type Subdirectories struct {
+	logger *slog.Logger
+	store  *Store
+
+	mux            sync.RWMutex
+	subdirectories map[uint64]*Subdirectory
+}
+
+func NewSubdirectories(logger *slog.Logger, store *Store) *Subdirectories {
+	return &Subdirectories{
+		logger: logger,
+		store:  store,
+
+		subdirectories: map[uint64]*Subdirectory{},
+	}
+}
+
+// Get returns the subdirectory with id gid, if it exists
+func (s *Subdirectories) Get(gid uint64) (*Subdirectory, bool) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	subdirectory, found := s.subdirectories[gid]
+	return subdirectory, found
+}
+
+func (s *Subdirectories) newSubdirectory(parent fstree.GroupSource, path string, name string, kind string) *Subdirectory {
+	id := subdirectoryID(parent.GetGroupID(), path)
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if subdirectory, found := s.subdirectories[id]; found {
+		return subdirectory
+	}
+	subdirectory := &Subdirectory{
+		ID:   id,
+		Name: name,
+		Kind: kind,
+
+		Parent: parent,
+
+		content: NewContentCache(s.logger, 0),
+	}
+	s.subdirectories[id] = subdirectory
+	s.store.Track(id, name, kind, subdirectory.content)
+	return subdirectory
+}
+
+// Group moves the repositories of parent to the subdirectories described by groupings. The repositories that don't
+// belong to any subdirectory are left in parent.
+func (s *Subdirectories) Group(parent fstree.GroupSource, repositories map[string]fstree.RepositorySource, groupings []Grouping) (map[string]fstree.GroupSource, map[string]fstree.RepositorySource) {
+	childGroups := make(map[string]fstree.GroupSource, len(groupings))
+	grouped := make(map[uint64]bool)
+
+	namesByID := make(map[uint64]string, len(repositories))
+	for name, repository := range repositories {
+		namesByID[repository.GetRepositoryID()] = name
+	}
+
+	for _, grouping := range groupings {
+		subdirectory := s.newSubdirectory(parent, grouping.Name, grouping.Name, SubdirectoryKind)
+		memberGroups := make(map[string]fstree.GroupSource, len(grouping.Members))
+		for memberName, ids := range grouping.Members {
+			memberRepositories := make(map[string]fstree.RepositorySource)
+			for _, id := range ids {
+				// the repositories that were filtered out are not in parent
+				if name, found := namesByID[id]; found {
+					memberRepositories[name] = repositories[name]
+					grouped[id] = true
+				}
+			}
+			if len(memberRepositories) == 0 {
+				continue
+			}
+			memberSubdirectory := s.newSubdirectory(parent, grouping.Name+"/"+memberName, memberName, grouping.Kind)
+			memberSubdirectory.content.Set(make(map[string]fstree.GroupSource), memberRepositories)
+			memberGroups[memberName] = memberSubdirectory
+		}
+		subdirectory.content.Set(memberGroups, make(map[string]fstree.RepositorySource))
+		childGroups[grouping.Name] = subdirectory
+	}
+
+	childRepositories := make(map[string]fstree.RepositorySource)
+	for name, repository := range repositories {
+		if !grouped[repository.GetRepositoryID()] {
+			childRepositories[name] = repository
+		}
+	}
+	return childGroups, childRepositories
+}
+
+// Restore restores the subdirectories of parent found in the content of groupSnapshot. The repositories of the
+// subdirectories are restored with restoreRepositories.
+func (s *Subdirectories) Restore(parent fstree.GroupSource, groupSnapshot *GroupSnapshot, snapshot *Snapshot, restoreRepositories func(*GroupSnapshot) map[string]fstree.RepositorySource) map[string]fstree.GroupSource {
+	return s.restore(parent, "", groupSnapshot, snapshot, restoreRepositories)
+}
+
+func (s *Subdirectories) restore(parent fstree.GroupSource, path string, groupSnapshot *GroupSnapshot, snapshot *Snapshot, restoreRepositories func(*GroupSnapshot) map[string]fstree.RepositorySource) map[string]fstree.GroupSource {
+	childGroups := make(map[string]fstree.GroupSource, len(groupSnapshot.Groups))
+	for name, gid := range groupSnapshot.Groups {
+		subdirectorySnapshot, found := snapshot.Groups[gid]
+		if !found || subdirectorySnapshot.FetchedAt.IsZero() {
+			continue
+		}
+		subdirectoryPath := path + name
+		subdirectory := s.newSubdirectory(parent, subdirectoryPath, name, subdirectorySnapshot.Kind)
+		subdirectory.content.Restore(
+			s.restore(parent, subdirectoryPath+"/", subdirectorySnapshot, snapshot, restoreRepositories),
+			restoreRepositories(subdirectorySnapshot),
+			subdirectorySnapshot.FetchedAt,
+		)
+		childGroups[name] = subdirectory
+	}
+	return childGroups
+}
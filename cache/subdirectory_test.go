@@ -0,0 +1,114 @@
+package cache_test
+
+import (
+	"log/slog"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/badjware/gitforgefs/cache"
+	"github.com/badjware/gitforgefs/fstree"
+)
+
+// listSubdirectories returns the id of the repositories of each subdirectory in groups, by path
+func listSubdirectories(t *testing.T, prefix string, groups map[string]fstree.GroupSource) map[string][]uint64 {
+	fetchParent := func() (map[string]fstree.GroupSource, map[string]fstree.RepositorySource, error) {
+		return nil, nil, nil
+	}
+	tree := map[string][]uint64{}
+	for name, group := range groups {
+		subdirectory, ok := group.(*cache.Subdirectory)
+		if !ok {
+			t.Fatalf("%v%v is a %T; expected a subdirectory", prefix, name, group)
+		}
+		childGroups, childRepositories, err := subdirectory.FetchContent(fetchParent)
+		if err != nil {
+			t.Fatalf("FetchContent() returned error: %v", err)
+		}
+		for path, ids := range listSubdirectories(t, prefix+name+"/", childGroups) {
+			tree[path] = ids
+		}
+		if len(childRepositories) > 0 {
+			ids := []uint64{}
+			for _, repository := range childRepositories {
+				ids = append(ids, repository.GetRepositoryID())
+			}
+			sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+			tree[prefix+name] = ids
+		}
+	}
+	return tree
+}
+
+func TestSubdirectories(t *testing.T) {
+	parent := &testGroup{id: 1}
+	repositories := map[string]fstree.RepositorySource{
+		"api":  &testRepository{id: 1},
+		"web":  &testRepository{id: 2},
+		"misc": &testRepository{id: 3},
+	}
+	groupings := []cache.Grouping{
+		{
+			Name: "teams",
+			Kind: "team",
+			// repository 99 was filtered out of the parent
+			Members: map[string][]uint64{"backend": {1, 99}, "frontend": {1, 2}, "empty": {99}},
+		},
+		{
+			Name:    "topics",
+			Kind:    "topic",
+			Members: map[string][]uint64{"web": {2}},
+		},
+	}
+	expected := map[string][]uint64{
+		"teams/backend":  {1},
+		"teams/frontend": {1, 2},
+		"topics/web":     {2},
+	}
+
+	path := filepath.Join(t.TempDir(), "test.json")
+	store := cache.NewStore(slog.Default(), path)
+	store.Load("https://example.com")
+	subdirectories := cache.NewSubdirectories(slog.Default(), store)
+	childGroups, childRepositories := subdirectories.Group(parent, repositories, groupings)
+	if got := listSubdirectories(t, "", childGroups); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Group() returned subdirectories %v; expected %v", got, expected)
+	}
+	expectedRepositories := map[string]fstree.RepositorySource{"misc": repositories["misc"]}
+	if !reflect.DeepEqual(childRepositories, expectedRepositories) {
+		t.Fatalf("Group() left repositories %v; expected %v", childRepositories, expectedRepositories)
+	}
+
+	// the subdirectories keep their id
+	regroupedGroups, _ := cache.NewSubdirectories(slog.Default(), nil).Group(parent, repositories, groupings)
+	if regroupedGroups["teams"].GetGroupID() != childGroups["teams"].GetGroupID() {
+		t.Fatalf("Group() returned subdirectory with id %v; expected %v", regroupedGroups["teams"].GetGroupID(), childGroups["teams"].GetGroupID())
+	}
+	if _, found := subdirectories.Get(childGroups["teams"].GetGroupID()); !found {
+		t.Fatalf("Get() did not find the subdirectory")
+	}
+
+	// the subdirectories are restored from the metadata cache
+	content := cache.NewContentCache(slog.Default(), 0)
+	content.Set(childGroups, childRepositories)
+	store.Track(parent.id, "parent", "group", content)
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close() returned error: %v", err)
+	}
+	snapshot := cache.NewStore(slog.Default(), path).Load("https://example.com")
+	if snapshot == nil {
+		t.Fatalf("Load() returned nil; expected a snapshot")
+	}
+	restoreRepositories := func(groupSnapshot *cache.GroupSnapshot) map[string]fstree.RepositorySource {
+		restored := map[string]fstree.RepositorySource{}
+		for name, repositorySnapshot := range groupSnapshot.Repositories {
+			restored[name] = &testRepository{id: repositorySnapshot.ID}
+		}
+		return restored
+	}
+	restoredGroups := cache.NewSubdirectories(slog.Default(), nil).Restore(parent, snapshot.Groups[parent.id], snapshot, restoreRepositories)
+	if got := listSubdirectories(t, "", restoredGroups); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Restore() returned subdirectories %v; expected %v", got, expected)
+	}
+}
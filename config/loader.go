@@ -4,14 +4,25 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/badjware/gitforgefs/utils"
 	"gopkg.in/yaml.v2"
 )
 
 const (
-	ForgeGitlab = "gitlab"
-	ForgeGithub = "github"
-	ForgeGitea  = "gitea"
+	ForgeGitlab    = "gitlab"
+	ForgeGithub    = "github"
+	ForgeGitea     = "gitea"
+	ForgeBitbucket = "bitbucket"
+	ForgeManifest  = "manifest"
+
+	BitbucketCloud      = "cloud"
+	BitbucketDataCenter = "datacenter"
+
+	BitbucketCloudURL = "https://api.bitbucket.org/2.0"
 
 	PullMethodHTTP = "http"
 	PullMethodSSH  = "ssh"
@@ -19,31 +30,88 @@ const (
 	ArchivedProjectShow   = "show"
 	ArchivedProjectHide   = "hide"
 	ArchivedProjectIgnore = "ignore"
+
+	ForkShow   = "show"
+	ForkHide   = "hide"
+	ForkIgnore = "ignore"
+
+	OnCloneInit         = "init"
+	OnCloneClone        = "clone"
+	OnCloneBlobless     = "blobless"
+	OnCloneTreeless     = "treeless"
+	OnCloneSparse       = "sparse"
+	OnCloneSingleBranch = "single-branch"
+
+	LayoutID   = "id"
+	LayoutPath = "path"
+
+	GCActionReport  = "report"
+	GCActionDelete  = "delete"
+	GCActionArchive = "archive"
+
+	MirrorShow   = "show"
+	MirrorHide   = "hide"
+	MirrorIgnore = "ignore"
+
+	CurrentUserSubdirectoryGroups  = "groups"
+	CurrentUserSubdirectoryStarred = "starred"
+
+	OrgSubdirectoryTeams  = "teams"
+	OrgSubdirectoryTopics = "topics"
+
+	ForksInclude = "include"
+	ForksExclude = "exclude"
+	ForksOnly    = "only"
 )
 
 type (
 	Config struct {
-		FS     FSConfig           `yaml:"fs,omitempty"`
-		Gitlab GitlabClientConfig `yaml:"gitlab,omitempty"`
-		Github GithubClientConfig `yaml:"github,omitempty"`
-		Gitea  GiteaClientConfig  `yaml:"gitea,omitempty"`
-		Git    GitClientConfig    `yaml:"git,omitempty"`
+		FS        FSConfig              `yaml:"fs,omitempty"`
+		Forges    []ForgeConfig         `yaml:"forges,omitempty"`
+		Gitlab    GitlabClientConfig    `yaml:"gitlab,omitempty"`
+		Github    GithubClientConfig    `yaml:"github,omitempty"`
+		Gitea     GiteaClientConfig     `yaml:"gitea,omitempty"`
+		Bitbucket BitbucketClientConfig `yaml:"bitbucket,omitempty"`
+		Manifest  ManifestClientConfig  `yaml:"manifest,omitempty"`
+		Git       GitClientConfig       `yaml:"git,omitempty"`
+		Metrics   MetricsConfig         `yaml:"metrics,omitempty"`
 	}
 	FSConfig struct {
-		Mountpoint   string `yaml:"mountpoint,omitempty"`
-		MountOptions string `yaml:"mountoptions,omitempty"`
-		Forge        string `yaml:"forge,omitempty"`
+		Mountpoint    string `yaml:"mountpoint,omitempty"`
+		MountOptions  string `yaml:"mountoptions,omitempty"`
+		Forge         string `yaml:"forge,omitempty"`
+		PersistCache  bool   `yaml:"persist_cache,omitempty"`
+		MetadataFiles bool   `yaml:"metadata_files,omitempty"`
+		ControlSocket string `yaml:"control_socket,omitempty"`
+	}
+	ForgeConfig struct {
+		Name string `yaml:"name,omitempty"`
+		Type string `yaml:"type,omitempty"`
+
+		Gitlab    GitlabClientConfig    `yaml:"gitlab,omitempty"`
+		Github    GithubClientConfig    `yaml:"github,omitempty"`
+		Gitea     GiteaClientConfig     `yaml:"gitea,omitempty"`
+		Bitbucket BitbucketClientConfig `yaml:"bitbucket,omitempty"`
+		Manifest  ManifestClientConfig  `yaml:"manifest,omitempty"`
 	}
 	GitlabClientConfig struct {
 		URL   string `yaml:"url,omitempty"`
 		Token string `yaml:"token,omitempty"`
 
 		GroupIDs  []int    `yaml:"group_ids,omitempty"`
+		Groups    []string `yaml:"groups,omitempty"`
 		UserNames []string `yaml:"user_names,omitempty"`
 
-		ArchivedProjectHandling string `yaml:"archived_project_handling,omitempty"`
-		IncludeCurrentUser      bool   `yaml:"include_current_user,omitempty"`
-		PullMethod              string `yaml:"pull_method,omitempty"`
+		ArchivedProjectHandling   string   `yaml:"archived_project_handling,omitempty"`
+		ForkHandling              string   `yaml:"fork_handling,omitempty"`
+		IncludeCurrentUser        bool     `yaml:"include_current_user,omitempty"`
+		CurrentUserSubdirectories []string `yaml:"current_user_subdirectories,omitempty"`
+		PullMethod                string   `yaml:"pull_method,omitempty"`
+
+		Filters FilterConfig `yaml:"filters,omitempty"`
+
+		CacheTTL          time.Duration            `yaml:"cache_ttl,omitempty"`
+		CacheTTLOverrides map[string]time.Duration `yaml:"cache_ttl_overrides,omitempty"`
 	}
 	GithubClientConfig struct {
 		Token string `yaml:"token,omitempty"`
@@ -51,9 +119,16 @@ type (
 		OrgNames  []string `yaml:"org_names,omitempty"`
 		UserNames []string `yaml:"user_names,omitempty"`
 
-		ArchivedRepoHandling string `yaml:"archived_repo_handling,omitempty"`
-		IncludeCurrentUser   bool   `yaml:"include_current_user,omitempty"`
-		PullMethod           string `yaml:"pull_method,omitempty"`
+		ArchivedRepoHandling string   `yaml:"archived_repo_handling,omitempty"`
+		ForkHandling         string   `yaml:"fork_handling,omitempty"`
+		IncludeCurrentUser   bool     `yaml:"include_current_user,omitempty"`
+		OrgSubdirectories    []string `yaml:"org_subdirectories,omitempty"`
+		PullMethod           string   `yaml:"pull_method,omitempty"`
+
+		Filters FilterConfig `yaml:"filters,omitempty"`
+
+		CacheTTL          time.Duration            `yaml:"cache_ttl,omitempty"`
+		CacheTTLOverrides map[string]time.Duration `yaml:"cache_ttl_overrides,omitempty"`
 	}
 	GiteaClientConfig struct {
 		URL   string `yaml:"url,omitempty"`
@@ -62,18 +137,87 @@ type (
 		OrgNames  []string `yaml:"org_names,omitempty"`
 		UserNames []string `yaml:"user_names,omitempty"`
 
+		ArchivedRepoHandling string   `yaml:"archived_repo_handling,omitempty"`
+		ForkHandling         string   `yaml:"fork_handling,omitempty"`
+		MirrorHandling       string   `yaml:"mirror_handling,omitempty"`
+		IncludeCurrentUser   bool     `yaml:"include_current_user,omitempty"`
+		OrgSubdirectories    []string `yaml:"org_subdirectories,omitempty"`
+		PullMethod           string   `yaml:"pull_method,omitempty"`
+
+		Filters FilterConfig `yaml:"filters,omitempty"`
+
+		CacheTTL          time.Duration            `yaml:"cache_ttl,omitempty"`
+		CacheTTLOverrides map[string]time.Duration `yaml:"cache_ttl_overrides,omitempty"`
+	}
+	BitbucketClientConfig struct {
+		URL      string `yaml:"url,omitempty"`
+		Edition  string `yaml:"edition,omitempty"`
+		Username string `yaml:"username,omitempty"`
+		Token    string `yaml:"token,omitempty"`
+
+		WorkspaceNames []string `yaml:"workspace_names,omitempty"`
+		ProjectKeys    []string `yaml:"project_keys,omitempty"`
+		UserNames      []string `yaml:"user_names,omitempty"`
+
 		ArchivedRepoHandling string `yaml:"archived_repo_handling,omitempty"`
 		IncludeCurrentUser   bool   `yaml:"include_current_user,omitempty"`
 		PullMethod           string `yaml:"pull_method,omitempty"`
+
+		CacheTTL          time.Duration            `yaml:"cache_ttl,omitempty"`
+		CacheTTLOverrides map[string]time.Duration `yaml:"cache_ttl_overrides,omitempty"`
+	}
+	ManifestClientConfig struct {
+		Path string `yaml:"path,omitempty"`
+
+		ArchivedRepoHandling string        `yaml:"archived_repo_handling,omitempty"`
+		PollInterval         time.Duration `yaml:"poll_interval,omitempty"`
 	}
 	GitClientConfig struct {
-		CloneLocation    string `yaml:"clone_location,omitempty"`
-		Remote           string `yaml:"remote,omitempty"`
-		OnClone          string `yaml:"on_clone,omitempty"`
-		AutoPull         bool   `yaml:"auto_pull,omitempty"`
-		Depth            int    `yaml:"depth,omitempty"`
-		QueueSize        int    `yaml:"queue_size,omitempty"`
-		QueueWorkerCount int    `yaml:"worker_count,omitempty"`
+		CloneLocation    string   `yaml:"clone_location,omitempty"`
+		Remote           string   `yaml:"remote,omitempty"`
+		OnClone          string   `yaml:"on_clone,omitempty"`
+		SparseCheckout   []string `yaml:"sparse_checkout,omitempty"`
+		Layout           string   `yaml:"layout,omitempty"`
+		AutoPull         bool     `yaml:"auto_pull,omitempty"`
+		Depth            int      `yaml:"depth,omitempty"`
+		QueueSize        int      `yaml:"queue_size,omitempty"`
+		QueueWorkerCount int      `yaml:"worker_count,omitempty"`
+
+		CloneWaitTimeout time.Duration `yaml:"clone_wait_timeout,omitempty"`
+
+		GCInterval time.Duration `yaml:"gc_interval,omitempty"`
+		GCAction   string        `yaml:"gc_action,omitempty"`
+
+		MaxSize ByteSize `yaml:"max_size,omitempty"`
+
+		CredentialHelper bool `yaml:"credential_helper,omitempty"`
+		// the command git runs to get the credentials of the forges, set when credential_helper is enabled
+		CredentialHelperCommand string `yaml:"-"`
+
+		Rules []GitRule `yaml:"rules,omitempty"`
+	}
+	// GitRule overrides the git settings of the repositories whose path matches Path. The unset settings are left as is.
+	GitRule struct {
+		Path string `yaml:"path,omitempty"`
+
+		Remote         string   `yaml:"remote,omitempty"`
+		OnClone        string   `yaml:"on_clone,omitempty"`
+		SparseCheckout []string `yaml:"sparse_checkout,omitempty"`
+		AutoPull       *bool    `yaml:"auto_pull,omitempty"`
+		Depth          *int     `yaml:"depth,omitempty"`
+
+		// extra keys set with `git config` in the local clone
+		Config map[string]string `yaml:"config,omitempty"`
+	}
+	FilterConfig struct {
+		Include    []string `yaml:"include,omitempty"`
+		Exclude    []string `yaml:"exclude,omitempty"`
+		Topics     []string `yaml:"topics,omitempty"`
+		Visibility []string `yaml:"visibility,omitempty"`
+		Forks      string   `yaml:"forks,omitempty"`
+	}
+	MetricsConfig struct {
+		Listen string `yaml:"listen,omitempty"`
 	}
 )
 
@@ -87,35 +231,37 @@ func LoadConfig(configPath string) (*Config, error) {
 
 	config := &Config{
 		FS: FSConfig{
-			Mountpoint:   "",
-			MountOptions: "nodev,nosuid",
-			Forge:        "",
-		},
-		Gitlab: GitlabClientConfig{
-			URL:                     "https://gitlab.com",
-			Token:                   "",
-			PullMethod:              "http",
-			GroupIDs:                []int{9970},
-			UserNames:               []string{},
-			ArchivedProjectHandling: "hide",
-			IncludeCurrentUser:      true,
-		},
-		Github: GithubClientConfig{
-			Token:                "",
-			PullMethod:           "http",
-			OrgNames:             []string{},
-			UserNames:            []string{},
-			ArchivedRepoHandling: "hide",
-			IncludeCurrentUser:   true,
+			Mountpoint:    "",
+			MountOptions:  "nodev,nosuid",
+			Forge:         "",
+			PersistCache:  true,
+			MetadataFiles: false,
+			ControlSocket: "",
 		},
+		Gitlab:    defaultGitlabConfig(),
+		Github:    defaultGithubConfig(),
+		Gitea:     defaultGiteaConfig(),
+		Bitbucket: defaultBitbucketConfig(),
+		Manifest:  defaultManifestConfig(),
 		Git: GitClientConfig{
 			CloneLocation:    defaultCloneLocation,
 			Remote:           "origin",
 			OnClone:          "init",
+			SparseCheckout:   []string{},
+			Layout:           "id",
 			AutoPull:         false,
 			Depth:            0,
 			QueueSize:        200,
 			QueueWorkerCount: 5,
+			CloneWaitTimeout: 0,
+			GCInterval:       0,
+			GCAction:         "report",
+			MaxSize:          0,
+			CredentialHelper: true,
+			Rules:            []GitRule{},
+		},
+		Metrics: MetricsConfig{
+			Listen: "",
 		},
 	}
 
@@ -130,60 +276,583 @@ func LoadConfig(configPath string) (*Config, error) {
 		return nil, fmt.Errorf("failed to parse config file: %v", err)
 	}
 
-	// validate forge is set
-	if config.FS.Forge != ForgeGithub && config.FS.Forge != ForgeGitlab && config.FS.Forge != ForgeGitea {
-		return nil, fmt.Errorf("fs.forge must be either \"%v\", \"%v\", or \"%v\"", ForgeGitlab, ForgeGithub, ForgeGitea)
+	// validate forge is set, unless a list of forges is configured
+	if len(config.Forges) == 0 && !isValidForge(config.FS.Forge) {
+		return nil, fmt.Errorf("fs.forge must be either \"%v\", \"%v\", \"%v\", \"%v\", or \"%v\"", ForgeGitlab, ForgeGithub, ForgeGitea, ForgeBitbucket, ForgeManifest)
 	}
 
 	return config, nil
 }
 
+func defaultGitlabConfig() GitlabClientConfig {
+	return GitlabClientConfig{
+		URL:                       "https://gitlab.com",
+		Token:                     "",
+		PullMethod:                "http",
+		GroupIDs:                  nil,
+		Groups:                    nil,
+		UserNames:                 []string{},
+		ArchivedProjectHandling:   "hide",
+		ForkHandling:              "show",
+		IncludeCurrentUser:        true,
+		CurrentUserSubdirectories: []string{},
+		Filters:                   defaultFilterConfig(),
+	}
+}
+
+func defaultGithubConfig() GithubClientConfig {
+	return GithubClientConfig{
+		Token:                "",
+		PullMethod:           "http",
+		OrgNames:             []string{},
+		UserNames:            []string{},
+		ArchivedRepoHandling: "hide",
+		ForkHandling:         "show",
+		IncludeCurrentUser:   true,
+		OrgSubdirectories:    []string{},
+		Filters:              defaultFilterConfig(),
+	}
+}
+
+func defaultGiteaConfig() GiteaClientConfig {
+	return GiteaClientConfig{
+		URL:                  "https://gitea.com",
+		Token:                "",
+		PullMethod:           "http",
+		OrgNames:             []string{},
+		UserNames:            []string{},
+		ArchivedRepoHandling: "hide",
+		ForkHandling:         "show",
+		MirrorHandling:       "show",
+		IncludeCurrentUser:   true,
+		OrgSubdirectories:    []string{},
+		Filters:              defaultFilterConfig(),
+	}
+}
+
+func defaultFilterConfig() FilterConfig {
+	return FilterConfig{
+		Include:    []string{},
+		Exclude:    []string{},
+		Topics:     []string{},
+		Visibility: []string{},
+		Forks:      ForksInclude,
+	}
+}
+
+func defaultBitbucketConfig() BitbucketClientConfig {
+	return BitbucketClientConfig{
+		URL:                  BitbucketCloudURL,
+		Edition:              BitbucketCloud,
+		Username:             "",
+		Token:                "",
+		PullMethod:           "http",
+		WorkspaceNames:       []string{},
+		ProjectKeys:          []string{},
+		UserNames:            []string{},
+		ArchivedRepoHandling: "hide",
+		IncludeCurrentUser:   true,
+	}
+}
+
+func defaultManifestConfig() ManifestClientConfig {
+	return ManifestClientConfig{
+		Path:                 "",
+		ArchivedRepoHandling: "hide",
+		PollInterval:         10 * time.Second,
+	}
+}
+
+// UnmarshalYAML fills the defaults of the forge before parsing it, like LoadConfig does for the top-level forges.
+func (c *ForgeConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type rawForgeConfig ForgeConfig
+	raw := rawForgeConfig{
+		Gitlab:    defaultGitlabConfig(),
+		Github:    defaultGithubConfig(),
+		Gitea:     defaultGiteaConfig(),
+		Bitbucket: defaultBitbucketConfig(),
+		Manifest:  defaultManifestConfig(),
+	}
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+	*c = ForgeConfig(raw)
+	return nil
+}
+
+// ByteSize is a size in bytes. In the config file, it can be written with a unit, eg: "500MB" or "20GiB".
+type ByteSize int64
+
+var byteSizeUnits = map[string]int64{
+	"":    1,
+	"b":   1,
+	"kb":  1000,
+	"mb":  1000 * 1000,
+	"gb":  1000 * 1000 * 1000,
+	"tb":  1000 * 1000 * 1000 * 1000,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+}
+
+// ParseByteSize parses a size in bytes, optionally followed by a unit such as "MB" or "GiB"
+func ParseByteSize(s string) (ByteSize, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i == -1 {
+		i = len(s)
+	}
+	unit, found := byteSizeUnits[strings.ToLower(strings.TrimSpace(s[i:]))]
+	if !found {
+		return 0, fmt.Errorf("invalid size \"%v\": unknown unit \"%v\"", s, s[i:])
+	}
+	value, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size \"%v\": %v", s, err)
+	}
+	return ByteSize(value * float64(unit)), nil
+}
+
+func (s *ByteSize) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var raw string
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+	size, err := ParseByteSize(raw)
+	if err != nil {
+		return err
+	}
+	*s = size
+	return nil
+}
+
+func isValidForge(forge string) bool {
+	return forge == ForgeGitlab || forge == ForgeGithub || forge == ForgeGitea || forge == ForgeBitbucket || forge == ForgeManifest
+}
+
+// MakeForgeConfigs returns the forges to expose in the filesystem.
+// If no list of forges is configured, the single forge configured with fs.forge is returned, named after its type.
+func MakeForgeConfigs(config *Config) ([]ForgeConfig, error) {
+	if len(config.Forges) == 0 {
+		forgeConfig := ForgeConfig{
+			Name:      config.FS.Forge,
+			Type:      config.FS.Forge,
+			Gitlab:    config.Gitlab,
+			Github:    config.Github,
+			Gitea:     config.Gitea,
+			Bitbucket: config.Bitbucket,
+			Manifest:  config.Manifest,
+		}
+		var err error
+		switch config.FS.Forge {
+		case ForgeGitlab:
+			err = validateGitlabConfig(ForgeGitlab, &forgeConfig.Gitlab)
+		case ForgeGithub:
+			err = validateGithubConfig(ForgeGithub, &forgeConfig.Github)
+		case ForgeGitea:
+			err = validateGiteaConfig(ForgeGitea, &forgeConfig.Gitea)
+		case ForgeBitbucket:
+			err = validateBitbucketConfig(ForgeBitbucket, &forgeConfig.Bitbucket)
+		case ForgeManifest:
+			err = validateManifestConfig(ForgeManifest, &forgeConfig.Manifest)
+		default:
+			err = fmt.Errorf("fs.forge must be either \"%v\", \"%v\", \"%v\", \"%v\", or \"%v\"", ForgeGitlab, ForgeGithub, ForgeGitea, ForgeBitbucket, ForgeManifest)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return []ForgeConfig{forgeConfig}, nil
+	}
+
+	forgeConfigs := make([]ForgeConfig, 0, len(config.Forges))
+	names := map[string]bool{}
+	for i, forgeConfig := range config.Forges {
+		// parse name
+		// the name is used as the name of the top-level directory of the forge
+		if forgeConfig.Name == "" || forgeConfig.Name == "." || forgeConfig.Name == ".." || strings.Contains(forgeConfig.Name, "/") {
+			return nil, fmt.Errorf("forges[%v].name must be a valid directory name", i)
+		}
+		if names[forgeConfig.Name] {
+			return nil, fmt.Errorf("forges[%v].name \"%v\" is used by more than one forge", i, forgeConfig.Name)
+		}
+		names[forgeConfig.Name] = true
+
+		// parse type
+		prefix := fmt.Sprintf("forges[%v].%v", forgeConfig.Name, forgeConfig.Type)
+		var err error
+		switch forgeConfig.Type {
+		case ForgeGitlab:
+			err = validateGitlabConfig(prefix, &forgeConfig.Gitlab)
+		case ForgeGithub:
+			err = validateGithubConfig(prefix, &forgeConfig.Github)
+		case ForgeGitea:
+			err = validateGiteaConfig(prefix, &forgeConfig.Gitea)
+		case ForgeBitbucket:
+			err = validateBitbucketConfig(prefix, &forgeConfig.Bitbucket)
+		case ForgeManifest:
+			err = validateManifestConfig(prefix, &forgeConfig.Manifest)
+		default:
+			err = fmt.Errorf("forges[%v].type must be either \"%v\", \"%v\", \"%v\", \"%v\", or \"%v\"", forgeConfig.Name, ForgeGitlab, ForgeGithub, ForgeGitea, ForgeBitbucket, ForgeManifest)
+		}
+		if err != nil {
+			return nil, err
+		}
+		forgeConfigs = append(forgeConfigs, forgeConfig)
+	}
+	return forgeConfigs, nil
+}
+
 func MakeGitlabConfig(config *Config) (*GitlabClientConfig, error) {
+	if err := validateGitlabConfig("gitlab", &config.Gitlab); err != nil {
+		return nil, err
+	}
+	return &config.Gitlab, nil
+}
+
+func validateGitlabConfig(prefix string, config *GitlabClientConfig) error {
 	// parse pull_method
-	if config.Gitlab.PullMethod != PullMethodHTTP && config.Gitlab.PullMethod != PullMethodSSH {
-		return nil, fmt.Errorf("gitlab.pull_method must be either \"%v\" or \"%v\"", PullMethodHTTP, PullMethodSSH)
+	if config.PullMethod != PullMethodHTTP && config.PullMethod != PullMethodSSH {
+		return fmt.Errorf("%v.pull_method must be either \"%v\" or \"%v\"", prefix, PullMethodHTTP, PullMethodSSH)
+	}
+
+	// parse groups
+	// gitlab-org is exposed when no group is configured, an empty list exposes no group
+	if config.GroupIDs == nil && config.Groups == nil {
+		config.GroupIDs = []int{9970}
+	}
+	for i, group := range config.Groups {
+		if group == "" || strings.HasPrefix(group, "/") || strings.HasSuffix(group, "/") {
+			return fmt.Errorf("%v.groups[%v] must be the id or the full path of a group, eg: \"gitlab-org/security\"", prefix, i)
+		}
 	}
 
 	// parse archive_handing
-	if config.Gitlab.ArchivedProjectHandling != ArchivedProjectShow && config.Gitlab.ArchivedProjectHandling != ArchivedProjectHide && config.Gitlab.ArchivedProjectHandling != ArchivedProjectIgnore {
-		return nil, fmt.Errorf("gitlab.archived_project_handling must be either \"%v\", \"%v\" or \"%v\"", ArchivedProjectShow, ArchivedProjectHide, ArchivedProjectIgnore)
+	if config.ArchivedProjectHandling != ArchivedProjectShow && config.ArchivedProjectHandling != ArchivedProjectHide && config.ArchivedProjectHandling != ArchivedProjectIgnore {
+		return fmt.Errorf("%v.archived_project_handling must be either \"%v\", \"%v\" or \"%v\"", prefix, ArchivedProjectShow, ArchivedProjectHide, ArchivedProjectIgnore)
 	}
 
-	return &config.Gitlab, nil
+	// parse fork_handling
+	if err := validateForkHandling(prefix, config.ForkHandling); err != nil {
+		return err
+	}
+
+	// parse current_user_subdirectories
+	for i, subdirectory := range config.CurrentUserSubdirectories {
+		if subdirectory != CurrentUserSubdirectoryGroups && subdirectory != CurrentUserSubdirectoryStarred {
+			return fmt.Errorf("%v.current_user_subdirectories[%v] must be either \"%v\" or \"%v\"", prefix, i, CurrentUserSubdirectoryGroups, CurrentUserSubdirectoryStarred)
+		}
+	}
+
+	// parse filters
+	if err := validateFilterConfig(prefix, &config.Filters); err != nil {
+		return err
+	}
+	if err := validateForkFilter(prefix, config.ForkHandling, config.Filters.Forks); err != nil {
+		return err
+	}
+
+	// parse cache_ttl
+	if err := validateCacheTTL(prefix, config.CacheTTL, config.CacheTTLOverrides); err != nil {
+		return err
+	}
+
+	return nil
 }
 
 func MakeGithubConfig(config *Config) (*GithubClientConfig, error) {
+	if err := validateGithubConfig("github", &config.Github); err != nil {
+		return nil, err
+	}
+	return &config.Github, nil
+}
+
+func validateGithubConfig(prefix string, config *GithubClientConfig) error {
 	// parse pull_method
-	if config.Github.PullMethod != PullMethodHTTP && config.Github.PullMethod != PullMethodSSH {
-		return nil, fmt.Errorf("github.pull_method must be either \"%v\" or \"%v\"", PullMethodHTTP, PullMethodSSH)
+	if config.PullMethod != PullMethodHTTP && config.PullMethod != PullMethodSSH {
+		return fmt.Errorf("%v.pull_method must be either \"%v\" or \"%v\"", prefix, PullMethodHTTP, PullMethodSSH)
 	}
 
 	// parse archive_handing
-	if config.Github.ArchivedRepoHandling != ArchivedProjectShow && config.Github.ArchivedRepoHandling != ArchivedProjectHide && config.Github.ArchivedRepoHandling != ArchivedProjectIgnore {
-		return nil, fmt.Errorf("github.archived_repo_handling must be either \"%v\", \"%v\" or \"%v\"", ArchivedProjectShow, ArchivedProjectHide, ArchivedProjectIgnore)
+	if config.ArchivedRepoHandling != ArchivedProjectShow && config.ArchivedRepoHandling != ArchivedProjectHide && config.ArchivedRepoHandling != ArchivedProjectIgnore {
+		return fmt.Errorf("%v.archived_repo_handling must be either \"%v\", \"%v\" or \"%v\"", prefix, ArchivedProjectShow, ArchivedProjectHide, ArchivedProjectIgnore)
 	}
 
-	return &config.Github, nil
+	// parse fork_handling
+	if err := validateForkHandling(prefix, config.ForkHandling); err != nil {
+		return err
+	}
+
+	// parse org_subdirectories
+	for i, subdirectory := range config.OrgSubdirectories {
+		if subdirectory != OrgSubdirectoryTeams && subdirectory != OrgSubdirectoryTopics {
+			return fmt.Errorf("%v.org_subdirectories[%v] must be either \"%v\" or \"%v\"", prefix, i, OrgSubdirectoryTeams, OrgSubdirectoryTopics)
+		}
+	}
+
+	// parse filters
+	if err := validateFilterConfig(prefix, &config.Filters); err != nil {
+		return err
+	}
+	if err := validateForkFilter(prefix, config.ForkHandling, config.Filters.Forks); err != nil {
+		return err
+	}
+
+	// parse cache_ttl
+	if err := validateCacheTTL(prefix, config.CacheTTL, config.CacheTTLOverrides); err != nil {
+		return err
+	}
+
+	return nil
 }
 
 func MakeGiteaConfig(config *Config) (*GiteaClientConfig, error) {
+	if err := validateGiteaConfig("gitea", &config.Gitea); err != nil {
+		return nil, err
+	}
+	return &config.Gitea, nil
+}
+
+func validateGiteaConfig(prefix string, config *GiteaClientConfig) error {
 	// parse pull_method
-	if config.Gitea.PullMethod != PullMethodHTTP && config.Gitea.PullMethod != PullMethodSSH {
-		return nil, fmt.Errorf("gitea.pull_method must be either \"%v\" or \"%v\"", PullMethodHTTP, PullMethodSSH)
+	if config.PullMethod != PullMethodHTTP && config.PullMethod != PullMethodSSH {
+		return fmt.Errorf("%v.pull_method must be either \"%v\" or \"%v\"", prefix, PullMethodHTTP, PullMethodSSH)
 	}
 
 	// parse archive_handing
-	if config.Gitea.ArchivedRepoHandling != ArchivedProjectShow && config.Gitea.ArchivedRepoHandling != ArchivedProjectHide && config.Gitea.ArchivedRepoHandling != ArchivedProjectIgnore {
-		return nil, fmt.Errorf("gitea.archived_repo_handling must be either \"%v\", \"%v\" or \"%v\"", ArchivedProjectShow, ArchivedProjectHide, ArchivedProjectIgnore)
+	if config.ArchivedRepoHandling != ArchivedProjectShow && config.ArchivedRepoHandling != ArchivedProjectHide && config.ArchivedRepoHandling != ArchivedProjectIgnore {
+		return fmt.Errorf("%v.archived_repo_handling must be either \"%v\", \"%v\" or \"%v\"", prefix, ArchivedProjectShow, ArchivedProjectHide, ArchivedProjectIgnore)
 	}
 
-	return &config.Gitea, nil
+	// parse fork_handling
+	if err := validateForkHandling(prefix, config.ForkHandling); err != nil {
+		return err
+	}
+
+	// parse mirror_handling
+	// an empty mirror_handling is treated like show
+	if config.MirrorHandling != "" && config.MirrorHandling != MirrorShow && config.MirrorHandling != MirrorHide && config.MirrorHandling != MirrorIgnore {
+		return fmt.Errorf("%v.mirror_handling must be either \"%v\", \"%v\" or \"%v\"", prefix, MirrorShow, MirrorHide, MirrorIgnore)
+	}
+
+	// parse org_subdirectories
+	for i, subdirectory := range config.OrgSubdirectories {
+		if subdirectory != OrgSubdirectoryTeams {
+			return fmt.Errorf("%v.org_subdirectories[%v] must be \"%v\"", prefix, i, OrgSubdirectoryTeams)
+		}
+	}
+
+	// parse filters
+	if err := validateFilterConfig(prefix, &config.Filters); err != nil {
+		return err
+	}
+	if err := validateForkFilter(prefix, config.ForkHandling, config.Filters.Forks); err != nil {
+		return err
+	}
+
+	// parse cache_ttl
+	if err := validateCacheTTL(prefix, config.CacheTTL, config.CacheTTLOverrides); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func MakeBitbucketConfig(config *Config) (*BitbucketClientConfig, error) {
+	if err := validateBitbucketConfig("bitbucket", &config.Bitbucket); err != nil {
+		return nil, err
+	}
+	return &config.Bitbucket, nil
+}
+
+func validateBitbucketConfig(prefix string, config *BitbucketClientConfig) error {
+	// parse edition
+	if config.Edition != BitbucketCloud && config.Edition != BitbucketDataCenter {
+		return fmt.Errorf("%v.edition must be either \"%v\" or \"%v\"", prefix, BitbucketCloud, BitbucketDataCenter)
+	}
+	if config.Edition == BitbucketCloud && len(config.ProjectKeys) > 0 {
+		return fmt.Errorf("%v.project_keys is only supported by \"%v\", use workspace_names instead", prefix, BitbucketDataCenter)
+	}
+	if config.Edition == BitbucketDataCenter && len(config.WorkspaceNames) > 0 {
+		return fmt.Errorf("%v.workspace_names is only supported by \"%v\", use project_keys instead", prefix, BitbucketCloud)
+	}
+	if config.Edition == BitbucketDataCenter && (config.URL == "" || strings.TrimSuffix(config.URL, "/") == BitbucketCloudURL) {
+		return fmt.Errorf("%v.url must be set to the url of the Bitbucket Data Center server when %v.edition is \"%v\"", prefix, prefix, BitbucketDataCenter)
+	}
+
+	// parse pull_method
+	if config.PullMethod != PullMethodHTTP && config.PullMethod != PullMethodSSH {
+		return fmt.Errorf("%v.pull_method must be either \"%v\" or \"%v\"", prefix, PullMethodHTTP, PullMethodSSH)
+	}
+
+	// parse archive_handing
+	if config.ArchivedRepoHandling != ArchivedProjectShow && config.ArchivedRepoHandling != ArchivedProjectHide && config.ArchivedRepoHandling != ArchivedProjectIgnore {
+		return fmt.Errorf("%v.archived_repo_handling must be either \"%v\", \"%v\" or \"%v\"", prefix, ArchivedProjectShow, ArchivedProjectHide, ArchivedProjectIgnore)
+	}
+
+	// parse cache_ttl
+	if err := validateCacheTTL(prefix, config.CacheTTL, config.CacheTTLOverrides); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func MakeManifestConfig(config *Config) (*ManifestClientConfig, error) {
+	if err := validateManifestConfig("manifest", &config.Manifest); err != nil {
+		return nil, err
+	}
+	return &config.Manifest, nil
+}
+
+func validateManifestConfig(prefix string, config *ManifestClientConfig) error {
+	// parse path
+	if config.Path == "" {
+		return fmt.Errorf("%v.path must be set", prefix)
+	}
+
+	// parse archive_handing
+	if config.ArchivedRepoHandling != ArchivedProjectShow && config.ArchivedRepoHandling != ArchivedProjectHide && config.ArchivedRepoHandling != ArchivedProjectIgnore {
+		return fmt.Errorf("%v.archived_repo_handling must be either \"%v\", \"%v\" or \"%v\"", prefix, ArchivedProjectShow, ArchivedProjectHide, ArchivedProjectIgnore)
+	}
+
+	// parse poll_interval
+	if config.PollInterval < 0 {
+		return fmt.Errorf("%v.poll_interval must be a positive duration or 0", prefix)
+	}
+
+	return nil
+}
+
+func validateOnClone(prefix string, onClone string, sparseCheckout []string) error {
+	switch onClone {
+	case OnCloneInit, OnCloneClone, OnCloneBlobless, OnCloneTreeless, OnCloneSingleBranch:
+		return nil
+	case OnCloneSparse:
+		if len(sparseCheckout) == 0 {
+			return fmt.Errorf("%v.sparse_checkout must be set when %v.on_clone is \"%v\"", prefix, prefix, OnCloneSparse)
+		}
+		return nil
+	}
+	return fmt.Errorf("%v.on_clone must be either \"%v\", \"%v\", \"%v\", \"%v\", \"%v\" or \"%v\"", prefix, OnCloneInit, OnCloneClone, OnCloneBlobless, OnCloneTreeless, OnCloneSparse, OnCloneSingleBranch)
+}
+
+func validateForkHandling(prefix string, forkHandling string) error {
+	// an empty fork_handling is treated like show
+	if forkHandling != "" && forkHandling != ForkShow && forkHandling != ForkHide && forkHandling != ForkIgnore {
+		return fmt.Errorf("%v.fork_handling must be either \"%v\", \"%v\" or \"%v\"", prefix, ForkShow, ForkHide, ForkIgnore)
+	}
+	return nil
+}
+
+// validateForkFilter rejects the combinations of fork_handling and filters.forks
+// that contradict each other. filters.forks selects which repositories are
+// exposed, then fork_handling sets how the selected forks are shown.
+func validateForkFilter(prefix string, forkHandling string, forks string) error {
+	switch {
+	case forks == ForksExclude && forkHandling != "" && forkHandling != ForkShow:
+		return fmt.Errorf("%v.fork_handling must be \"%v\" when %v.filters.forks is \"%v\"", prefix, ForkShow, prefix, ForksExclude)
+	case forks == ForksOnly && forkHandling == ForkIgnore:
+		return fmt.Errorf("%v.fork_handling cannot be \"%v\" when %v.filters.forks is \"%v\"", prefix, ForkIgnore, prefix, ForksOnly)
+	}
+	return nil
+}
+
+func validateFilterConfig(prefix string, config *FilterConfig) error {
+	for i, pattern := range config.Include {
+		if _, err := utils.CompilePattern(pattern); err != nil {
+			return fmt.Errorf("%v.filters.include[%v] is invalid: %v", prefix, i, err)
+		}
+	}
+	for i, pattern := range config.Exclude {
+		if _, err := utils.CompilePattern(pattern); err != nil {
+			return fmt.Errorf("%v.filters.exclude[%v] is invalid: %v", prefix, i, err)
+		}
+	}
+	for i, visibility := range config.Visibility {
+		if visibility != "public" && visibility != "internal" && visibility != "private" {
+			return fmt.Errorf("%v.filters.visibility[%v] must be either \"public\", \"internal\" or \"private\"", prefix, i)
+		}
+	}
+	// an empty forks is treated like include
+	if config.Forks != "" && config.Forks != ForksInclude && config.Forks != ForksExclude && config.Forks != ForksOnly {
+		return fmt.Errorf("%v.filters.forks must be either \"%v\", \"%v\" or \"%v\"", prefix, ForksInclude, ForksExclude, ForksOnly)
+	}
+	return nil
+}
+
+func validateCacheTTL(prefix string, cacheTTL time.Duration, cacheTTLOverrides map[string]time.Duration) error {
+	if cacheTTL < 0 {
+		return fmt.Errorf("%v.cache_ttl must be a positive duration or 0", prefix)
+	}
+	for name, ttl := range cacheTTLOverrides {
+		if ttl < 0 {
+			return fmt.Errorf("%v.cache_ttl_overrides.%v must be a positive duration or 0", prefix, name)
+		}
+	}
+	return nil
 }
 
 func MakeGitConfig(config *Config) (*GitClientConfig, error) {
 	// parse on_clone
-	if config.Git.OnClone != "init" && config.Git.OnClone != "clone" {
-		return nil, fmt.Errorf("git.on_clone must be either \"init\" or \"clone\"")
+	if err := validateOnClone("git", config.Git.OnClone, config.Git.SparseCheckout); err != nil {
+		return nil, err
+	}
+
+	// parse rules
+	for i, rule := range config.Git.Rules {
+		prefix := fmt.Sprintf("git.rules[%v]", i)
+		if rule.Path == "" {
+			return nil, fmt.Errorf("%v.path must be set", prefix)
+		}
+		if _, err := utils.CompilePattern(rule.Path); err != nil {
+			return nil, fmt.Errorf("%v.path is invalid: %v", prefix, err)
+		}
+		if rule.OnClone != "" {
+			sparseCheckout := rule.SparseCheckout
+			if len(sparseCheckout) == 0 {
+				sparseCheckout = config.Git.SparseCheckout
+			}
+			if err := validateOnClone(prefix, rule.OnClone, sparseCheckout); err != nil {
+				return nil, err
+			}
+		}
+		if rule.Depth != nil && *rule.Depth < 0 {
+			return nil, fmt.Errorf("%v.depth must be a positive number or 0", prefix)
+		}
+		for key := range rule.Config {
+			// git config keys are made of a section and a name, eg: "user.email"
+			if !strings.Contains(strings.Trim(key, "."), ".") || strings.ContainsAny(key, " \t\n=") {
+				return nil, fmt.Errorf("%v.config has an invalid key \"%v\"", prefix, key)
+			}
+		}
+	}
+
+	// parse layout
+	if config.Git.Layout != LayoutID && config.Git.Layout != LayoutPath {
+		return nil, fmt.Errorf("git.layout must be either \"%v\" or \"%v\"", LayoutID, LayoutPath)
+	}
+
+	// parse clone_wait_timeout
+	if config.Git.CloneWaitTimeout < 0 {
+		return nil, fmt.Errorf("git.clone_wait_timeout must be a positive duration or 0")
+	}
+
+	// parse gc_interval
+	if config.Git.GCInterval < 0 {
+		return nil, fmt.Errorf("git.gc_interval must be a positive duration or 0")
+	}
+
+	// parse gc_action
+	if config.Git.GCAction != "" && config.Git.GCAction != GCActionReport && config.Git.GCAction != GCActionDelete && config.Git.GCAction != GCActionArchive {
+		return nil, fmt.Errorf("git.gc_action must be either \"%v\", \"%v\" or \"%v\"", GCActionReport, GCActionDelete, GCActionArchive)
+	}
+
+	// parse max_size
+	if config.Git.MaxSize < 0 {
+		return nil, fmt.Errorf("git.max_size must be a positive size or 0")
 	}
 
 	return &config.Git, nil
@@ -3,6 +3,7 @@ package config_test
 import (
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/badjware/gitforgefs/config"
 )
@@ -16,18 +17,32 @@ func TestLoadConfig(t *testing.T) {
 			input: "config.test.yaml",
 			expected: &config.Config{
 				FS: config.FSConfig{
-					Mountpoint:   "/tmp/gitforgefs/test/mnt/gitlab",
-					MountOptions: "nodev",
-					Forge:        "gitlab",
+					Mountpoint:    "/tmp/gitforgefs/test/mnt/gitlab",
+					MountOptions:  "nodev",
+					Forge:         "gitlab",
+					PersistCache:  true,
+					MetadataFiles: true,
+					ControlSocket: "/tmp/gitforgefs/test/control.sock",
 				},
 				Gitlab: config.GitlabClientConfig{
-					URL:                     "https://example.com",
-					Token:                   "12345",
-					PullMethod:              "ssh",
-					GroupIDs:                []int{123},
-					UserNames:               []int{456},
-					ArchivedProjectHandling: "hide",
-					IncludeCurrentUser:      true,
+					URL:                       "https://example.com",
+					Token:                     "12345",
+					PullMethod:                "ssh",
+					GroupIDs:                  []int{123},
+					UserNames:                 []string{"test-user"},
+					ArchivedProjectHandling:   "hide",
+					ForkHandling:              "hide",
+					IncludeCurrentUser:        true,
+					CurrentUserSubdirectories: []string{"groups", "starred"},
+					Filters: config.FilterConfig{
+						Include:    []string{"test-group/**"},
+						Exclude:    []string{"re:^test-group/archive(/|$)"},
+						Topics:     []string{},
+						Visibility: []string{"internal", "private"},
+						Forks:      "only",
+					},
+					CacheTTL:          time.Hour,
+					CacheTTLOverrides: map[string]time.Duration{"test-user": 10 * time.Minute},
 				},
 				Github: config.GithubClientConfig{
 					Token:                "12345",
@@ -35,7 +50,16 @@ func TestLoadConfig(t *testing.T) {
 					OrgNames:             []string{"test-org"},
 					UserNames:            []string{"test-user"},
 					ArchivedRepoHandling: "hide",
+					ForkHandling:         "show",
 					IncludeCurrentUser:   true,
+					OrgSubdirectories:    []string{"teams", "topics"},
+					Filters: config.FilterConfig{
+						Include:    []string{},
+						Exclude:    []string{},
+						Topics:     []string{},
+						Visibility: []string{},
+						Forks:      "include",
+					},
 				},
 				Gitea: config.GiteaClientConfig{
 					URL:                  "https://example.com",
@@ -44,16 +68,56 @@ func TestLoadConfig(t *testing.T) {
 					OrgNames:             []string{"test-org"},
 					UserNames:            []string{"test-user"},
 					ArchivedRepoHandling: "hide",
+					ForkHandling:         "show",
+					MirrorHandling:       "ignore",
 					IncludeCurrentUser:   true,
+					OrgSubdirectories:    []string{"teams"},
+					Filters: config.FilterConfig{
+						Include:    []string{},
+						Exclude:    []string{},
+						Topics:     []string{},
+						Visibility: []string{},
+						Forks:      "include",
+					},
+				},
+				Bitbucket: config.BitbucketClientConfig{
+					URL:                  "https://bitbucket.example.com",
+					Edition:              "datacenter",
+					Username:             "test-user",
+					Token:                "12345",
+					PullMethod:           "ssh",
+					WorkspaceNames:       []string{},
+					ProjectKeys:          []string{"TEST"},
+					UserNames:            []string{"test-user"},
+					ArchivedRepoHandling: "ignore",
+					IncludeCurrentUser:   false,
+				},
+				Manifest: config.ManifestClientConfig{
+					Path:                 "/tmp/gitforgefs/test/manifest.yaml",
+					ArchivedRepoHandling: "show",
+					PollInterval:         time.Minute,
 				},
 				Git: config.GitClientConfig{
 					CloneLocation:    "/tmp/gitforgefs/test/cache/gitlab",
 					Remote:           "origin",
 					OnClone:          "clone",
+					SparseCheckout:   []string{"docs"},
+					Layout:           "path",
 					AutoPull:         false,
 					Depth:            0,
 					QueueSize:        100,
 					QueueWorkerCount: 1,
+					GCInterval:       24 * time.Hour,
+					GCAction:         "archive",
+					MaxSize:          20 << 30,
+					CredentialHelper: true,
+					Rules: []config.GitRule{
+						{Path: "test-group/monorepo", OnClone: "sparse"},
+						{Path: "test-group/large/**", OnClone: "blobless", Depth: pointer(1), AutoPull: pointer(true), Config: map[string]string{"user.email": "test@example.com"}},
+					},
+				},
+				Metrics: config.MetricsConfig{
+					Listen: "127.0.0.1:9100",
 				}},
 		},
 	}
@@ -71,6 +135,89 @@ func TestLoadConfig(t *testing.T) {
 	}
 }
 
+// gitlabConfig returns the default gitlab configuration, modified by f
+func gitlabConfig(f func(c *config.GitlabClientConfig)) config.GitlabClientConfig {
+	c := config.GitlabClientConfig{
+		URL:                     "https://gitlab.com",
+		Token:                   "",
+		PullMethod:              "http",
+		GroupIDs:                []int{9970},
+		UserNames:               []string{},
+		ArchivedProjectHandling: "hide",
+		IncludeCurrentUser:      true,
+	}
+	if f != nil {
+		f(&c)
+	}
+	return c
+}
+
+// githubConfig returns the default github configuration, modified by f
+func githubConfig(f func(c *config.GithubClientConfig)) config.GithubClientConfig {
+	c := config.GithubClientConfig{
+		Token:                "",
+		PullMethod:           "http",
+		OrgNames:             []string{},
+		UserNames:            []string{},
+		ArchivedRepoHandling: "hide",
+		IncludeCurrentUser:   true,
+	}
+	if f != nil {
+		f(&c)
+	}
+	return c
+}
+
+// giteaConfig returns the default gitea configuration, modified by f
+func giteaConfig(f func(c *config.GiteaClientConfig)) config.GiteaClientConfig {
+	c := config.GiteaClientConfig{
+		URL:                  "https://gitea.com",
+		Token:                "",
+		PullMethod:           "http",
+		OrgNames:             []string{},
+		UserNames:            []string{},
+		ArchivedRepoHandling: "hide",
+		IncludeCurrentUser:   true,
+	}
+	if f != nil {
+		f(&c)
+	}
+	return c
+}
+
+// bitbucketConfig returns the default bitbucket configuration, modified by f
+func bitbucketConfig(f func(c *config.BitbucketClientConfig)) config.BitbucketClientConfig {
+	c := config.BitbucketClientConfig{
+		URL:                  "https://api.bitbucket.org/2.0",
+		Edition:              "cloud",
+		Username:             "",
+		Token:                "",
+		PullMethod:           "http",
+		WorkspaceNames:       []string{},
+		ProjectKeys:          []string{},
+		UserNames:            []string{},
+		ArchivedRepoHandling: "hide",
+		IncludeCurrentUser:   true,
+	}
+	if f != nil {
+		f(&c)
+	}
+	return c
+}
+
+// manifestConfig returns the default manifest configuration, modified by f
+func manifestConfig(f func(c *config.ManifestClientConfig)) config.ManifestClientConfig {
+	c := config.ManifestClientConfig{
+		Path:                 "",
+		ArchivedRepoHandling: "hide",
+		PollInterval:         10 * time.Second,
+	}
+	if f != nil {
+		f(&c)
+	}
+	return c
+}
+
 func TestMakeGitConfig(t *testing.T) {
 	tests := map[string]struct {
 		input    *config.Config
@@ -85,20 +232,26 @@ func TestMakeGitConfig(t *testing.T) {
 					CloneLocation:    "/tmp",
 					Remote:           "origin",
 					OnClone:          "init",
+					Layout:           "path",
 					AutoPull:         false,
 					Depth:            0,
 					QueueSize:        200,
 					QueueWorkerCount: 5,
+					GCInterval:       time.Hour,
+					GCAction:         "delete",
 				},
 			},
 			expected: &config.GitClientConfig{
 				CloneLocation:    "/tmp",
 				Remote:           "origin",
 				OnClone:          "init",
+				Layout:           "path",
 				AutoPull:         false,
 				Depth:            0,
 				QueueSize:        200,
 				QueueWorkerCount: 5,
+				GCInterval:       time.Hour,
+				GCAction:         "delete",
 			},
 		},
 		"InvalidOnClone": {
@@ -118,6 +271,202 @@ func TestMakeGitConfig(t *testing.T) {
 			},
 			expected: nil,
 		},
+		"Rules": {
+			input: &config.Config{
+				FS: config.FSConfig{
+					Forge: "gitlab",
+				},
+				Git: config.GitClientConfig{
+					CloneLocation: "/tmp",
+					Remote:        "origin",
+					OnClone:       "treeless",
+					Rules: []config.GitRule{
+						{Path: "group/monorepo", OnClone: "sparse", SparseCheckout: []string{"docs"}},
+						{Path: "re:^group/branch-", OnClone: "single-branch", Remote: "upstream", Depth: pointer(0)},
+						{Path: "work/**", Config: map[string]string{"user.email": "me@example.com", "core.hooksPath": "/etc/git/hooks"}},
+					},
+					Layout:           "id",
+					QueueSize:        200,
+					QueueWorkerCount: 5,
+				},
+			},
+			expected: &config.GitClientConfig{
+				CloneLocation: "/tmp",
+				Remote:        "origin",
+				OnClone:       "treeless",
+				Rules: []config.GitRule{
+					{Path: "group/monorepo", OnClone: "sparse", SparseCheckout: []string{"docs"}},
+					{Path: "re:^group/branch-", OnClone: "single-branch", Remote: "upstream", Depth: pointer(0)},
+					{Path: "work/**", Config: map[string]string{"user.email": "me@example.com", "core.hooksPath": "/etc/git/hooks"}},
+				},
+				Layout:           "id",
+				QueueSize:        200,
+				QueueWorkerCount: 5,
+			},
+		},
+		"SparseWithoutSparseCheckout": {
+			input: &config.Config{
+				FS: config.FSConfig{
+					Forge: "gitlab",
+				},
+				Git: config.GitClientConfig{
+					CloneLocation:    "/tmp",
+					Remote:           "origin",
+					OnClone:          "sparse",
+					Layout:           "id",
+					QueueSize:        200,
+					QueueWorkerCount: 5,
+				},
+			},
+			expected: nil,
+		},
+		"InvalidRuleOnClone": {
+			input: &config.Config{
+				FS: config.FSConfig{
+					Forge: "gitlab",
+				},
+				Git: config.GitClientConfig{
+					CloneLocation: "/tmp",
+					Remote:        "origin",
+					OnClone:       "init",
+					Rules: []config.GitRule{
+						{Path: "group/**", OnClone: "invalid"},
+					},
+					Layout:           "id",
+					QueueSize:        200,
+					QueueWorkerCount: 5,
+				},
+			},
+			expected: nil,
+		},
+		"InvalidRulePath": {
+			input: &config.Config{
+				FS: config.FSConfig{
+					Forge: "gitlab",
+				},
+				Git: config.GitClientConfig{
+					CloneLocation: "/tmp",
+					Remote:        "origin",
+					OnClone:       "init",
+					Rules: []config.GitRule{
+						{Path: "re:(", OnClone: "clone"},
+					},
+					Layout:           "id",
+					QueueSize:        200,
+					QueueWorkerCount: 5,
+				},
+			},
+			expected: nil,
+		},
+		"InvalidRuleDepth": {
+			input: &config.Config{
+				FS: config.FSConfig{
+					Forge: "gitlab",
+				},
+				Git: config.GitClientConfig{
+					CloneLocation: "/tmp",
+					Remote:        "origin",
+					OnClone:       "init",
+					Rules: []config.GitRule{
+						{Path: "group/**", Depth: pointer(-1)},
+					},
+					Layout:           "id",
+					QueueSize:        200,
+					QueueWorkerCount: 5,
+				},
+			},
+			expected: nil,
+		},
+		"InvalidRuleConfig": {
+			input: &config.Config{
+				FS: config.FSConfig{
+					Forge: "gitlab",
+				},
+				Git: config.GitClientConfig{
+					CloneLocation: "/tmp",
+					Remote:        "origin",
+					OnClone:       "init",
+					Rules: []config.GitRule{
+						{Path: "group/**", Config: map[string]string{"email": "me@example.com"}},
+					},
+					Layout:           "id",
+					QueueSize:        200,
+					QueueWorkerCount: 5,
+				},
+			},
+			expected: nil,
+		},
+		"InvalidLayout": {
+			input: &config.Config{
+				FS: config.FSConfig{
+					Forge: "gitlab",
+				},
+				Git: config.GitClientConfig{
+					CloneLocation:    "/tmp",
+					Remote:           "origin",
+					OnClone:          "init",
+					Layout:           "invalid",
+					AutoPull:         false,
+					Depth:            0,
+					QueueSize:        200,
+					QueueWorkerCount: 5,
+				},
+			},
+			expected: nil,
+		},
+		"InvalidCloneWaitTimeout": {
+			input: &config.Config{
+				FS: config.FSConfig{
+					Forge: "gitlab",
+				},
+				Git: config.GitClientConfig{
+					CloneLocation:    "/tmp",
+					Remote:           "origin",
+					OnClone:          "init",
+					AutoPull:         false,
+					Depth:            0,
+					QueueSize:        200,
+					QueueWorkerCount: 5,
+					Layout:           "id",
+					CloneWaitTimeout: -time.Second,
+				},
+			},
+			expected: nil,
+		},
+		"InvalidGCInterval": {
+			input: &config.Config{
+				FS: config.FSConfig{
+					Forge: "gitlab",
+				},
+				Git: config.GitClientConfig{
+					CloneLocation:    "/tmp",
+					Remote:           "origin",
+					OnClone:          "init",
+					Layout:           "id",
+					QueueSize:        200,
+					QueueWorkerCount: 5,
+					GCInterval:       -time.Hour,
+				},
+			},
+			expected: nil,
+		},
+		"InvalidGCAction": {
+			input: &config.Config{
+				FS: config.FSConfig{
+					Forge: "gitlab",
+				},
+				Git: config.GitClientConfig{
+					CloneLocation:    "/tmp",
+					Remote:           "origin",
+					OnClone:          "init",
+					Layout:           "id",
+					QueueSize:        200,
+					QueueWorkerCount: 5,
+					GCAction:         "invalid",
+				},
+			},
+			expected: nil,
+		},
 	}
 
 	for name, test := range tests {
@@ -148,7 +497,7 @@ func TestMakeGitlabConfig(t *testing.T) {
 					PullMethod:              "http",
 					Token:                   "",
 					GroupIDs:                []int{9970},
-					UserNames:               []int{},
+					UserNames:               []string{},
 					ArchivedProjectHandling: "hide",
 					IncludeCurrentUser:      true,
 				},
@@ -158,7 +507,7 @@ func TestMakeGitlabConfig(t *testing.T) {
 				PullMethod:              "http",
 				Token:                   "",
 				GroupIDs:                []int{9970},
-				UserNames:               []int{},
+				UserNames:               []string{},
 				ArchivedProjectHandling: "hide",
 				IncludeCurrentUser:      true,
 			},
@@ -173,7 +522,7 @@ func TestMakeGitlabConfig(t *testing.T) {
 					PullMethod:              "invalid",
 					Token:                   "",
 					GroupIDs:                []int{9970},
-					UserNames:               []int{},
+					UserNames:               []string{},
 					ArchivedProjectHandling: "hide",
 					IncludeCurrentUser:      true,
 				},
@@ -187,13 +536,150 @@ func TestMakeGitlabConfig(t *testing.T) {
 					PullMethod:              "http",
 					Token:                   "",
 					GroupIDs:                []int{9970},
-					UserNames:               []int{},
+					UserNames:               []string{},
 					IncludeCurrentUser:      true,
 					ArchivedProjectHandling: "invalid",
 				},
 			},
 			expected: nil,
 		},
+		"InvalidCacheTTL": {
+			input: &config.Config{
+				Gitlab: config.GitlabClientConfig{
+					URL:                     "https://gitlab.com",
+					PullMethod:              "http",
+					Token:                   "",
+					GroupIDs:                []int{9970},
+					UserNames:               []string{},
+					IncludeCurrentUser:      true,
+					ArchivedProjectHandling: "hide",
+					CacheTTL:                -time.Minute,
+				},
+			},
+			expected: nil,
+		},
+		"InvalidCacheTTLOverride": {
+			input: &config.Config{
+				Gitlab: config.GitlabClientConfig{
+					URL:                     "https://gitlab.com",
+					PullMethod:              "http",
+					Token:                   "",
+					GroupIDs:                []int{9970},
+					UserNames:               []string{},
+					IncludeCurrentUser:      true,
+					ArchivedProjectHandling: "hide",
+					CacheTTLOverrides:       map[string]time.Duration{"gitlab-org": -time.Minute},
+				},
+			},
+			expected: nil,
+		},
+		"InvalidForkHandling": {
+			input: &config.Config{
+				Gitlab: config.GitlabClientConfig{
+					URL:                     "https://gitlab.com",
+					PullMethod:              "http",
+					Token:                   "",
+					GroupIDs:                []int{9970},
+					UserNames:               []string{},
+					IncludeCurrentUser:      true,
+					ArchivedProjectHandling: "hide",
+					ForkHandling:            "invalid",
+				},
+			},
+			expected: nil,
+		},
+		"InvalidCurrentUserSubdirectory": {
+			input: &config.Config{
+				Gitlab: config.GitlabClientConfig{
+					URL:                       "https://gitlab.com",
+					PullMethod:                "http",
+					Token:                     "",
+					GroupIDs:                  []int{9970},
+					UserNames:                 []string{},
+					IncludeCurrentUser:        true,
+					CurrentUserSubdirectories: []string{"projects"},
+					ArchivedProjectHandling:   "hide",
+				},
+			},
+			expected: nil,
+		},
+		"InvalidFilterPattern": {
+			input: &config.Config{
+				Gitlab: config.GitlabClientConfig{
+					URL:                     "https://gitlab.com",
+					PullMethod:              "http",
+					Token:                   "",
+					GroupIDs:                []int{9970},
+					UserNames:               []string{},
+					IncludeCurrentUser:      true,
+					ArchivedProjectHandling: "hide",
+					Filters:                 config.FilterConfig{Exclude: []string{"re:gitlab-org/(infra"}},
+				},
+			},
+			expected: nil,
+		},
+		"InvalidFilterVisibility": {
+			input: &config.Config{
+				Gitlab: config.GitlabClientConfig{
+					URL:                     "https://gitlab.com",
+					PullMethod:              "http",
+					Token:                   "",
+					GroupIDs:                []int{9970},
+					UserNames:               []string{},
+					IncludeCurrentUser:      true,
+					ArchivedProjectHandling: "hide",
+					Filters:                 config.FilterConfig{Visibility: []string{"secret"}},
+				},
+			},
+			expected: nil,
+		},
+		"ConflictingForkHandlingExclude": {
+			input: &config.Config{
+				Gitlab: config.GitlabClientConfig{
+					URL:                     "https://gitlab.com",
+					PullMethod:              "http",
+					Token:                   "",
+					GroupIDs:                []int{9970},
+					UserNames:               []string{},
+					IncludeCurrentUser:      true,
+					ArchivedProjectHandling: "hide",
+					ForkHandling:            "hide",
+					Filters:                 config.FilterConfig{Forks: "exclude"},
+				},
+			},
+			expected: nil,
+		},
+		"ConflictingForkHandlingOnly": {
+			input: &config.Config{
+				Gitlab: config.GitlabClientConfig{
+					URL:                     "https://gitlab.com",
+					PullMethod:              "http",
+					Token:                   "",
+					GroupIDs:                []int{9970},
+					UserNames:               []string{},
+					IncludeCurrentUser:      true,
+					ArchivedProjectHandling: "hide",
+					ForkHandling:            "ignore",
+					Filters:                 config.FilterConfig{Forks: "only"},
+				},
+			},
+			expected: nil,
+		},
+		"InvalidFilterForks": {
+			input: &config.Config{
+				Gitlab: config.GitlabClientConfig{
+					URL:                     "https://gitlab.com",
+					PullMethod:              "http",
+					Token:                   "",
+					GroupIDs:                []int{9970},
+					UserNames:               []string{},
+					IncludeCurrentUser:      true,
+					ArchivedProjectHandling: "hide",
+					Filters:                 config.FilterConfig{Forks: "invalid"},
+				},
+			},
+			expected: nil,
+		},
 	}
 
 	for name, test := range tests {
@@ -208,3 +694,289 @@ func TestMakeGitlabConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestMakeForgeConfigs(t *testing.T) {
+	tests := map[string]struct {
+		input    *config.Config
+		expected []config.ForgeConfig
+	}{
+		"SingleForge": {
+			input: &config.Config{
+				FS: config.FSConfig{
+					Forge: "github",
+				},
+				Gitlab: gitlabConfig(nil),
+				Github: githubConfig(nil),
+				Gitea:  giteaConfig(nil),
+			},
+			expected: []config.ForgeConfig{
+				{
+					Name:   "github",
+					Type:   "github",
+					Gitlab: gitlabConfig(nil),
+					Github: githubConfig(nil),
+					Gitea:  giteaConfig(nil),
+				},
+			},
+		},
+		"InvalidSingleForge": {
+			input: &config.Config{
+				FS: config.FSConfig{
+					Forge: "github",
+				},
+				Github: githubConfig(func(c *config.GithubClientConfig) { c.PullMethod = "invalid" }),
+			},
+			expected: nil,
+		},
+		"MultipleForges": {
+			input: &config.Config{
+				Forges: []config.ForgeConfig{
+					{Name: "gitlab.com", Type: "gitlab", Gitlab: gitlabConfig(nil)},
+					{Name: "work", Type: "gitlab", Gitlab: gitlabConfig(nil)},
+				},
+			},
+			expected: []config.ForgeConfig{
+				{Name: "gitlab.com", Type: "gitlab", Gitlab: gitlabConfig(nil)},
+				{Name: "work", Type: "gitlab", Gitlab: gitlabConfig(nil)},
+			},
+		},
+		"DuplicateName": {
+			input: &config.Config{
+				Forges: []config.ForgeConfig{
+					{Name: "gitlab", Type: "gitlab", Gitlab: gitlabConfig(nil)},
+					{Name: "gitlab", Type: "gitlab", Gitlab: gitlabConfig(nil)},
+				},
+			},
+			expected: nil,
+		},
+		"InvalidName": {
+			input: &config.Config{
+				Forges: []config.ForgeConfig{
+					{Name: "gitlab/work", Type: "gitlab", Gitlab: gitlabConfig(nil)},
+				},
+			},
+			expected: nil,
+		},
+		"InvalidType": {
+			input: &config.Config{
+				Forges: []config.ForgeConfig{
+					{Name: "gitlab", Type: "invalid", Gitlab: gitlabConfig(nil)},
+				},
+			},
+			expected: nil,
+		},
+		"InvalidForgeConfig": {
+			input: &config.Config{
+				Forges: []config.ForgeConfig{
+					{Name: "gitlab", Type: "gitlab", Gitlab: gitlabConfig(func(c *config.GitlabClientConfig) { c.ArchivedProjectHandling = "invalid" })},
+				},
+			},
+			expected: nil,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			got, err := config.MakeForgeConfigs(test.input)
+			expected := test.expected
+			if !reflect.DeepEqual(got, expected) {
+				t.Fatalf("MakeForgeConfigs(%v) returned %v; expected %v; error: %v", test.input, got, expected, err)
+			}
+		})
+	}
+}
+
+func TestMakeBitbucketConfig(t *testing.T) {
+	tests := map[string]struct {
+		input    *config.Config
+		expected *config.BitbucketClientConfig
+	}{
+		"ValidConfig": {
+			input: &config.Config{
+				Bitbucket: bitbucketConfig(func(c *config.BitbucketClientConfig) { c.WorkspaceNames = []string{"test-workspace"} }),
+			},
+			expected: func() *config.BitbucketClientConfig {
+				c := bitbucketConfig(func(c *config.BitbucketClientConfig) { c.WorkspaceNames = []string{"test-workspace"} })
+				return &c
+			}(),
+		},
+		"InvalidEdition": {
+			input: &config.Config{
+				Bitbucket: bitbucketConfig(func(c *config.BitbucketClientConfig) { c.Edition = "invalid" }),
+			},
+			expected: nil,
+		},
+		"ProjectKeysOnCloud": {
+			input: &config.Config{
+				Bitbucket: bitbucketConfig(func(c *config.BitbucketClientConfig) { c.ProjectKeys = []string{"TEST"} }),
+			},
+			expected: nil,
+		},
+		"WorkspaceNamesOnDataCenter": {
+			input: &config.Config{
+				Bitbucket: bitbucketConfig(func(c *config.BitbucketClientConfig) {
+					c.URL = "https://bitbucket.example.com"
+					c.Edition = "datacenter"
+					c.WorkspaceNames = []string{"test-workspace"}
+				}),
+			},
+			expected: nil,
+		},
+		"DataCenterWithoutURL": {
+			input: &config.Config{
+				Bitbucket: bitbucketConfig(func(c *config.BitbucketClientConfig) {
+					c.Edition = "datacenter"
+					c.ProjectKeys = []string{"TEST"}
+				}),
+			},
+			expected: nil,
+		},
+		"ValidDataCenterConfig": {
+			input: &config.Config{
+				Bitbucket: bitbucketConfig(func(c *config.BitbucketClientConfig) {
+					c.URL = "https://bitbucket.example.com"
+					c.Edition = "datacenter"
+					c.ProjectKeys = []string{"TEST"}
+				}),
+			},
+			expected: func() *config.BitbucketClientConfig {
+				c := bitbucketConfig(func(c *config.BitbucketClientConfig) {
+					c.URL = "https://bitbucket.example.com"
+					c.Edition = "datacenter"
+					c.ProjectKeys = []string{"TEST"}
+				})
+				return &c
+			}(),
+		},
+		"InvalidPullMethod": {
+			input: &config.Config{
+				Bitbucket: bitbucketConfig(func(c *config.BitbucketClientConfig) { c.PullMethod = "invalid" }),
+			},
+			expected: nil,
+		},
+		"InvalidArchiveHandling": {
+			input: &config.Config{
+				Bitbucket: bitbucketConfig(func(c *config.BitbucketClientConfig) { c.ArchivedRepoHandling = "invalid" }),
+			},
+			expected: nil,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			got, err := config.MakeBitbucketConfig(test.input)
+			expected := test.expected
+			if !reflect.DeepEqual(got, expected) {
+				t.Fatalf("MakeBitbucketConfig(%v) returned %v; expected %v; error: %v", test.input, got, expected, err)
+			}
+		})
+	}
+}
+
+func TestMakeManifestConfig(t *testing.T) {
+	tests := map[string]struct {
+		input    *config.Config
+		expected *config.ManifestClientConfig
+	}{
+		"ValidConfig": {
+			input: &config.Config{
+				Manifest: manifestConfig(func(c *config.ManifestClientConfig) { c.Path = "manifest.yaml" }),
+			},
+			expected: func() *config.ManifestClientConfig {
+				c := manifestConfig(func(c *config.ManifestClientConfig) { c.Path = "manifest.yaml" })
+				return &c
+			}(),
+		},
+		"MissingPath": {
+			input: &config.Config{
+				Manifest: manifestConfig(nil),
+			},
+			expected: nil,
+		},
+		"InvalidArchiveHandling": {
+			input: &config.Config{
+				Manifest: manifestConfig(func(c *config.ManifestClientConfig) {
+					c.Path = "manifest.yaml"
+					c.ArchivedRepoHandling = "invalid"
+				}),
+			},
+			expected: nil,
+		},
+		"InvalidPollInterval": {
+			input: &config.Config{
+				Manifest: manifestConfig(func(c *config.ManifestClientConfig) {
+					c.Path = "manifest.yaml"
+					c.PollInterval = -time.Second
+				}),
+			},
+			expected: nil,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			got, err := config.MakeManifestConfig(test.input)
+			expected := test.expected
+			if !reflect.DeepEqual(got, expected) {
+				t.Fatalf("MakeManifestConfig(%v) returned %v; expected %v; error: %v", test.input, got, expected, err)
+			}
+		})
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	tests := map[string]struct {
+		input    string
+		expected config.ByteSize
+	}{
+		"Bytes": {
+			input:    "1024",
+			expected: 1024,
+		},
+		"Decimal": {
+			input:    "500MB",
+			expected: 500_000_000,
+		},
+		"Binary": {
+			input:    "20 GiB",
+			expected: 20 << 30,
+		},
+		"Fraction": {
+			input:    "1.5kib",
+			expected: 1536,
+		},
+		"InvalidUnit": {
+			input:    "20GB/s",
+			expected: -1,
+		},
+		"InvalidNumber": {
+			input:    "GB",
+			expected: -1,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			got, err := config.ParseByteSize(test.input)
+			if err != nil {
+				got = -1
+			}
+			if got != test.expected {
+				t.Fatalf("ParseByteSize(%v) returned %v; expected %v; error: %v", test.input, got, test.expected, err)
+			}
+		})
+	}
+}
+
+// pointer returns a pointer to v, for the optional settings
+func pointer[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,151 @@
+package control
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	CommandStatus  = "status"
+	CommandRefresh = "refresh"
+	CommandPull    = "pull"
+	CommandClone   = "clone"
+	CommandQueue   = "queue"
+	CommandUnmount = "unmount"
+	CommandGC      = "gc"
+
+	// The time a client has to send its request, and then to read the response
+	requestTimeout = 5 * time.Second
+)
+
+// Request is a command sent to a running filesystem through its control socket
+type Request struct {
+	Command string `json:"command"`
+	Path    string `json:"path,omitempty"`
+	// what to do with the orphaned local clones, for the gc command
+	Action string `json:"action,omitempty"`
+}
+
+// Response is the outcome of a command
+type Response struct {
+	Output string `json:"output,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Handler executes a command and returns its output
+type Handler func(request Request) (string, error)
+
+// IsCommand returns whether name is a command supported by the control socket
+func IsCommand(name string) bool {
+	switch name {
+	case CommandStatus, CommandRefresh, CommandPull, CommandClone, CommandQueue, CommandUnmount, CommandGC:
+		return true
+	}
+	return false
+}
+
+// Serve listens on the unix socket at socketPath and executes the commands it receives with handler.
+// It returns a function to stop listening, which waits for the commands in progress to complete.
+func Serve(logger *slog.Logger, socketPath string, handler Handler) (stop func(), err error) {
+	if err := os.MkdirAll(filepath.Dir(socketPath), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create directory of control socket %v: %v", socketPath, err)
+	}
+	// a socket left behind by a previous run prevents listening, unless another instance is still using it
+	if _, err := os.Stat(socketPath); err == nil {
+		if conn, err := net.Dial("unix", socketPath); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("control socket %v is already in use", socketPath)
+		}
+		if err := os.Remove(socketPath); err != nil {
+			return nil, fmt.Errorf("failed to remove stale control socket %v: %v", socketPath, err)
+		}
+	}
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on control socket %v: %v", socketPath, err)
+	}
+	// only the owner of the filesystem may control it
+	if err := os.Chmod(socketPath, 0600); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to set permissions of control socket %v: %v", socketPath, err)
+	}
+	logger.Info("Listening on control socket", "path", socketPath)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					logger.Error("Failed to accept connection on control socket", "error", err)
+				}
+				return
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				handleConnection(logger, conn, handler)
+			}()
+		}
+	}()
+
+	return func() {
+		listener.Close()
+		wg.Wait()
+	}, nil
+}
+
+func handleConnection(logger *slog.Logger, conn net.Conn, handler Handler) {
+	defer conn.Close()
+
+	// a client that never sends its request must not hold the connection, stopping waits for it
+	conn.SetReadDeadline(time.Now().Add(requestTimeout))
+	var request Request
+	if err := json.NewDecoder(conn).Decode(&request); err != nil {
+		logger.Warn("Failed to read request from control socket", "error", err)
+		return
+	}
+	logger.Debug("Received command", "command", request.Command, "path", request.Path)
+
+	var response Response
+	output, err := handler(request)
+	response.Output = output
+	if err != nil {
+		response.Error = err.Error()
+	}
+	conn.SetWriteDeadline(time.Now().Add(requestTimeout))
+	if err := json.NewEncoder(conn).Encode(response); err != nil {
+		logger.Warn("Failed to write response to control socket", "error", err)
+	}
+}
+
+// Send sends the request to the filesystem listening on the unix socket at socketPath and returns the output of the command
+func Send(socketPath string, request Request) (string, error) {
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to control socket %v, is the filesystem mounted? %v", socketPath, err)
+	}
+	defer conn.Close()
+
+	if err := json.NewEncoder(conn).Encode(request); err != nil {
+		return "", fmt.Errorf("failed to send request to control socket: %v", err)
+	}
+	var response Response
+	if err := json.NewDecoder(conn).Decode(&response); err != nil {
+		return "", fmt.Errorf("failed to read response from control socket: %v", err)
+	}
+	if response.Error != "" {
+		return response.Output, errors.New(response.Error)
+	}
+	return response.Output, nil
+}
@@ -0,0 +1,88 @@
+package control_test
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/badjware/gitforgefs/control"
+)
+
+func TestSend(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "control.sock")
+	stop, err := control.Serve(slog.Default(), socketPath, func(request control.Request) (string, error) {
+		switch request.Command {
+		case control.CommandRefresh:
+			return "Refreshed " + request.Path + "\n", nil
+		case control.CommandUnmount:
+			return "", errors.New("device or resource busy")
+		}
+		return "", errors.New("unknown command")
+	})
+	if err != nil {
+		t.Fatalf("Serve() returned error: %v", err)
+	}
+	t.Cleanup(stop)
+
+	// a second instance must not steal the socket of the first one
+	if _, err := control.Serve(slog.Default(), socketPath, nil); err == nil {
+		t.Fatalf("Serve() on a socket in use returned no error")
+	}
+
+	tests := map[string]struct {
+		input    control.Request
+		expected control.Response
+	}{
+		"Output": {
+			input:    control.Request{Command: control.CommandRefresh, Path: "/mnt/group"},
+			expected: control.Response{Output: "Refreshed /mnt/group\n"},
+		},
+		"Error": {
+			input:    control.Request{Command: control.CommandUnmount},
+			expected: control.Response{Error: "device or resource busy"},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var got control.Response
+			output, err := control.Send(socketPath, test.input)
+			got.Output = output
+			if err != nil {
+				got.Error = err.Error()
+			}
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Fatalf("Send(%v) returned %v; expected %v", test.input, got, test.expected)
+			}
+		})
+	}
+}
+
+func TestIdleConnection(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "control.sock")
+	stop, err := control.Serve(slog.Default(), socketPath, func(request control.Request) (string, error) {
+		return "", nil
+	})
+	if err != nil {
+		t.Fatalf("Serve() returned error: %v", err)
+	}
+	t.Cleanup(stop)
+
+	// a client that never sends its request is disconnected, so that stopping doesn't wait for it
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		t.Fatalf("Dial() returned error: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(30 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("Read() returned error %v; expected %v", err, io.EOF)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/badjware/gitforgefs/config"
+	"github.com/badjware/gitforgefs/git"
+)
+
+const (
+	// The command git runs to get the credentials of the forges
+	credentialCommand = "credential"
+)
+
+// makeGitClientConfig returns the configuration of the git client, with the credential helper pointing to this executable
+func makeGitClientConfig(loadedConfig *config.Config, configPath string) (*config.GitClientConfig, error) {
+	gitClientParam, err := config.MakeGitConfig(loadedConfig)
+	if err != nil {
+		return nil, err
+	}
+	if gitClientParam.CredentialHelper {
+		command, err := credentialHelperCommand(configPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure the credential helper: %v", err)
+		}
+		gitClientParam.CredentialHelperCommand = command
+	}
+	return gitClientParam, nil
+}
+
+// credentialHelperCommand returns the value of credential.helper running this executable with the config file at configPath
+func credentialHelperCommand(configPath string) (string, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	absoluteConfigPath, err := filepath.Abs(configPath)
+	if err != nil {
+		return "", err
+	}
+	// git runs the helper with a shell when it starts with "!"
+	return fmt.Sprintf("!%v -config %v %v", shellQuote(executable), shellQuote(absoluteConfigPath), credentialCommand), nil
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// runCredentialHelper answers the request of git for the credentials of a forge, see gitcredentials(7)
+func runCredentialHelper(loadedConfig *config.Config, args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "%s requires a single operation\n", credentialCommand)
+		return 2
+	}
+	forgeConfigs, err := config.MakeForgeConfigs(loadedConfig)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := git.ServeCredential(args[0], os.Stdin, os.Stdout, forgeCredentials(forgeConfigs)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// forgeCredentials returns the credentials of the forges for their git repositories served over http(s)
+func forgeCredentials(forgeConfigs []config.ForgeConfig) []git.Credential {
+	credentials := make([]git.Credential, 0, len(forgeConfigs))
+	for _, forgeConfig := range forgeConfigs {
+		var credential git.Credential
+		switch forgeConfig.Type {
+		case config.ForgeGitlab:
+			// any username is accepted along with a personal access token
+			credential = newCredential(forgeConfig.Gitlab.URL, "oauth2", forgeConfig.Gitlab.Token)
+		case config.ForgeGithub:
+			credential = newCredential("https://github.com", "x-access-token", forgeConfig.Github.Token)
+		case config.ForgeGitea:
+			credential = newCredential(forgeConfig.Gitea.URL, "oauth2", forgeConfig.Gitea.Token)
+		case config.ForgeBitbucket:
+			bitbucketURL := forgeConfig.Bitbucket.URL
+			if forgeConfig.Bitbucket.Edition == config.BitbucketCloud {
+				// the api is served on another host than the git repositories
+				bitbucketURL = "https://bitbucket.org"
+			}
+			username := forgeConfig.Bitbucket.Username
+			if username == "" {
+				// access tokens are used without a username
+				username = "x-token-auth"
+			}
+			credential = newCredential(bitbucketURL, username, forgeConfig.Bitbucket.Token)
+		}
+		if credential.Host != "" && credential.Password != "" {
+			credentials = append(credentials, credential)
+		}
+	}
+	return credentials
+}
+
+func newCredential(forgeURL string, username string, password string) git.Credential {
+	parsedURL, err := url.Parse(forgeURL)
+	if err != nil {
+		return git.Credential{}
+	}
+	return git.Credential{
+		Protocol: parsedURL.Scheme,
+		Host:     parsedURL.Host,
+		Username: username,
+		Password: password,
+	}
+}
@@ -0,0 +1,135 @@
+package filter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/badjware/gitforgefs/config"
+	"github.com/badjware/gitforgefs/utils"
+)
+
+// Repository describes the attributes of a repository the filters are applied to
+type Repository struct {
+	// FullPath is the path of the repository on the forge, including its namespace
+	FullPath   string
+	Topics     []string
+	Visibility string
+	Fork       bool
+}
+
+// Filter decides which groups and repositories of a forge are exposed in the filesystem.
+// A nil Filter lets everything through.
+type Filter struct {
+	include    []*regexp.Regexp
+	exclude    []*regexp.Regexp
+	topics     []string
+	visibility []string
+	forks      string
+
+	fingerprint string
+}
+
+// New compiles the filters of a forge
+func New(filterConfig config.FilterConfig) (*Filter, error) {
+	f := &Filter{
+		topics:     filterConfig.Topics,
+		visibility: filterConfig.Visibility,
+		forks:      filterConfig.Forks,
+	}
+	for _, pattern := range filterConfig.Include {
+		re, err := utils.CompilePattern(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile include filter: %v", err)
+		}
+		f.include = append(f.include, re)
+	}
+	for _, pattern := range filterConfig.Exclude {
+		re, err := utils.CompilePattern(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile exclude filter: %v", err)
+		}
+		f.exclude = append(f.exclude, re)
+	}
+
+	if len(f.include) > 0 || len(f.exclude) > 0 || len(f.topics) > 0 || len(f.visibility) > 0 || (f.forks != "" && f.forks != config.ForksInclude) {
+		b, err := json.Marshal(filterConfig)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(b)
+		f.fingerprint = hex.EncodeToString(sum[:8])
+	}
+
+	return f, nil
+}
+
+// HasTopics returns whether repositories are filtered on their topics
+func (f *Filter) HasTopics() bool {
+	return f != nil && len(f.topics) > 0
+}
+
+// MatchGroup returns whether the group at fullPath is exposed. Only the exclude patterns apply to groups, the include
+// patterns are matched against the full path of the repositories.
+func (f *Filter) MatchGroup(fullPath string) bool {
+	if f == nil {
+		return true
+	}
+	return !matchAny(f.exclude, fullPath)
+}
+
+// MatchPath returns whether the repository at fullPath passes the include and exclude patterns. It lets the forges
+// skip fetching the other attributes of the repositories that are filtered out anyway.
+func (f *Filter) MatchPath(fullPath string) bool {
+	if f == nil {
+		return true
+	}
+	if len(f.include) > 0 && !matchAny(f.include, fullPath) {
+		return false
+	}
+	return !matchAny(f.exclude, fullPath)
+}
+
+// MatchRepository returns whether the repository is exposed
+func (f *Filter) MatchRepository(repository Repository) bool {
+	if f == nil {
+		return true
+	}
+	if !f.MatchPath(repository.FullPath) {
+		return false
+	}
+	if len(f.topics) > 0 && !slices.ContainsFunc(repository.Topics, func(topic string) bool { return slices.Contains(f.topics, topic) }) {
+		return false
+	}
+	if len(f.visibility) > 0 && !slices.Contains(f.visibility, repository.Visibility) {
+		return false
+	}
+	switch f.forks {
+	case config.ForksExclude:
+		return !repository.Fork
+	case config.ForksOnly:
+		return repository.Fork
+	}
+	return true
+}
+
+// CacheSource returns the source to load the metadata cache of a forge with, so that the content saved while
+// other filters were configured is discarded
+func (f *Filter) CacheSource(source string) string {
+	if f == nil || f.fingerprint == "" {
+		return source
+	}
+	return source + "#filters=" + f.fingerprint
+}
+
+func matchAny(patterns []*regexp.Regexp, fullPath string) bool {
+	for _, re := range patterns {
+		if re.MatchString(fullPath) {
+			return true
+		}
+	}
+	return false
+}
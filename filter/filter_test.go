@@ -0,0 +1,127 @@
+package filter_test
+
+import (
+	"testing"
+
+	"github.com/badjware/gitforgefs/config"
+	"github.com/badjware/gitforgefs/filter"
+)
+
+func TestMatchRepository(t *testing.T) {
+	f, err := filter.New(config.FilterConfig{
+		Include:    []string{"my-group/**", "re:^other-group/[a-z]+-service$"},
+		Exclude:    []string{"my-group/archive/**", "**/*-old"},
+		Visibility: []string{"internal", "private"},
+		Forks:      config.ForksExclude,
+	})
+	if err != nil {
+		t.Fatalf("New() returned error: %v", err)
+	}
+
+	tests := map[string]struct {
+		input    filter.Repository
+		expected bool
+	}{
+		"Included": {
+			input:    filter.Repository{FullPath: "my-group/sub-group/my-repo", Visibility: "private"},
+			expected: true,
+		},
+		"IncludedByRegex": {
+			input:    filter.Repository{FullPath: "other-group/auth-service", Visibility: "internal"},
+			expected: true,
+		},
+		"NotIncluded": {
+			input:    filter.Repository{FullPath: "other-group/my-repo", Visibility: "private"},
+			expected: false,
+		},
+		"ExcludedGroup": {
+			input:    filter.Repository{FullPath: "my-group/archive/my-repo", Visibility: "private"},
+			expected: false,
+		},
+		"ExcludedName": {
+			input:    filter.Repository{FullPath: "my-group/my-repo-old", Visibility: "private"},
+			expected: false,
+		},
+		"Visibility": {
+			input:    filter.Repository{FullPath: "my-group/my-repo", Visibility: "public"},
+			expected: false,
+		},
+		"Fork": {
+			input:    filter.Repository{FullPath: "my-group/my-repo", Visibility: "private", Fork: true},
+			expected: false,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			got := f.MatchRepository(test.input)
+			if got != test.expected {
+				t.Fatalf("MatchRepository(%v) returned %v; expected %v", test.input, got, test.expected)
+			}
+		})
+	}
+}
+
+func TestMatchGroup(t *testing.T) {
+	f, err := filter.New(config.FilterConfig{
+		Include: []string{"my-group/my-repo"},
+		Exclude: []string{"my-group/archive/**"},
+		Topics:  []string{"go"},
+	})
+	if err != nil {
+		t.Fatalf("New() returned error: %v", err)
+	}
+
+	tests := map[string]struct {
+		input    string
+		expected bool
+	}{
+		"Group":         {input: "my-group/sub-group", expected: true},
+		"Excluded":      {input: "my-group/archive", expected: false},
+		"ExcludedChild": {input: "my-group/archive/2020", expected: false},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			got := f.MatchGroup(test.input)
+			if got != test.expected {
+				t.Fatalf("MatchGroup(%v) returned %v; expected %v", test.input, got, test.expected)
+			}
+		})
+	}
+}
+
+func TestMatchPath(t *testing.T) {
+	f, err := filter.New(config.FilterConfig{
+		Include: []string{"my-group/**"},
+		Exclude: []string{"my-group/archive/**"},
+		Topics:  []string{"go"},
+	})
+	if err != nil {
+		t.Fatalf("New() returned error: %v", err)
+	}
+
+	tests := map[string]struct {
+		input    string
+		expected bool
+	}{
+		"Included":    {input: "my-group/my-repo", expected: true},
+		"NotIncluded": {input: "other-group/my-repo", expected: false},
+		"Excluded":    {input: "my-group/archive/my-repo", expected: false},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			got := f.MatchPath(test.input)
+			if got != test.expected {
+				t.Fatalf("MatchPath(%v) returned %v; expected %v", test.input, got, test.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package bitbucket
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/badjware/gitforgefs/config"
+)
+
+const (
+	pageSize = 100
+
+	// Bitbucket Cloud identifies its objects with uuids, which we hash into ids.
+	// Ids are kept small enough to leave room for the namespacing done when exposing multiple forges.
+	idMask = 1<<47 - 1
+)
+
+// page holds a page of results of both the Bitbucket Cloud and the Bitbucket Data Center APIs
+type page[T any] struct {
+	Values []T `json:"values"`
+
+	// Bitbucket Cloud
+	Next string `json:"next"`
+
+	// Bitbucket Data Center
+	IsLastPage    bool `json:"isLastPage"`
+	NextPageStart int  `json:"nextPageStart"`
+}
+
+type apiError struct {
+	StatusCode int
+	URL        string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("GET %v: %v %v", e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+func (c *bitbucketClient) apiURL(path string, query url.Values) string {
+	apiURL := strings.TrimSuffix(c.URL, "/")
+	if c.Edition == config.BitbucketDataCenter {
+		apiURL += "/rest/api/1.0"
+	}
+	apiURL += path
+	if len(query) > 0 {
+		apiURL += "?" + query.Encode()
+	}
+	return apiURL
+}
+
+// get fetches rawURL and decodes the json response in v. If v is a *string, the raw response is returned instead.
+// endpoint names the api endpoint of rawURL in the metrics.
+func (c *bitbucketClient) get(endpoint string, rawURL string, v any) (err error) {
+	start := time.Now()
+	defer func() {
+		c.metrics.ObserveRequest(endpoint, start, err)
+	}()
+
+	request, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/json")
+	if c.Token != "" {
+		if c.Username != "" {
+			request.SetBasicAuth(c.Username, c.Token)
+		} else {
+			request.Header.Set("Authorization", "Bearer "+c.Token)
+		}
+	}
+
+	c.logger.Debug("Calling bitbucket api", "url", rawURL)
+	response, err := c.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return &apiError{StatusCode: response.StatusCode, URL: rawURL}
+	}
+
+	if s, ok := v.(*string); ok {
+		body, err := io.ReadAll(response.Body)
+		if err != nil {
+			return err
+		}
+		*s = strings.TrimSpace(string(body))
+		return nil
+	}
+	return json.NewDecoder(response.Body).Decode(v)
+}
+
+// listAll fetches every page of results of path
+func listAll[T any](c *bitbucketClient, endpoint string, path string, query url.Values) ([]T, error) {
+	if query == nil {
+		query = url.Values{}
+	}
+	if c.Edition == config.BitbucketDataCenter {
+		query.Set("limit", strconv.Itoa(pageSize))
+	} else {
+		query.Set("pagelen", strconv.Itoa(pageSize))
+	}
+
+	values := []T{}
+	pageURL := c.apiURL(path, query)
+	for {
+		var p page[T]
+		if err := c.get(endpoint, pageURL, &p); err != nil {
+			return nil, err
+		}
+		values = append(values, p.Values...)
+
+		// Get the next page
+		if c.Edition == config.BitbucketDataCenter {
+			if p.IsLastPage {
+				break
+			}
+			query.Set("start", strconv.Itoa(p.NextPageStart))
+			pageURL = c.apiURL(path, query)
+		} else {
+			if p.Next == "" {
+				break
+			}
+			pageURL = p.Next
+		}
+	}
+	return values, nil
+}
+
+func hashID(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64() & idMask
+}
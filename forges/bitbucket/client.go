@@ -0,0 +1,239 @@
+package bitbucket
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/badjware/gitforgefs/cache"
+	"github.com/badjware/gitforgefs/config"
+	"github.com/badjware/gitforgefs/fstree"
+	"github.com/badjware/gitforgefs/metrics"
+)
+
+const (
+	currentUserMetadataKey = "current_user"
+)
+
+type bitbucketClient struct {
+	config.BitbucketClientConfig
+	client *http.Client
+
+	logger *slog.Logger
+
+	store   *cache.Store
+	metrics *metrics.ForgeMetrics
+
+	rootMux     sync.Mutex
+	rootContent map[string]fstree.GroupSource
+	// the last root content fetched without error, served while the root cannot be fully fetched
+	lastRootContent map[string]fstree.GroupSource
+	currentUser     string
+
+	// API response cache
+	groupCacheMux    sync.RWMutex
+	groupNameToIDMap map[string]uint64
+	groupCache       map[uint64]*Group
+}
+
+type bitbucketUser struct {
+	// Bitbucket Cloud
+	Username string `json:"username"`
+}
+
+func NewClient(logger *slog.Logger, config config.BitbucketClientConfig, store *cache.Store, forgeMetrics *metrics.ForgeMetrics) (*bitbucketClient, error) {
+	bitbucketClient := &bitbucketClient{
+		BitbucketClientConfig: config,
+		client:                &http.Client{Timeout: 30 * time.Second},
+
+		logger: logger,
+
+		store:   store,
+		metrics: forgeMetrics,
+
+		rootContent: nil,
+
+		groupNameToIDMap: map[string]uint64{},
+		groupCache:       map[uint64]*Group{},
+	}
+
+	if bitbucketClient.IncludeCurrentUser {
+		// if the filesystem can be served from the metadata cache, the current user is resolved in the background
+		if bitbucketClient.restore() {
+			go bitbucketClient.resolveCurrentUser()
+		} else {
+			bitbucketClient.resolveCurrentUser()
+		}
+	} else {
+		bitbucketClient.restore()
+	}
+
+	return bitbucketClient, nil
+}
+
+func (c *bitbucketClient) resolveCurrentUser() {
+	var currentUserName string
+	if c.Edition == config.BitbucketDataCenter {
+		// whoami answers with the name of the user in plain text, or nothing if anonymous
+		err := c.get("whoami", strings.TrimSuffix(c.URL, "/")+"/plugins/servlet/applinks/whoami", &currentUserName)
+		if err != nil {
+			c.logger.Warn("failed to fetch the current user:", "error", err.Error())
+			return
+		}
+	} else {
+		var currentUser bitbucketUser
+		err := c.get("user", c.apiURL("/user", nil), &currentUser)
+		if err != nil {
+			c.logger.Warn("failed to fetch the current user:", "error", err.Error())
+			return
+		}
+		currentUserName = currentUser.Username
+	}
+	if currentUserName == "" {
+		return
+	}
+
+	c.rootMux.Lock()
+	defer c.rootMux.Unlock()
+	if c.currentUser != currentUserName {
+		c.currentUser = currentUserName
+		c.rootContent = nil
+	}
+
+	c.store.SetMetadata(currentUserMetadataKey, currentUserName)
+}
+
+// restore populates the caches from the metadata cache. It returns false if there was nothing to restore.
+func (c *bitbucketClient) restore() bool {
+	snapshot := c.store.Load(c.Edition + ":" + c.URL)
+	if snapshot == nil {
+		return false
+	}
+
+	// restore the groups first so they can be referenced as child groups
+	for _, groupSnapshot := range snapshot.Groups {
+		group := c.newGroup(groupSnapshot.ID, groupSnapshot.Name, groupSnapshot.Kind, "", c.rootCacheTTL(groupSnapshot.Name))
+		c.groupCache[group.ID] = group
+		if group.Kind != projectKind || c.Edition == config.BitbucketDataCenter {
+			c.groupNameToIDMap[group.Kind+":"+group.Name] = group.ID
+		}
+	}
+
+	// restore the content
+	for _, groupSnapshot := range snapshot.Groups {
+		group := c.groupCache[groupSnapshot.ID]
+		if groupSnapshot.FetchedAt.IsZero() {
+			continue
+		}
+		childGroups := make(map[string]fstree.GroupSource, len(groupSnapshot.Groups))
+		for name, gid := range groupSnapshot.Groups {
+			if childGroup, found := c.groupCache[gid]; found {
+				childGroup.workspace = group.Name
+				childGroups[name] = childGroup
+			}
+		}
+		childRepositories := make(map[string]fstree.RepositorySource, len(groupSnapshot.Repositories))
+		for name, repositorySnapshot := range groupSnapshot.Repositories {
+			childRepositories[name] = &Repository{
+				ID:            repositorySnapshot.ID,
+				FullPath:      repositorySnapshot.FullPath,
+				Path:          name,
+				CloneURL:      repositorySnapshot.CloneURL,
+				DefaultBranch: repositorySnapshot.DefaultBranch,
+
+				Metadata: repositorySnapshot.Metadata,
+			}
+		}
+		group.content.Restore(childGroups, childRepositories, groupSnapshot.FetchedAt)
+	}
+
+	// restore the current user
+	if c.IncludeCurrentUser {
+		c.currentUser, _ = c.store.GetMetadata(currentUserMetadataKey)
+	}
+
+	return true
+}
+
+func (c *bitbucketClient) FetchRootGroupContent() (map[string]fstree.GroupSource, error) {
+	c.rootMux.Lock()
+	defer c.rootMux.Unlock()
+
+	if c.rootContent == nil {
+		rootContent := make(map[string]fstree.GroupSource)
+		var fetchErr error
+
+		userNames := c.UserNames
+		if c.currentUser != "" {
+			userNames = append([]string{c.currentUser}, userNames...)
+		}
+
+		addRootGroup := func(kind string, name string) {
+			group, err := c.fetchRootGroup(kind, name)
+			if err != nil {
+				c.logger.Warn(err.Error())
+				if fetchErr == nil {
+					fetchErr = err
+				}
+			} else {
+				rootContent[group.Name] = group
+			}
+		}
+
+		for _, workspaceName := range c.WorkspaceNames {
+			addRootGroup(workspaceKind, workspaceName)
+		}
+		for _, projectKey := range c.ProjectKeys {
+			addRootGroup(projectKind, projectKey)
+		}
+		// users have a personal workspace in Bitbucket Cloud
+		userGroupKind := workspaceKind
+		if c.Edition == config.BitbucketDataCenter {
+			userGroupKind = userKind
+		}
+		for _, userName := range userNames {
+			addRootGroup(userGroupKind, userName)
+		}
+
+		if fetchErr != nil {
+			// retry on the next call instead of caching an incomplete root, and serve the last complete root meanwhile
+			if c.lastRootContent != nil {
+				rootContent = c.lastRootContent
+			}
+			return rootContent, fmt.Errorf("failed to fetch the root content: %v", fetchErr)
+		}
+		c.rootContent = rootContent
+		c.lastRootContent = rootContent
+		c.store.SetRoot(rootContent)
+	}
+	return c.rootContent, nil
+}
+
+func (c *bitbucketClient) InvalidateRootContentCache() {
+	c.rootMux.Lock()
+	c.rootContent = nil
+	c.rootMux.Unlock()
+
+	// pick up a change of the current user
+	go c.resolveCurrentUser()
+}
+
+func (c *bitbucketClient) FetchGroupContent(gid uint64) (map[string]fstree.GroupSource, map[string]fstree.RepositorySource, error) {
+	c.groupCacheMux.RLock()
+	group, found := c.groupCache[gid]
+	c.groupCacheMux.RUnlock()
+	if found {
+		return c.fetchGroupContent(group)
+	}
+	return nil, nil, fmt.Errorf("invalid gid: %v", gid)
+}
+
+func (c *bitbucketClient) rootCacheTTL(name string) time.Duration {
+	if ttl, found := c.CacheTTLOverrides[name]; found {
+		return ttl
+	}
+	return c.CacheTTL
+}
@@ -0,0 +1,121 @@
+package bitbucket_test
+
+import (
+	"log/slog"
+	"reflect"
+	"testing"
+
+	"github.com/badjware/gitforgefs/config"
+	"github.com/badjware/gitforgefs/forges/bitbucket"
+	"github.com/badjware/gitforgefs/forges/forgetest"
+	"github.com/badjware/gitforgefs/fstree"
+)
+
+type expectedRepository struct {
+	Path          string
+	CloneURL      string
+	DefaultBranch string
+}
+
+// listTree returns the path of every groups and repositories of the forge
+func listTree(t *testing.T, forge fstree.GitForge) ([]string, map[string]expectedRepository) {
+	groupPaths, repos := forgetest.ListTree(t, forge)
+	repositories := make(map[string]expectedRepository, len(repos))
+	for path, repo := range repos {
+		repositories[path] = expectedRepository{
+			Path:          path,
+			CloneURL:      repo.GetCloneURL(),
+			DefaultBranch: repo.GetDefaultBranch(),
+		}
+	}
+	return groupPaths, repositories
+}
+
+func TestBitbucketCloud(t *testing.T) {
+	server := forgetest.NewServer(t, "Bearer 12345", map[string]string{
+		"/user":                      `{"username": "test-user"}`,
+		"/workspaces/test-workspace": `{"uuid": "{w1}", "slug": "test-workspace"}`,
+		"/workspaces/test-user":      `{"uuid": "{w2}", "slug": "test-user"}`,
+		"/workspaces/test-workspace/projects?pagelen=100":        `{"values": [{"uuid": "{p1}", "key": "PROJ"}], "next": "{{url}}/workspaces/test-workspace/projects?page=2&pagelen=100"}`,
+		"/workspaces/test-workspace/projects?page=2&pagelen=100": `{"values": [{"uuid": "{p2}", "key": "EMPTY"}]}`,
+		"/workspaces/test-user/projects?pagelen=100":             `{"values": []}`,
+		"/repositories/test-workspace?pagelen=100&q=project.key%3D%22PROJ%22": `{"values": [
+			{"uuid": "{r1}", "slug": "repo", "mainbranch": {"name": "main"}, "links": {"clone": [{"name": "https", "href": "https://bitbucket.org/test-workspace/repo.git"}, {"name": "ssh", "href": "git@bitbucket.org:test-workspace/repo.git"}]}},
+			{"uuid": "{r2}", "slug": "empty-repo", "links": {"clone": [{"name": "https", "href": "https://bitbucket.org/test-workspace/empty-repo.git"}]}}
+		]}`,
+		"/repositories/test-workspace?pagelen=100&q=project.key%3D%22EMPTY%22": `{"values": []}`,
+	})
+
+	forge, err := bitbucket.NewClient(slog.Default(), config.BitbucketClientConfig{
+		URL:                  server.URL,
+		Edition:              config.BitbucketCloud,
+		Token:                "12345",
+		WorkspaceNames:       []string{"test-workspace"},
+		ArchivedRepoHandling: config.ArchivedProjectHide,
+		IncludeCurrentUser:   true,
+		PullMethod:           config.PullMethodHTTP,
+	}, nil, nil)
+	if err != nil {
+		t.Fatalf("NewClient() returned error: %v", err)
+	}
+
+	groupPaths, repositories := listTree(t, forge)
+	expectedGroupPaths := []string{"test-user", "test-workspace", "test-workspace/EMPTY", "test-workspace/PROJ"}
+	if !reflect.DeepEqual(groupPaths, expectedGroupPaths) {
+		t.Fatalf("forge has groups %v; expected %v", groupPaths, expectedGroupPaths)
+	}
+	expectedRepositories := map[string]expectedRepository{
+		"test-workspace/PROJ/repo":       {"test-workspace/PROJ/repo", "https://bitbucket.org/test-workspace/repo.git", "main"},
+		"test-workspace/PROJ/empty-repo": {"test-workspace/PROJ/empty-repo", "https://bitbucket.org/test-workspace/empty-repo.git", "master"},
+	}
+	if !reflect.DeepEqual(repositories, expectedRepositories) {
+		t.Fatalf("forge has repositories %v; expected %v", repositories, expectedRepositories)
+	}
+}
+
+func TestBitbucketDataCenter(t *testing.T) {
+	server := forgetest.NewServer(t, "Bearer 12345", map[string]string{
+		"/plugins/servlet/applinks/whoami": `test-user`,
+		"/rest/api/1.0/projects/TEST":      `{"id": 1, "key": "TEST"}`,
+		"/rest/api/1.0/users/test-user":    `{"id": 1, "slug": "test-user"}`,
+		"/rest/api/1.0/projects/TEST/repos?limit=100": `{"values": [
+			{"id": 1, "slug": "repo", "project": {"key": "TEST"}, "links": {"clone": [{"name": "http", "href": "https://bitbucket.example.com/scm/test/repo.git"}, {"name": "ssh", "href": "ssh://git@bitbucket.example.com:7999/test/repo.git"}]}}
+		], "isLastPage": false, "nextPageStart": 1}`,
+		"/rest/api/1.0/projects/TEST/repos?limit=100&start=1": `{"values": [
+			{"id": 2, "slug": "old-repo", "archived": true, "project": {"key": "TEST"}, "links": {"clone": [{"name": "ssh", "href": "ssh://git@bitbucket.example.com:7999/test/old-repo.git"}]}}
+		], "isLastPage": true}`,
+		"/rest/api/1.0/projects/~test-user/repos?limit=100": `{"values": [
+			{"id": 3, "slug": "personal-repo", "project": {"key": "~TEST-USER"}, "links": {"clone": [{"name": "ssh", "href": "ssh://git@bitbucket.example.com:7999/~test-user/personal-repo.git"}]}}
+		], "isLastPage": true}`,
+		"/rest/api/1.0/projects/TEST/repos/repo/default-branch":                `{"id": "refs/heads/main", "displayId": "main"}`,
+		"/rest/api/1.0/projects/TEST/repos/old-repo/default-branch":            `{"id": "refs/heads/develop", "displayId": "develop"}`,
+		"/rest/api/1.0/projects/~TEST-USER/repos/personal-repo/default-branch": `{"id": "refs/heads/trunk", "displayId": "trunk"}`,
+	})
+
+	forge, err := bitbucket.NewClient(slog.Default(), config.BitbucketClientConfig{
+		URL:                  server.URL,
+		Edition:              config.BitbucketDataCenter,
+		Token:                "12345",
+		ProjectKeys:          []string{"TEST"},
+		ArchivedRepoHandling: config.ArchivedProjectHide,
+		IncludeCurrentUser:   true,
+		PullMethod:           config.PullMethodSSH,
+	}, nil, nil)
+	if err != nil {
+		t.Fatalf("NewClient() returned error: %v", err)
+	}
+
+	groupPaths, repositories := listTree(t, forge)
+	expectedGroupPaths := []string{"TEST", "test-user"}
+	if !reflect.DeepEqual(groupPaths, expectedGroupPaths) {
+		t.Fatalf("forge has groups %v; expected %v", groupPaths, expectedGroupPaths)
+	}
+	expectedRepositories := map[string]expectedRepository{
+		"TEST/repo":               {"TEST/repo", "ssh://git@bitbucket.example.com:7999/test/repo.git", "main"},
+		"TEST/.old-repo":          {"TEST/.old-repo", "ssh://git@bitbucket.example.com:7999/test/old-repo.git", "develop"},
+		"test-user/personal-repo": {"test-user/personal-repo", "ssh://git@bitbucket.example.com:7999/~test-user/personal-repo.git", "trunk"},
+	}
+	if !reflect.DeepEqual(repositories, expectedRepositories) {
+		t.Fatalf("forge has repositories %v; expected %v", repositories, expectedRepositories)
+	}
+}
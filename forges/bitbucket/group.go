@@ -0,0 +1,203 @@
+package bitbucket
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/badjware/gitforgefs/cache"
+	"github.com/badjware/gitforgefs/config"
+	"github.com/badjware/gitforgefs/fstree"
+)
+
+const (
+	// Bitbucket Cloud workspace, containing projects
+	workspaceKind = "workspace"
+	// Bitbucket Cloud or Bitbucket Data Center project, containing repositories
+	projectKind = "project"
+	// Bitbucket Data Center user, containing personal repositories
+	userKind = "user"
+)
+
+type Group struct {
+	ID   uint64
+	Name string
+	Kind string
+
+	// slug of the workspace of a Bitbucket Cloud project
+	workspace string
+
+	// hold group content
+	content *cache.ContentCache
+}
+
+type bitbucketGroup struct {
+	// Bitbucket Data Center
+	ID int64 `json:"id"`
+
+	// Bitbucket Cloud
+	UUID string `json:"uuid"`
+
+	Slug string `json:"slug"`
+	Key  string `json:"key"`
+}
+
+func (g *Group) GetGroupID() uint64 {
+	return g.ID
+}
+
+func (g *Group) InvalidateContentCache() {
+	// clear child groups and repositories from cache
+	g.content.Invalidate()
+}
+
+func (c *bitbucketClient) newGroup(gid uint64, name string, kind string, workspace string, cacheTTL time.Duration) *Group {
+	group := &Group{
+		ID:   gid,
+		Name: name,
+		Kind: kind,
+
+		workspace: workspace,
+
+		content: cache.NewContentCache(c.logger, cacheTTL),
+	}
+	c.store.Track(gid, name, kind, group.content)
+	return group
+}
+
+func (c *bitbucketClient) newGroupFromBitbucketGroup(bitbucketGroup *bitbucketGroup, kind string, workspace string, cacheTTL time.Duration) *Group {
+	var gid uint64
+	var name string
+	switch kind {
+	case workspaceKind:
+		gid = hashID(kind + ":" + bitbucketGroup.UUID)
+		name = bitbucketGroup.Slug
+	case projectKind:
+		if c.Edition == config.BitbucketDataCenter {
+			gid = hashID(kind + ":" + strconv.FormatInt(bitbucketGroup.ID, 10))
+		} else {
+			gid = hashID(kind + ":" + bitbucketGroup.UUID)
+		}
+		name = bitbucketGroup.Key
+	case userKind:
+		gid = hashID(kind + ":" + strconv.FormatInt(bitbucketGroup.ID, 10))
+		name = bitbucketGroup.Slug
+	}
+
+	// start by searching the cache
+	c.groupCacheMux.RLock()
+	group, found := c.groupCache[gid]
+	c.groupCacheMux.RUnlock()
+	if found && group.Name == name {
+		// if found in cache, return the cached reference
+		c.logger.Debug("Group cache hit", "gid", gid)
+		c.metrics.ObserveCacheLookup(kind, true)
+		group.content.SetTTL(cacheTTL)
+		return group
+	} else {
+		c.logger.Debug("Group cache miss; registering group", "gid", gid)
+		c.metrics.ObserveCacheLookup(kind, false)
+	}
+
+	// if not found in cache, convert and save to cache now
+	newGroup := c.newGroup(gid, name, kind, workspace, cacheTTL)
+
+	// save in cache
+	c.groupCacheMux.Lock()
+	c.groupCache[gid] = newGroup
+	c.groupCacheMux.Unlock()
+
+	return newGroup
+}
+
+func (c *bitbucketClient) fetchRootGroup(kind string, name string) (*Group, error) {
+	// start by searching the cache
+	c.groupCacheMux.RLock()
+	cachedID, found := c.groupNameToIDMap[kind+":"+name]
+	if found {
+		cachedGroup := c.groupCache[cachedID]
+		c.groupCacheMux.RUnlock()
+
+		// if found in cache, return the cached reference
+		c.logger.Debug("Group cache hit", "kind", kind, "name", name)
+		c.metrics.ObserveCacheLookup(kind, true)
+		return cachedGroup, nil
+	} else {
+		c.groupCacheMux.RUnlock()
+
+		c.logger.Debug("Group cache miss", "kind", kind, "name", name)
+		c.metrics.ObserveCacheLookup(kind, false)
+	}
+
+	// If not found in cache, fetch group infos from API
+	var path string
+	switch kind {
+	case workspaceKind:
+		path = "/workspaces/" + url.PathEscape(name)
+	case projectKind:
+		path = "/projects/" + url.PathEscape(name)
+	case userKind:
+		path = "/users/" + url.PathEscape(name)
+	}
+	var bitbucketGroup bitbucketGroup
+	if err := c.get(kind, c.apiURL(path, nil), &bitbucketGroup); err != nil {
+		return nil, fmt.Errorf("failed to fetch %v with name %v: %v", kind, name, err)
+	}
+	newGroup := c.newGroupFromBitbucketGroup(&bitbucketGroup, kind, "", c.rootCacheTTL(name))
+
+	// save in cache
+	c.groupCacheMux.Lock()
+	c.groupNameToIDMap[kind+":"+name] = newGroup.ID
+	c.groupCacheMux.Unlock()
+
+	return newGroup, nil
+}
+
+func (c *bitbucketClient) fetchGroupContent(group *Group) (map[string]fstree.GroupSource, map[string]fstree.RepositorySource, error) {
+	// child groups inherit the cache ttl of their parent
+	cacheTTL := group.content.TTL()
+
+	return group.content.Get(func() (map[string]fstree.GroupSource, map[string]fstree.RepositorySource, error) {
+		childGroups := make(map[string]fstree.GroupSource)
+		childRepositories := make(map[string]fstree.RepositorySource)
+
+		if group.Kind == workspaceKind {
+			// List the projects of the workspace
+			bitbucketProjects, err := listAll[bitbucketGroup](c, "projects", "/workspaces/"+url.PathEscape(group.Name)+"/projects", nil)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to fetch projects in bitbucket: %v", err)
+			}
+			for _, bitbucketProject := range bitbucketProjects {
+				project := c.newGroupFromBitbucketGroup(&bitbucketProject, projectKind, group.Name, cacheTTL)
+				childGroups[project.Name] = project
+			}
+			return childGroups, childRepositories, nil
+		}
+
+		// List the repositories
+		var path string
+		var query url.Values
+		if group.Kind == projectKind && c.Edition == config.BitbucketCloud {
+			path = "/repositories/" + url.PathEscape(group.workspace)
+			query = url.Values{"q": []string{fmt.Sprintf("project.key=\"%v\"", group.Name)}}
+		} else if group.Kind == projectKind {
+			path = "/projects/" + url.PathEscape(group.Name) + "/repos"
+		} else {
+			// personal repositories of a user are stored in a special project
+			path = "/projects/~" + url.PathEscape(group.Name) + "/repos"
+		}
+		bitbucketRepositories, err := listAll[bitbucketRepository](c, "repositories", path, query)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to fetch repositories in bitbucket: %v", err)
+		}
+		defaultBranches := c.fetchDefaultBranches(bitbucketRepositories)
+		for i, bitbucketRepository := range bitbucketRepositories {
+			repository := c.newRepositoryFromBitbucketRepository(&bitbucketRepository, defaultBranches[i])
+			if repository != nil {
+				childRepositories[repository.Path] = repository
+			}
+		}
+		return childGroups, childRepositories, nil
+	})
+}
@@ -0,0 +1,187 @@
+package bitbucket
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/badjware/gitforgefs/config"
+	"github.com/badjware/gitforgefs/fstree"
+)
+
+const (
+	// The most default branches fetched at once from Bitbucket Data Center
+	defaultBranchFetchConcurrency = 8
+)
+
+type Repository struct {
+	ID            uint64
+	FullPath      string
+	Path          string
+	CloneURL      string
+	DefaultBranch string
+
+	Metadata *fstree.RepositoryMetadata
+}
+
+type bitbucketRepository struct {
+	// Bitbucket Data Center
+	ID       int64 `json:"id"`
+	Archived bool  `json:"archived"`
+	Public   bool  `json:"public"`
+
+	// Bitbucket Cloud
+	UUID       string `json:"uuid"`
+	MainBranch *struct {
+		Name string `json:"name"`
+	} `json:"mainbranch"`
+	IsPrivate bool       `json:"is_private"`
+	UpdatedOn *time.Time `json:"updated_on"`
+
+	Slug string `json:"slug"`
+	// Bitbucket Cloud
+	FullName    string `json:"full_name"`
+	Description string `json:"description"`
+	Project     struct {
+		Key string `json:"key"`
+	} `json:"project"`
+	Links struct {
+		Clone []struct {
+			Name string `json:"name"`
+			Href string `json:"href"`
+		} `json:"clone"`
+
+		// Bitbucket Cloud
+		HTML struct {
+			Href string `json:"href"`
+		} `json:"html"`
+
+		// Bitbucket Data Center
+		Self []struct {
+			Href string `json:"href"`
+		} `json:"self"`
+	} `json:"links"`
+}
+
+type bitbucketBranch struct {
+	DisplayID string `json:"displayId"`
+}
+
+func (r *Repository) GetRepositoryID() uint64 {
+	return r.ID
+}
+
+func (r *Repository) GetRepositoryPath() string {
+	return r.FullPath
+}
+
+func (r *Repository) GetCloneURL() string {
+	return r.CloneURL
+}
+
+func (r *Repository) GetDefaultBranch() string {
+	return r.DefaultBranch
+}
+
+func (r *Repository) GetMetadata() *fstree.RepositoryMetadata {
+	return r.Metadata
+}
+
+// newRepositoryFromBitbucketRepository returns the repository, or nil if it is ignored. defaultBranch is the default
+// branch of the repository in Bitbucket Data Center, as returned by fetchDefaultBranches.
+func (c *bitbucketClient) newRepositoryFromBitbucketRepository(repository *bitbucketRepository, defaultBranch string) *Repository {
+	if c.ArchivedRepoHandling == config.ArchivedProjectIgnore && repository.Archived {
+		return nil
+	}
+	r := Repository{
+		Path: repository.Slug,
+	}
+	if c.Edition == config.BitbucketDataCenter {
+		r.ID = uint64(repository.ID)
+		r.FullPath = repository.Project.Key + "/" + repository.Slug
+		r.DefaultBranch = defaultBranch
+	} else {
+		r.ID = hashID(repository.UUID)
+		r.FullPath = repository.FullName
+		if repository.MainBranch != nil {
+			r.DefaultBranch = repository.MainBranch.Name
+		}
+	}
+	if r.DefaultBranch == "" {
+		r.DefaultBranch = "master"
+	}
+	r.Metadata = &fstree.RepositoryMetadata{
+		Description:   repository.Description,
+		DefaultBranch: r.DefaultBranch,
+		Archived:      repository.Archived,
+	}
+	if c.Edition == config.BitbucketDataCenter {
+		if len(repository.Links.Self) > 0 {
+			r.Metadata.WebURL = repository.Links.Self[0].Href
+		}
+		r.Metadata.Visibility = "private"
+		if repository.Public {
+			r.Metadata.Visibility = "public"
+		}
+	} else {
+		r.Metadata.WebURL = repository.Links.HTML.Href
+		r.Metadata.Visibility = "public"
+		if repository.IsPrivate {
+			r.Metadata.Visibility = "private"
+		}
+		r.Metadata.LastActivity = repository.UpdatedOn
+	}
+	for _, link := range repository.Links.Clone {
+		if c.PullMethod == config.PullMethodSSH && link.Name == "ssh" || c.PullMethod == config.PullMethodHTTP && link.Name != "ssh" {
+			r.CloneURL = link.Href
+		}
+	}
+	if c.ArchivedRepoHandling == config.ArchivedProjectHide && repository.Archived {
+		r.Path = path.Join(path.Dir(r.Path), "."+path.Base(r.Path))
+	}
+	return &r
+}
+
+// fetchDefaultBranches returns the default branch of each of the repositories, by index. The default branch is not
+// part of the repository in Bitbucket Data Center, so it is fetched for each repository, a few at once.
+func (c *bitbucketClient) fetchDefaultBranches(repositories []bitbucketRepository) []string {
+	defaultBranches := make([]string, len(repositories))
+	if c.Edition != config.BitbucketDataCenter {
+		return defaultBranches
+	}
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, defaultBranchFetchConcurrency)
+	for i := range repositories {
+		repository := &repositories[i]
+		if c.ArchivedRepoHandling == config.ArchivedProjectIgnore && repository.Archived {
+			continue
+		}
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			defaultBranch, err := c.fetchDefaultBranch(repository)
+			if err != nil {
+				c.logger.Warn("failed to fetch the default branch of the repository", "repository", repository.Slug, "error", err.Error())
+			}
+			defaultBranches[i] = defaultBranch
+		}(i)
+	}
+	wg.Wait()
+	return defaultBranches
+}
+
+func (c *bitbucketClient) fetchDefaultBranch(repository *bitbucketRepository) (string, error) {
+	var branch bitbucketBranch
+	apiURL := c.apiURL(fmt.Sprintf("/projects/%v/repos/%v/default-branch", url.PathEscape(repository.Project.Key), url.PathEscape(repository.Slug)), nil)
+	if err := c.get("default-branch", apiURL, &branch); err != nil {
+		return "", err
+	}
+	return branch.DisplayID, nil
+}
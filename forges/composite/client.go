@@ -0,0 +1,168 @@
+package composite
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/badjware/gitforgefs/fstree"
+)
+
+const (
+	// The group ids and repository ids of each forge are namespaced by storing the index of the forge
+	// in the upper bits of the id, so that the inodes of different forges never collide.
+	namespaceShift = 48
+	idMask         = 1<<namespaceShift - 1
+)
+
+type Forge struct {
+	Name     string
+	GitForge fstree.GitForge
+}
+
+type compositeClient struct {
+	logger *slog.Logger
+
+	mux sync.RWMutex
+	// forges by namespace index, nil once a forge is removed
+	forges []*Forge
+	// namespace index of each forge, by name. The index of a forge never changes so its inodes stay stable
+	indexes     map[string]int
+	rootContent map[string]fstree.GroupSource
+}
+
+// NewClient creates a forge exposing each of the given forges as a top-level directory named after the forge.
+func NewClient(logger *slog.Logger, forges []Forge) (*compositeClient, error) {
+	compositeClient := &compositeClient{
+		logger: logger,
+
+		forges:      []*Forge{},
+		indexes:     map[string]int{},
+		rootContent: map[string]fstree.GroupSource{},
+	}
+	if err := compositeClient.SetForges(forges); err != nil {
+		return nil, err
+	}
+
+	return compositeClient, nil
+}
+
+// SetForges replaces the forges exposed by the client. The top-level directory of a forge that is unchanged is kept as-is.
+func (c *compositeClient) SetForges(forges []Forge) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	newCount := len(c.forges)
+	for _, forge := range forges {
+		if _, found := c.indexes[forge.Name]; !found {
+			newCount++
+		}
+	}
+	if newCount >= 1<<(64-namespaceShift)-1 {
+		return fmt.Errorf("too many forges: %v", newCount)
+	}
+
+	rootContent := make(map[string]fstree.GroupSource, len(forges))
+	for _, forge := range forges {
+		forge := forge
+		i, found := c.indexes[forge.Name]
+		if !found {
+			i = len(c.forges)
+			c.indexes[forge.Name] = i
+			c.forges = append(c.forges, nil)
+		}
+		if c.forges[i] != nil && c.forges[i].GitForge == forge.GitForge {
+			rootContent[forge.Name] = c.rootContent[forge.Name]
+		} else {
+			rootContent[forge.Name] = &forgeGroup{
+				id:    namespace(i, 0),
+				forge: forge.GitForge,
+			}
+		}
+		c.forges[i] = &forge
+	}
+	// forget the forges that were removed, their index is not reused
+	for name, i := range c.indexes {
+		if _, found := rootContent[name]; !found {
+			c.forges[i] = nil
+		}
+	}
+	c.rootContent = rootContent
+
+	return nil
+}
+
+func (c *compositeClient) FetchRootGroupContent() (map[string]fstree.GroupSource, error) {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	return c.rootContent, nil
+}
+
+// InvalidateRootContentCache refreshes the root content of every forges
+func (c *compositeClient) InvalidateRootContentCache() {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	for _, rootGroup := range c.rootContent {
+		rootGroup.InvalidateContentCache()
+	}
+}
+
+func (c *compositeClient) FetchGroupContent(gid uint64) (map[string]fstree.GroupSource, map[string]fstree.RepositorySource, error) {
+	i, forgeGid := unnamespace(gid)
+	c.mux.RLock()
+	var forge fstree.GitForge
+	if i >= 0 && i < len(c.forges) && c.forges[i] != nil {
+		forge = c.forges[i].GitForge
+	}
+	c.mux.RUnlock()
+	if forge == nil {
+		return nil, nil, fmt.Errorf("invalid gid: %v", gid)
+	}
+
+	if forgeGid == 0 {
+		// gid is the top-level directory of the forge
+		groups, err := forge.FetchRootGroupContent()
+		if err != nil && groups == nil {
+			return nil, nil, err
+		}
+		// on error, groups is the last known root content of the forge
+		return wrapGroups(i, groups), make(map[string]fstree.RepositorySource), err
+	}
+
+	groups, repositories, err := forge.FetchGroupContent(forgeGid)
+	if err != nil && (groups == nil || repositories == nil) {
+		return nil, nil, err
+	}
+	// on error, the content is the last known content of the group
+	return wrapGroups(i, groups), wrapRepositories(i, repositories), err
+}
+
+func namespace(i int, id uint64) uint64 {
+	return uint64(i+1)<<namespaceShift | id&idMask
+}
+
+func unnamespace(id uint64) (int, uint64) {
+	return int(id>>namespaceShift) - 1, id & idMask
+}
+
+func wrapGroups(i int, groups map[string]fstree.GroupSource) map[string]fstree.GroupSource {
+	wrappedGroups := make(map[string]fstree.GroupSource, len(groups))
+	for name, group := range groups {
+		wrappedGroups[name] = &namespacedGroup{
+			GroupSource: group,
+			id:          namespace(i, group.GetGroupID()),
+		}
+	}
+	return wrappedGroups
+}
+
+func wrapRepositories(i int, repositories map[string]fstree.RepositorySource) map[string]fstree.RepositorySource {
+	wrappedRepositories := make(map[string]fstree.RepositorySource, len(repositories))
+	for name, repository := range repositories {
+		wrappedRepositories[name] = &namespacedRepository{
+			RepositorySource: repository,
+			id:               namespace(i, repository.GetRepositoryID()),
+		}
+	}
+	return wrappedRepositories
+}
@@ -0,0 +1,133 @@
+package composite_test
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/badjware/gitforgefs/forges/composite"
+	"github.com/badjware/gitforgefs/fstree"
+)
+
+type testGroup struct {
+	id uint64
+}
+
+func (g *testGroup) GetGroupID() uint64      { return g.id }
+func (g *testGroup) InvalidateContentCache() {}
+
+type testRepository struct {
+	id uint64
+}
+
+func (r *testRepository) GetRepositoryID() uint64  { return r.id }
+func (r *testRepository) GetCloneURL() string      { return "https://example.com/test.git" }
+func (r *testRepository) GetDefaultBranch() string { return "main" }
+
+// testForge has a single root group with id 1 containing a repository with id 1
+type testForge struct {
+	invalidated bool
+}
+
+func (f *testForge) InvalidateRootContentCache() { f.invalidated = true }
+
+func (f *testForge) FetchRootGroupContent() (map[string]fstree.GroupSource, error) {
+	return map[string]fstree.GroupSource{"group": &testGroup{id: 1}}, nil
+}
+
+func (f *testForge) FetchGroupContent(gid uint64) (map[string]fstree.GroupSource, map[string]fstree.RepositorySource, error) {
+	return map[string]fstree.GroupSource{}, map[string]fstree.RepositorySource{"repo": &testRepository{id: 1}}, nil
+}
+
+func TestCompositeClient(t *testing.T) {
+	client, err := composite.NewClient(slog.Default(), []composite.Forge{
+		{Name: "first", GitForge: &testForge{}},
+		{Name: "second", GitForge: &testForge{}},
+	})
+	if err != nil {
+		t.Fatalf("NewClient() returned error: %v", err)
+	}
+
+	rootContent, _ := client.FetchRootGroupContent()
+	if len(rootContent) != 2 {
+		t.Fatalf("FetchRootGroupContent() returned %v; expected the 2 forges", rootContent)
+	}
+
+	groupIDs := map[uint64]bool{}
+	repositoryIDs := map[uint64]bool{}
+	for _, forgeName := range []string{"first", "second"} {
+		forgeGroups, _, err := client.FetchGroupContent(rootContent[forgeName].GetGroupID())
+		if err != nil || len(forgeGroups) != 1 {
+			t.Fatalf("FetchGroupContent() of forge %v returned %v; error: %v", forgeName, forgeGroups, err)
+		}
+		group := forgeGroups["group"]
+		groupIDs[group.GetGroupID()] = true
+
+		_, repositories, err := client.FetchGroupContent(group.GetGroupID())
+		if err != nil || len(repositories) != 1 {
+			t.Fatalf("FetchGroupContent() of group of forge %v returned %v; error: %v", forgeName, repositories, err)
+		}
+		repository := repositories["repo"]
+		repositoryIDs[repository.GetRepositoryID()] = true
+		if rid := fstree.UnwrapRepositorySource(repository).GetRepositoryID(); rid != 1 {
+			t.Fatalf("unwrapped repository of forge %v has id %v; expected 1", forgeName, rid)
+		}
+	}
+	if len(groupIDs) != 2 || len(repositoryIDs) != 2 {
+		t.Fatalf("group ids %v and repository ids %v of different forges collide", groupIDs, repositoryIDs)
+	}
+}
+
+func TestCompositeClientSetForges(t *testing.T) {
+	first := &testForge{}
+	client, err := composite.NewClient(slog.Default(), []composite.Forge{
+		{Name: "first", GitForge: first},
+		{Name: "second", GitForge: &testForge{}},
+	})
+	if err != nil {
+		t.Fatalf("NewClient() returned error: %v", err)
+	}
+	rootContent, _ := client.FetchRootGroupContent()
+
+	// remove the second forge and add a third one
+	if err := client.SetForges([]composite.Forge{
+		{Name: "third", GitForge: &testForge{}},
+		{Name: "first", GitForge: first},
+	}); err != nil {
+		t.Fatalf("SetForges() returned error: %v", err)
+	}
+	newRootContent, _ := client.FetchRootGroupContent()
+
+	if len(newRootContent) != 2 || newRootContent["first"] != rootContent["first"] {
+		t.Fatalf("SetForges() replaced the unchanged forge: %v; expected %v", newRootContent["first"], rootContent["first"])
+	}
+	if _, _, err := client.FetchGroupContent(rootContent["second"].GetGroupID()); err == nil {
+		t.Fatalf("FetchGroupContent() of a removed forge returned no error")
+	}
+	if newRootContent["third"].GetGroupID() == rootContent["second"].GetGroupID() {
+		t.Fatalf("new forge reuses the id %v of a removed forge", rootContent["second"].GetGroupID())
+	}
+}
+
+func TestCompositeClientRefresh(t *testing.T) {
+	first, second := &testForge{}, &testForge{}
+	client, err := composite.NewClient(slog.Default(), []composite.Forge{
+		{Name: "first", GitForge: first},
+		{Name: "second", GitForge: second},
+	})
+	if err != nil {
+		t.Fatalf("NewClient() returned error: %v", err)
+	}
+
+	// refreshing the top-level directory of a forge refreshes the root content of the forge
+	rootContent, _ := client.FetchRootGroupContent()
+	rootContent["first"].InvalidateContentCache()
+	if !first.invalidated || second.invalidated {
+		t.Fatalf("refreshing the first forge invalidated first: %v, second: %v; expected only the first", first.invalidated, second.invalidated)
+	}
+
+	// refreshing the root refreshes every forges
+	client.InvalidateRootContentCache()
+	if !second.invalidated {
+		t.Fatalf("refreshing the root did not invalidate the second forge")
+	}
+}
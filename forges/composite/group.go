@@ -0,0 +1,30 @@
+package composite
+
+import (
+	"github.com/badjware/gitforgefs/fstree"
+)
+
+// forgeGroup is the top-level directory of a forge
+type forgeGroup struct {
+	id    uint64
+	forge fstree.GitForge
+}
+
+func (g *forgeGroup) GetGroupID() uint64 {
+	return g.id
+}
+
+func (g *forgeGroup) InvalidateContentCache() {
+	if invalidator, ok := g.forge.(fstree.RootContentCacheInvalidator); ok {
+		invalidator.InvalidateRootContentCache()
+	}
+}
+
+type namespacedGroup struct {
+	fstree.GroupSource
+	id uint64
+}
+
+func (g *namespacedGroup) GetGroupID() uint64 {
+	return g.id
+}
@@ -0,0 +1,18 @@
+package composite
+
+import (
+	"github.com/badjware/gitforgefs/fstree"
+)
+
+type namespacedRepository struct {
+	fstree.RepositorySource
+	id uint64
+}
+
+func (r *namespacedRepository) GetRepositoryID() uint64 {
+	return r.id
+}
+
+func (r *namespacedRepository) Unwrap() fstree.RepositorySource {
+	return r.RepositorySource
+}
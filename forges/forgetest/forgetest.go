@@ -0,0 +1,79 @@
+// Package forgetest implements utilities for testing the forges against a stand-in for their api.
+package forgetest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/badjware/gitforgefs/fstree"
+)
+
+// NewServer starts a stand-in for the api of a forge answering the given responses by request uri. {{url}} is
+// replaced by the url of the server. If authorization is not empty, the requests without this Authorization header
+// are rejected.
+func NewServer(t *testing.T, authorization string, responses map[string]string) *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if authorization != "" && r.Header.Get("Authorization") != authorization {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		response, found := responses[r.URL.RequestURI()]
+		if !found {
+			t.Logf("unexpected request: %v", r.URL.RequestURI())
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"message":"404 Not Found"}`)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, strings.ReplaceAll(response, "{{url}}", server.URL))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// ListTree returns the path of every groups of the forge, sorted, along with every repositories of the forge by path
+func ListTree(t *testing.T, forge fstree.GitForge) ([]string, map[string]fstree.RepositorySource) {
+	groupPaths := []string{}
+	repositories := map[string]fstree.RepositorySource{}
+
+	var walk func(prefix string, gid uint64)
+	walk = func(prefix string, gid uint64) {
+		groups, repos, err := forge.FetchGroupContent(gid)
+		if err != nil {
+			t.Fatalf("FetchGroupContent(%v) returned error: %v", prefix, err)
+		}
+		for name, group := range groups {
+			groupPaths = append(groupPaths, prefix+"/"+name)
+			walk(prefix+"/"+name, group.GetGroupID())
+		}
+		for name, repo := range repos {
+			repositories[prefix+"/"+name] = repo
+		}
+	}
+
+	rootGroups, err := forge.FetchRootGroupContent()
+	if err != nil {
+		t.Fatalf("FetchRootGroupContent() returned error: %v", err)
+	}
+	for name, group := range rootGroups {
+		groupPaths = append(groupPaths, name)
+		walk(name, group.GetGroupID())
+	}
+	sort.Strings(groupPaths)
+	return groupPaths, repositories
+}
+
+// RepositoryIDs returns the id of each of repositories
+func RepositoryIDs(repositories map[string]fstree.RepositorySource) map[string]uint64 {
+	ids := make(map[string]uint64, len(repositories))
+	for path, repository := range repositories {
+		ids[path] = repository.GetRepositoryID()
+	}
+	return ids
+}
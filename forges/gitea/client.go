@@ -3,20 +3,40 @@ package gitea
 import (
 	"fmt"
 	"log/slog"
+	"slices"
 	"sync"
+	"time"
 
 	"code.gitea.io/sdk/gitea"
+	"github.com/badjware/gitforgefs/cache"
 	"github.com/badjware/gitforgefs/config"
+	"github.com/badjware/gitforgefs/filter"
 	"github.com/badjware/gitforgefs/fstree"
+	"github.com/badjware/gitforgefs/metrics"
+)
+
+const (
+	organizationKind = "organization"
+	userKind         = "user"
+	teamKind         = "team"
+
+	currentUserMetadataKey = "current_user"
 )
 
 type giteaClient struct {
 	config.GiteaClientConfig
 	client *gitea.Client
+	filter *filter.Filter
 
 	logger *slog.Logger
 
+	store   *cache.Store
+	metrics *metrics.ForgeMetrics
+
+	rootMux     sync.Mutex
 	rootContent map[string]fstree.GroupSource
+	// the last root content fetched without error, served while the root cannot be fully fetched
+	lastRootContent map[string]fstree.GroupSource
 
 	// API response cache
 	organizationCacheMux    sync.RWMutex
@@ -25,47 +45,146 @@ type giteaClient struct {
 	userCacheMux            sync.RWMutex
 	userNameToIDMap         map[string]int64
 	userCache               map[int64]*User
+	subdirectories          *cache.Subdirectories
 }
 
-func NewClient(logger *slog.Logger, config config.GiteaClientConfig) (*giteaClient, error) {
+func NewClient(logger *slog.Logger, config config.GiteaClientConfig, store *cache.Store, forgeMetrics *metrics.ForgeMetrics) (*giteaClient, error) {
 	client, err := gitea.NewClient(config.URL, gitea.SetToken(config.Token))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create the gitea client: %v", err)
 	}
+	repositoryFilter, err := filter.New(config.Filters)
+	if err != nil {
+		return nil, err
+	}
 
 	giteaClient := &giteaClient{
 		GiteaClientConfig: config,
 		client:            client,
+		filter:            repositoryFilter,
 
 		logger: logger,
 
+		store:   store,
+		metrics: forgeMetrics,
+
 		rootContent: nil,
 
 		organizationNameToIDMap: map[string]int64{},
 		organizationCache:       map[int64]*Organization{},
 		userNameToIDMap:         map[string]int64{},
 		userCache:               map[int64]*User{},
+		subdirectories:          cache.NewSubdirectories(logger, store),
 	}
 
-	// Fetch current user and add it to the list
-	currentUser, _, err := client.GetMyUserInfo()
-	if err != nil {
-		logger.Warn("failed to fetch the current user:", "error", err.Error())
+	// Add the current user to the list
+	// if the filesystem can be served from the metadata cache, the current user is resolved in the background
+	if giteaClient.restore() {
+		go giteaClient.resolveCurrentUser()
 	} else {
-		giteaClient.UserNames = append(giteaClient.UserNames, *&currentUser.UserName)
+		giteaClient.resolveCurrentUser()
 	}
 
 	return giteaClient, nil
 }
 
+func (c *giteaClient) resolveCurrentUser() {
+	start := time.Now()
+	currentUser, _, err := c.client.GetMyUserInfo()
+	c.metrics.ObserveRequest("GetMyUserInfo", start, err)
+	if err != nil {
+		c.logger.Warn("failed to fetch the current user:", "error", err.Error())
+		return
+	}
+	currentUserName := currentUser.UserName
+
+	c.rootMux.Lock()
+	defer c.rootMux.Unlock()
+	cachedCurrentUserName, found := c.store.GetMetadata(currentUserMetadataKey)
+	if found && cachedCurrentUserName == currentUserName {
+		return
+	}
+	if found {
+		// the token changed hands since the metadata cache was saved
+		c.UserNames = slices.DeleteFunc(c.UserNames, func(userName string) bool { return userName == cachedCurrentUserName })
+	}
+	c.UserNames = append(c.UserNames, currentUserName)
+	c.rootContent = nil
+
+	c.store.SetMetadata(currentUserMetadataKey, currentUserName)
+}
+
+// restore populates the caches from the metadata cache. It returns false if there was nothing to restore.
+func (c *giteaClient) restore() bool {
+	snapshot := c.store.Load(c.filter.CacheSource(c.URL))
+	if snapshot == nil {
+		return false
+	}
+
+	for _, groupSnapshot := range snapshot.Groups {
+		childRepositories := restoreRepositories(groupSnapshot)
+
+		var content *cache.ContentCache
+		childGroups := make(map[string]fstree.GroupSource)
+		switch groupSnapshot.Kind {
+		case organizationKind:
+			org := c.newOrganization(int64(groupSnapshot.ID), groupSnapshot.Name)
+			c.organizationCache[org.ID] = org
+			c.organizationNameToIDMap[org.Name] = org.ID
+			content = org.content
+			childGroups = c.subdirectories.Restore(org, groupSnapshot, snapshot, restoreRepositories)
+		case userKind:
+			user := c.newUser(int64(groupSnapshot.ID), groupSnapshot.Name)
+			c.userCache[user.ID] = user
+			c.userNameToIDMap[user.Name] = user.ID
+			content = user.content
+		default:
+			continue
+		}
+		if !groupSnapshot.FetchedAt.IsZero() {
+			content.Restore(childGroups, childRepositories, groupSnapshot.FetchedAt)
+		}
+	}
+
+	// restore the current user
+	if currentUserName, found := c.store.GetMetadata(currentUserMetadataKey); found {
+		c.UserNames = append(c.UserNames, currentUserName)
+	}
+
+	return true
+}
+
+func restoreRepositories(groupSnapshot *cache.GroupSnapshot) map[string]fstree.RepositorySource {
+	repositories := make(map[string]fstree.RepositorySource, len(groupSnapshot.Repositories))
+	for name, repositorySnapshot := range groupSnapshot.Repositories {
+		repositories[name] = &Repository{
+			ID:            int64(repositorySnapshot.ID),
+			FullPath:      repositorySnapshot.FullPath,
+			Path:          name,
+			CloneURL:      repositorySnapshot.CloneURL,
+			DefaultBranch: repositorySnapshot.DefaultBranch,
+
+			Metadata: repositorySnapshot.Metadata,
+		}
+	}
+	return repositories
+}
+
 func (c *giteaClient) FetchRootGroupContent() (map[string]fstree.GroupSource, error) {
+	c.rootMux.Lock()
+	defer c.rootMux.Unlock()
+
 	if c.rootContent == nil {
 		rootContent := make(map[string]fstree.GroupSource)
+		var fetchErr error
 
 		for _, orgName := range c.GiteaClientConfig.OrgNames {
 			org, err := c.fetchOrganization(orgName)
 			if err != nil {
 				c.logger.Warn(err.Error())
+				if fetchErr == nil {
+					fetchErr = err
+				}
 			} else {
 				rootContent[org.Name] = org
 			}
@@ -75,22 +194,64 @@ func (c *giteaClient) FetchRootGroupContent() (map[string]fstree.GroupSource, er
 			user, err := c.fetchUser(userName)
 			if err != nil {
 				c.logger.Warn(err.Error())
+				if fetchErr == nil {
+					fetchErr = err
+				}
 			} else {
 				rootContent[user.Name] = user
 			}
 		}
 
+		if fetchErr != nil {
+			// retry on the next call instead of caching an incomplete root, and serve the last complete root meanwhile
+			if c.lastRootContent != nil {
+				rootContent = c.lastRootContent
+			}
+			return rootContent, fmt.Errorf("failed to fetch the root content: %v", fetchErr)
+		}
 		c.rootContent = rootContent
+		c.lastRootContent = rootContent
+		c.store.SetRoot(rootContent)
 	}
 	return c.rootContent, nil
 }
 
+func (c *giteaClient) InvalidateRootContentCache() {
+	c.rootMux.Lock()
+	c.rootContent = nil
+	c.rootMux.Unlock()
+
+	// pick up a change of the current user
+	go c.resolveCurrentUser()
+}
+
 func (c *giteaClient) FetchGroupContent(gid uint64) (map[string]fstree.GroupSource, map[string]fstree.RepositorySource, error) {
-	if org, found := c.organizationCache[int64(gid)]; found {
+	c.organizationCacheMux.RLock()
+	org, found := c.organizationCache[int64(gid)]
+	c.organizationCacheMux.RUnlock()
+	if found {
 		return c.fetchOrganizationContent(org)
 	}
-	if user, found := c.userCache[int64(gid)]; found {
+
+	c.userCacheMux.RLock()
+	user, found := c.userCache[int64(gid)]
+	c.userCacheMux.RUnlock()
+	if found {
 		return c.fetchUserContent(user)
 	}
+
+	subdirectory, found := c.subdirectories.Get(gid)
+	if found {
+		return subdirectory.FetchContent(func() (map[string]fstree.GroupSource, map[string]fstree.RepositorySource, error) {
+			return c.fetchOrganizationContent(subdirectory.Parent.(*Organization))
+		})
+	}
 	return nil, nil, fmt.Errorf("invalid gid: %v", gid)
 }
+
+func (c *giteaClient) rootCacheTTL(name string) time.Duration {
+	if ttl, found := c.CacheTTLOverrides[name]; found {
+		return ttl
+	}
+	return c.CacheTTL
+}
@@ -0,0 +1,82 @@
+package gitea_test
+
+import (
+	"log/slog"
+	"reflect"
+	"testing"
+
+	"github.com/badjware/gitforgefs/config"
+	"github.com/badjware/gitforgefs/forges/forgetest"
+	"github.com/badjware/gitforgefs/forges/gitea"
+)
+
+// testOrgResponses are the responses of the stand-in for the api of gitea to list an organization and its teams
+var testOrgResponses = map[string]string{
+	"/api/v1/version":       `{"version": "1.22.0"}`,
+	"/api/v1/user":          `{"id": 1, "login": "test-user"}`,
+	"/api/v1/orgs/test-org": `{"id": 10, "username": "test-org"}`,
+	"/api/v1/orgs/test-org/repos?limit=100&page=1":   `[{"id": 100, "name": "api", "full_name": "test-org/api"}, {"id": 101, "name": "web", "full_name": "test-org/web"}, {"id": 102, "name": "upstream", "full_name": "test-org/upstream", "mirror": true}, {"id": 103, "name": "misc", "full_name": "test-org/misc"}]`,
+	"/api/v1/orgs/test-org/teams?limit=100&page=1":   `[{"id": 19, "name": "Owners", "includes_all_repositories": true}, {"id": 20, "name": "backend"}, {"id": 21, "name": "frontend"}]`,
+	"/api/v1/teams/20/repos?limit=100&page=1":        `[{"id": 100, "name": "api"}, {"id": 102, "name": "upstream"}]`,
+	"/api/v1/teams/21/repos?limit=100&page=1":        `[{"id": 100, "name": "api"}, {"id": 101, "name": "web"}]`,
+	"/api/v1/users/test-user":                        `{"id": 1, "login": "test-user"}`,
+	"/api/v1/users/test-user/repos?limit=100&page=1": `[]`,
+}
+
+func TestOrgSubdirectories(t *testing.T) {
+	tests := map[string]struct {
+		forbidTeams bool
+		expected    map[string]uint64
+	}{
+		"Teams": {
+			expected: map[string]uint64{
+				"test-org/teams/backend/api":  100,
+				"test-org/teams/frontend/api": 100,
+				"test-org/teams/frontend/web": 101,
+				"test-org/misc":               103,
+			},
+		},
+		"TeamsForbidden": {
+			// such as an organization the token user is not a member of
+			forbidTeams: true,
+			expected: map[string]uint64{
+				"test-org/api":  100,
+				"test-org/web":  101,
+				"test-org/misc": 103,
+			},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			responses := map[string]string{}
+			for uri, response := range testOrgResponses {
+				responses[uri] = response
+			}
+			if test.forbidTeams {
+				delete(responses, "/api/v1/orgs/test-org/teams?limit=100&page=1")
+			}
+			server := forgetest.NewServer(t, "", responses)
+
+			client, err := gitea.NewClient(slog.Default(), config.GiteaClientConfig{
+				URL:                  server.URL,
+				OrgNames:             []string{"test-org"},
+				UserNames:            []string{},
+				ArchivedRepoHandling: config.ArchivedProjectHide,
+				MirrorHandling:       config.MirrorIgnore,
+				OrgSubdirectories:    []string{config.OrgSubdirectoryTeams},
+				PullMethod:           config.PullMethodHTTP,
+			}, nil, nil)
+			if err != nil {
+				t.Fatalf("NewClient() returned error: %v", err)
+			}
+
+			_, repositories := forgetest.ListTree(t, client)
+			if got := forgetest.RepositoryIDs(repositories); !reflect.DeepEqual(got, test.expected) {
+				t.Fatalf("forge has repositories %v; expected %v", got, test.expected)
+			}
+		})
+	}
+}
@@ -2,9 +2,10 @@ package gitea
 
 import (
 	"fmt"
-	"sync"
+	"time"
 
 	"code.gitea.io/sdk/gitea"
+	"github.com/badjware/gitforgefs/cache"
 	"github.com/badjware/gitforgefs/fstree"
 )
 
@@ -12,10 +13,8 @@ type Organization struct {
 	ID   int64
 	Name string
 
-	mux sync.Mutex
-
 	// hold org content
-	childRepositories map[string]fstree.RepositorySource
+	content *cache.ContentCache
 }
 
 func (o *Organization) GetGroupID() uint64 {
@@ -23,11 +22,19 @@ func (o *Organization) GetGroupID() uint64 {
 }
 
 func (o *Organization) InvalidateContentCache() {
-	o.mux.Lock()
-	defer o.mux.Unlock()
-
 	// clear child repositories from cache
-	o.childRepositories = nil
+	o.content.Invalidate()
+}
+
+func (c *giteaClient) newOrganization(id int64, name string) *Organization {
+	org := &Organization{
+		ID:   id,
+		Name: name,
+
+		content: cache.NewContentCache(c.logger, c.rootCacheTTL(name)),
+	}
+	c.store.Track(uint64(id), name, organizationKind, org.content)
+	return org
 }
 
 func (c *giteaClient) fetchOrganization(orgName string) (*Organization, error) {
@@ -39,41 +46,35 @@ func (c *giteaClient) fetchOrganization(orgName string) (*Organization, error) {
 
 		// if found in cache, return the cached reference
 		c.logger.Debug("Organization cache hit", "org_name", orgName)
+		c.metrics.ObserveCacheLookup(organizationKind, true)
 		return cachedOrg, nil
 	} else {
 		c.organizationCacheMux.RUnlock()
 
 		c.logger.Debug("Organization cache miss", "org_name", orgName)
+		c.metrics.ObserveCacheLookup(organizationKind, false)
 	}
 
 	// If not found in cache, fetch organization infos from API
+	start := time.Now()
 	giteaOrg, _, err := c.client.GetOrg(orgName)
+	c.metrics.ObserveRequest("GetOrg", start, err)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch organization with name %v: %v", orgName, err)
 	}
-	newOrg := Organization{
-		ID:   giteaOrg.ID,
-		Name: giteaOrg.UserName,
-
-		childRepositories: nil,
-	}
+	newOrg := c.newOrganization(giteaOrg.ID, giteaOrg.UserName)
 
 	// save in cache
 	c.organizationCacheMux.Lock()
-	c.organizationCache[newOrg.ID] = &newOrg
+	c.organizationCache[newOrg.ID] = newOrg
 	c.organizationNameToIDMap[newOrg.Name] = newOrg.ID
 	c.organizationCacheMux.Unlock()
 
-	return &newOrg, nil
+	return newOrg, nil
 }
 
 func (c *giteaClient) fetchOrganizationContent(org *Organization) (map[string]fstree.GroupSource, map[string]fstree.RepositorySource, error) {
-	org.mux.Lock()
-	defer org.mux.Unlock()
-
-	// Get cached data if available
-	// TODO: cache cache invalidation?
-	if org.childRepositories == nil {
+	return org.content.Get(func() (map[string]fstree.GroupSource, map[string]fstree.RepositorySource, error) {
 		childRepositories := make(map[string]fstree.RepositorySource)
 
 		// Fetch the organization repositories
@@ -81,7 +82,9 @@ func (c *giteaClient) fetchOrganizationContent(org *Organization) (map[string]fs
 			ListOptions: gitea.ListOptions{PageSize: 100},
 		}
 		for {
+			start := time.Now()
 			giteaRepositories, response, err := c.client.ListOrgRepos(org.Name, gitea.ListOrgReposOptions(listReposOptions))
+			c.metrics.ObserveRequest("ListOrgRepos", start, err)
 			if err != nil {
 				return nil, nil, fmt.Errorf("failed to fetch repository in gitea: %v", err)
 			}
@@ -98,7 +101,10 @@ func (c *giteaClient) fetchOrganizationContent(org *Organization) (map[string]fs
 			listReposOptions.Page = response.NextPage
 		}
 
-		org.childRepositories = childRepositories
-	}
-	return make(map[string]fstree.GroupSource), org.childRepositories, nil
+		if len(c.OrgSubdirectories) > 0 {
+			childGroups, childRepositories := c.groupBySubdirectories(org, childRepositories)
+			return childGroups, childRepositories, nil
+		}
+		return make(map[string]fstree.GroupSource), childRepositories, nil
+	})
 }
@@ -2,22 +2,32 @@ package gitea
 
 import (
 	"path"
+	"time"
 
 	"code.gitea.io/sdk/gitea"
 	"github.com/badjware/gitforgefs/config"
+	"github.com/badjware/gitforgefs/filter"
+	"github.com/badjware/gitforgefs/fstree"
 )
 
 type Repository struct {
 	ID            int64
+	FullPath      string
 	Path          string
 	CloneURL      string
 	DefaultBranch string
+
+	Metadata *fstree.RepositoryMetadata
 }
 
 func (r *Repository) GetRepositoryID() uint64 {
 	return uint64(r.ID)
 }
 
+func (r *Repository) GetRepositoryPath() string {
+	return r.FullPath
+}
+
 func (r *Repository) GetCloneURL() string {
 	return r.CloneURL
 }
@@ -26,25 +36,80 @@ func (r *Repository) GetDefaultBranch() string {
 	return r.DefaultBranch
 }
 
+func (r *Repository) GetMetadata() *fstree.RepositoryMetadata {
+	return r.Metadata
+}
+
 func (c *giteaClient) newRepositoryFromGiteaRepository(repository *gitea.Repository) *Repository {
 	if c.ArchivedRepoHandling == config.ArchivedProjectIgnore && repository.Archived {
 		return nil
 	}
+	if c.ForkHandling == config.ForkIgnore && repository.Fork {
+		return nil
+	}
+	if c.MirrorHandling == config.MirrorIgnore && repository.Mirror {
+		return nil
+	}
+	// the topics are fetched for each repository, skip the repositories filtered out by their path first
+	if !c.filter.MatchPath(repository.FullName) {
+		return nil
+	}
 	r := Repository{
 		ID:            repository.ID,
+		FullPath:      repository.FullName,
 		Path:          repository.Name,
 		DefaultBranch: repository.DefaultBranch,
 	}
 	if r.DefaultBranch == "" {
 		r.DefaultBranch = "master"
 	}
+	r.Metadata = &fstree.RepositoryMetadata{
+		Description:   repository.Description,
+		WebURL:        repository.HTMLURL,
+		Visibility:    "public",
+		DefaultBranch: r.DefaultBranch,
+		Stars:         repository.Stars,
+		Archived:      repository.Archived,
+	}
+	if repository.Private {
+		r.Metadata.Visibility = "private"
+	} else if repository.Internal {
+		r.Metadata.Visibility = "internal"
+	}
+	if !repository.Updated.IsZero() {
+		r.Metadata.LastActivity = &repository.Updated
+	}
+	if !c.filter.MatchRepository(filter.Repository{
+		FullPath:   repository.FullName,
+		Topics:     c.fetchTopics(repository),
+		Visibility: r.Metadata.Visibility,
+		Fork:       repository.Fork,
+	}) {
+		return nil
+	}
 	if c.PullMethod == config.PullMethodSSH {
 		r.CloneURL = repository.SSHURL
 	} else {
 		r.CloneURL = repository.CloneURL
 	}
-	if c.ArchivedRepoHandling == config.ArchivedProjectHide && repository.Archived {
+	if (c.ArchivedRepoHandling == config.ArchivedProjectHide && repository.Archived) || (c.ForkHandling == config.ForkHide && repository.Fork) || (c.MirrorHandling == config.MirrorHide && repository.Mirror) {
 		r.Path = path.Join(path.Dir(r.Path), "."+path.Base(r.Path))
 	}
 	return &r
 }
+
+// fetchTopics returns the topics of the repository. They are not returned when listing the repositories, so they are
+// only fetched when the repositories are filtered on their topics.
+func (c *giteaClient) fetchTopics(repository *gitea.Repository) []string {
+	if !c.filter.HasTopics() || repository.Owner == nil {
+		return nil
+	}
+	start := time.Now()
+	topics, _, err := c.client.ListRepoTopics(repository.Owner.UserName, repository.Name, gitea.ListRepoTopicsOptions{})
+	c.metrics.ObserveRequest("ListRepoTopics", start, err)
+	if err != nil {
+		c.logger.Warn("failed to fetch the topics of the repository", "repository", repository.FullName, "error", err.Error())
+		return nil
+	}
+	return topics
+}
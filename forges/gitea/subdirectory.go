@@ -0,0 +1,80 @@
+package gitea
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"code.gitea.io/sdk/gitea"
+	"github.com/badjware/gitforgefs/cache"
+	"github.com/badjware/gitforgefs/config"
+	"github.com/badjware/gitforgefs/fstree"
+)
+
+// groupBySubdirectories moves the repositories of the organization to its subdirectories, as configured by
+// org_subdirectories. The repositories that don't belong to any subdirectory are left in the organization.
+func (c *giteaClient) groupBySubdirectories(org *Organization, repositories map[string]fstree.RepositorySource) (map[string]fstree.GroupSource, map[string]fstree.RepositorySource) {
+	groupings := []cache.Grouping{}
+	if slices.Contains(c.OrgSubdirectories, config.OrgSubdirectoryTeams) {
+		teamRepositories, err := c.fetchTeamRepositories(org)
+		if err != nil {
+			// such as an organization the token user is not a member of, the repositories are left in the organization
+			c.logger.Warn("Failed to group the repositories of the organization by team", "org_name", org.Name, "error", err.Error())
+		} else {
+			groupings = append(groupings, cache.Grouping{Name: config.OrgSubdirectoryTeams, Kind: teamKind, Members: teamRepositories})
+		}
+	}
+
+	return c.subdirectories.Group(org, repositories, groupings)
+}
+
+// fetchTeamRepositories returns the ids of the repositories of each team of the organization, by team name. The teams
+// including every repository of the organization are skipped.
+func (c *giteaClient) fetchTeamRepositories(org *Organization) (map[string][]uint64, error) {
+	var teams []*gitea.Team
+	listTeamsOptions := gitea.ListTeamsOptions{
+		ListOptions: gitea.ListOptions{PageSize: 100},
+	}
+	for {
+		start := time.Now()
+		giteaTeams, response, err := c.client.ListOrgTeams(org.Name, listTeamsOptions)
+		c.metrics.ObserveRequest("ListOrgTeams", start, err)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch teams of organization %v in gitea: %v", org.Name, err)
+		}
+		teams = append(teams, giteaTeams...)
+		if response.NextPage == 0 {
+			break
+		}
+		// Get the next page
+		listTeamsOptions.Page = response.NextPage
+	}
+
+	teamRepositories := make(map[string][]uint64, len(teams))
+	for _, team := range teams {
+		if team.IncludesAllRepositories {
+			// teams such as "Owners" hold every repository of the organization, they would leave it empty
+			continue
+		}
+		listReposOptions := gitea.ListTeamRepositoriesOptions{
+			ListOptions: gitea.ListOptions{PageSize: 100},
+		}
+		for {
+			start := time.Now()
+			giteaRepositories, response, err := c.client.ListTeamRepositories(team.ID, listReposOptions)
+			c.metrics.ObserveRequest("ListTeamRepositories", start, err)
+			if err != nil {
+				return nil, fmt.Errorf("failed to fetch repositories of team %v in gitea: %v", team.Name, err)
+			}
+			for _, giteaRepository := range giteaRepositories {
+				teamRepositories[team.Name] = append(teamRepositories[team.Name], uint64(giteaRepository.ID))
+			}
+			if response.NextPage == 0 {
+				break
+			}
+			// Get the next page
+			listReposOptions.Page = response.NextPage
+		}
+	}
+	return teamRepositories, nil
+}
@@ -2,9 +2,10 @@ package gitea
 
 import (
 	"fmt"
-	"sync"
+	"time"
 
 	"code.gitea.io/sdk/gitea"
+	"github.com/badjware/gitforgefs/cache"
 	"github.com/badjware/gitforgefs/fstree"
 )
 
@@ -12,10 +13,8 @@ type User struct {
 	ID   int64
 	Name string
 
-	mux sync.Mutex
-
 	// hold user content
-	childRepositories map[string]fstree.RepositorySource
+	content *cache.ContentCache
 }
 
 func (u *User) GetGroupID() uint64 {
@@ -23,11 +22,19 @@ func (u *User) GetGroupID() uint64 {
 }
 
 func (u *User) InvalidateContentCache() {
-	u.mux.Lock()
-	defer u.mux.Unlock()
-
 	// clear child repositories from cache
-	u.childRepositories = nil
+	u.content.Invalidate()
+}
+
+func (c *giteaClient) newUser(id int64, name string) *User {
+	user := &User{
+		ID:   id,
+		Name: name,
+
+		content: cache.NewContentCache(c.logger, c.rootCacheTTL(name)),
+	}
+	c.store.Track(uint64(id), name, userKind, user.content)
+	return user
 }
 
 func (c *giteaClient) fetchUser(userName string) (*User, error) {
@@ -39,41 +46,35 @@ func (c *giteaClient) fetchUser(userName string) (*User, error) {
 
 		// if found in cache, return the cached reference
 		c.logger.Debug("User cache hit", "user_name", userName)
+		c.metrics.ObserveCacheLookup(userKind, true)
 		return cachedUser, nil
 	} else {
 		c.userCacheMux.RUnlock()
 
 		c.logger.Debug("User cache miss", "user_name", userName)
+		c.metrics.ObserveCacheLookup(userKind, false)
 	}
 
 	// If not found in cache, fetch user infos from API
+	start := time.Now()
 	giteaUser, _, err := c.client.GetUserInfo(userName)
+	c.metrics.ObserveRequest("GetUserInfo", start, err)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch user with name %v: %v", userName, err)
 	}
-	newUser := User{
-		ID:   giteaUser.ID,
-		Name: giteaUser.UserName,
-
-		childRepositories: nil,
-	}
+	newUser := c.newUser(giteaUser.ID, giteaUser.UserName)
 
 	// save in cache
 	c.userCacheMux.Lock()
-	c.userCache[newUser.ID] = &newUser
+	c.userCache[newUser.ID] = newUser
 	c.userNameToIDMap[newUser.Name] = newUser.ID
 	c.userCacheMux.Unlock()
 
-	return &newUser, nil
+	return newUser, nil
 }
 
 func (c *giteaClient) fetchUserContent(user *User) (map[string]fstree.GroupSource, map[string]fstree.RepositorySource, error) {
-	user.mux.Lock()
-	defer user.mux.Unlock()
-
-	// Get cached data if available
-	// TODO: cache cache invalidation?
-	if user.childRepositories == nil {
+	return user.content.Get(func() (map[string]fstree.GroupSource, map[string]fstree.RepositorySource, error) {
 		childRepositories := make(map[string]fstree.RepositorySource)
 
 		// Fetch the user repositories
@@ -81,7 +82,9 @@ func (c *giteaClient) fetchUserContent(user *User) (map[string]fstree.GroupSourc
 			ListOptions: gitea.ListOptions{PageSize: 100},
 		}
 		for {
+			start := time.Now()
 			giteaRepositories, response, err := c.client.ListUserRepos(user.Name, listReposOptions)
+			c.metrics.ObserveRequest("ListUserRepos", start, err)
 			if err != nil {
 				return nil, nil, fmt.Errorf("failed to fetch repository in gitea: %v", err)
 			}
@@ -98,7 +101,6 @@ func (c *giteaClient) fetchUserContent(user *User) (map[string]fstree.GroupSourc
 			listReposOptions.Page = response.NextPage
 		}
 
-		user.childRepositories = childRepositories
-	}
-	return make(map[string]fstree.GroupSource), user.childRepositories, nil
+		return make(map[string]fstree.GroupSource), childRepositories, nil
+	})
 }
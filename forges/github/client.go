@@ -4,20 +4,41 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"slices"
 	"sync"
+	"time"
 
+	"github.com/badjware/gitforgefs/cache"
 	"github.com/badjware/gitforgefs/config"
+	"github.com/badjware/gitforgefs/filter"
 	"github.com/badjware/gitforgefs/fstree"
+	"github.com/badjware/gitforgefs/metrics"
 	"github.com/google/go-github/v63/github"
 )
 
+const (
+	organizationKind = "organization"
+	userKind         = "user"
+	teamKind         = "team"
+	topicKind        = "topic"
+
+	currentUserMetadataKey = "current_user"
+)
+
 type githubClient struct {
 	config.GithubClientConfig
 	client *github.Client
+	filter *filter.Filter
 
 	logger *slog.Logger
 
+	store   *cache.Store
+	metrics *metrics.ForgeMetrics
+
+	rootMux     sync.Mutex
 	rootContent map[string]fstree.GroupSource
+	// the last root content fetched without error, served while the root cannot be fully fetched
+	lastRootContent map[string]fstree.GroupSource
 
 	// API response cache
 	organizationCacheMux    sync.RWMutex
@@ -26,47 +47,150 @@ type githubClient struct {
 	userCacheMux            sync.RWMutex
 	userNameToIDMap         map[string]int64
 	userCache               map[int64]*User
+	subdirectories          *cache.Subdirectories
 }
 
-func NewClient(logger *slog.Logger, config config.GithubClientConfig) (*githubClient, error) {
+func NewClient(logger *slog.Logger, config config.GithubClientConfig, store *cache.Store, forgeMetrics *metrics.ForgeMetrics) (*githubClient, error) {
 	client := github.NewClient(nil)
 	if config.Token != "" {
 		client = client.WithAuthToken(config.Token)
 	}
+	return newClient(logger, config, client, store, forgeMetrics)
+}
+
+func newClient(logger *slog.Logger, config config.GithubClientConfig, client *github.Client, store *cache.Store, forgeMetrics *metrics.ForgeMetrics) (*githubClient, error) {
+	repositoryFilter, err := filter.New(config.Filters)
+	if err != nil {
+		return nil, err
+	}
 
 	gitHubClient := &githubClient{
 		GithubClientConfig: config,
 		client:             client,
+		filter:             repositoryFilter,
 
 		logger: logger,
 
+		store:   store,
+		metrics: forgeMetrics,
+
 		rootContent: nil,
 
 		organizationNameToIDMap: map[string]int64{},
 		organizationCache:       map[int64]*Organization{},
 		userNameToIDMap:         map[string]int64{},
 		userCache:               map[int64]*User{},
+		subdirectories:          cache.NewSubdirectories(logger, store),
 	}
 
-	// Fetch current user and add it to the list
-	currentUser, _, err := client.Users.Get(context.Background(), "")
-	if err != nil {
-		logger.Warn("failed to fetch the current user:", "error", err.Error())
+	// Add the current user to the list
+	// if the filesystem can be served from the metadata cache, the current user is resolved in the background
+	if gitHubClient.restore() {
+		go gitHubClient.resolveCurrentUser()
 	} else {
-		gitHubClient.UserNames = append(gitHubClient.UserNames, *currentUser.Login)
+		gitHubClient.resolveCurrentUser()
 	}
 
 	return gitHubClient, nil
 }
 
+func (c *githubClient) resolveCurrentUser() {
+	start := time.Now()
+	currentUser, _, err := c.client.Users.Get(context.Background(), "")
+	c.metrics.ObserveRequest("Users.Get", start, err)
+	if err != nil {
+		c.logger.Warn("failed to fetch the current user:", "error", err.Error())
+		return
+	}
+	currentUserName := *currentUser.Login
+
+	c.rootMux.Lock()
+	defer c.rootMux.Unlock()
+	cachedCurrentUserName, found := c.store.GetMetadata(currentUserMetadataKey)
+	if found && cachedCurrentUserName == currentUserName {
+		return
+	}
+	if found {
+		// the token changed hands since the metadata cache was saved
+		c.UserNames = slices.DeleteFunc(c.UserNames, func(userName string) bool { return userName == cachedCurrentUserName })
+	}
+	c.UserNames = append(c.UserNames, currentUserName)
+	c.rootContent = nil
+
+	c.store.SetMetadata(currentUserMetadataKey, currentUserName)
+}
+
+// restore populates the caches from the metadata cache. It returns false if there was nothing to restore.
+func (c *githubClient) restore() bool {
+	snapshot := c.store.Load(c.filter.CacheSource("https://github.com"))
+	if snapshot == nil {
+		return false
+	}
+
+	for _, groupSnapshot := range snapshot.Groups {
+		childRepositories := restoreRepositories(groupSnapshot)
+
+		var content *cache.ContentCache
+		childGroups := make(map[string]fstree.GroupSource)
+		switch groupSnapshot.Kind {
+		case organizationKind:
+			org := c.newOrganization(int64(groupSnapshot.ID), groupSnapshot.Name)
+			c.organizationCache[org.ID] = org
+			c.organizationNameToIDMap[org.Name] = org.ID
+			content = org.content
+			childGroups = c.subdirectories.Restore(org, groupSnapshot, snapshot, restoreRepositories)
+		case userKind:
+			user := c.newUser(int64(groupSnapshot.ID), groupSnapshot.Name)
+			c.userCache[user.ID] = user
+			c.userNameToIDMap[user.Name] = user.ID
+			content = user.content
+		default:
+			continue
+		}
+		if !groupSnapshot.FetchedAt.IsZero() {
+			content.Restore(childGroups, childRepositories, groupSnapshot.FetchedAt)
+		}
+	}
+
+	// restore the current user
+	if currentUserName, found := c.store.GetMetadata(currentUserMetadataKey); found {
+		c.UserNames = append(c.UserNames, currentUserName)
+	}
+
+	return true
+}
+
+func restoreRepositories(groupSnapshot *cache.GroupSnapshot) map[string]fstree.RepositorySource {
+	repositories := make(map[string]fstree.RepositorySource, len(groupSnapshot.Repositories))
+	for name, repositorySnapshot := range groupSnapshot.Repositories {
+		repositories[name] = &Repository{
+			ID:            int64(repositorySnapshot.ID),
+			FullPath:      repositorySnapshot.FullPath,
+			Path:          name,
+			CloneURL:      repositorySnapshot.CloneURL,
+			DefaultBranch: repositorySnapshot.DefaultBranch,
+
+			Metadata: repositorySnapshot.Metadata,
+		}
+	}
+	return repositories
+}
+
 func (c *githubClient) FetchRootGroupContent() (map[string]fstree.GroupSource, error) {
+	c.rootMux.Lock()
+	defer c.rootMux.Unlock()
+
 	if c.rootContent == nil {
 		rootContent := make(map[string]fstree.GroupSource)
+		var fetchErr error
 
 		for _, orgName := range c.GithubClientConfig.OrgNames {
 			org, err := c.fetchOrganization(orgName)
 			if err != nil {
 				c.logger.Warn(err.Error())
+				if fetchErr == nil {
+					fetchErr = err
+				}
 			} else {
 				rootContent[org.Name] = org
 			}
@@ -76,22 +200,64 @@ func (c *githubClient) FetchRootGroupContent() (map[string]fstree.GroupSource, e
 			user, err := c.fetchUser(userName)
 			if err != nil {
 				c.logger.Warn(err.Error())
+				if fetchErr == nil {
+					fetchErr = err
+				}
 			} else {
 				rootContent[user.Name] = user
 			}
 		}
 
+		if fetchErr != nil {
+			// retry on the next call instead of caching an incomplete root, and serve the last complete root meanwhile
+			if c.lastRootContent != nil {
+				rootContent = c.lastRootContent
+			}
+			return rootContent, fmt.Errorf("failed to fetch the root content: %v", fetchErr)
+		}
 		c.rootContent = rootContent
+		c.lastRootContent = rootContent
+		c.store.SetRoot(rootContent)
 	}
 	return c.rootContent, nil
 }
 
+func (c *githubClient) InvalidateRootContentCache() {
+	c.rootMux.Lock()
+	c.rootContent = nil
+	c.rootMux.Unlock()
+
+	// pick up a change of the current user
+	go c.resolveCurrentUser()
+}
+
 func (c *githubClient) FetchGroupContent(gid uint64) (map[string]fstree.GroupSource, map[string]fstree.RepositorySource, error) {
-	if org, found := c.organizationCache[int64(gid)]; found {
+	c.organizationCacheMux.RLock()
+	org, found := c.organizationCache[int64(gid)]
+	c.organizationCacheMux.RUnlock()
+	if found {
 		return c.fetchOrganizationContent(org)
 	}
-	if user, found := c.userCache[int64(gid)]; found {
+
+	c.userCacheMux.RLock()
+	user, found := c.userCache[int64(gid)]
+	c.userCacheMux.RUnlock()
+	if found {
 		return c.fetchUserContent(user)
 	}
+
+	subdirectory, found := c.subdirectories.Get(gid)
+	if found {
+		return subdirectory.FetchContent(func() (map[string]fstree.GroupSource, map[string]fstree.RepositorySource, error) {
+			return c.fetchOrganizationContent(subdirectory.Parent.(*Organization))
+		})
+	}
 	return nil, nil, fmt.Errorf("invalid gid: %v", gid)
 }
+
+func (c *githubClient) rootCacheTTL(name string) time.Duration {
+	if ttl, found := c.CacheTTLOverrides[name]; found {
+		return ttl
+	}
+	return c.CacheTTL
+}
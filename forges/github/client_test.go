@@ -0,0 +1,194 @@
+package github_test
+
+import (
+	"log/slog"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/badjware/gitforgefs/cache"
+	"github.com/badjware/gitforgefs/config"
+	"github.com/badjware/gitforgefs/forges/forgetest"
+	"github.com/badjware/gitforgefs/forges/github"
+	"github.com/badjware/gitforgefs/fstree"
+)
+
+// testOrgResponses are the responses of the stand-in for the api of github to list an organization and its teams
+var testOrgResponses = map[string]string{
+	"/user":                               `{"id": 1, "login": "test-user"}`,
+	"/users/test-user":                    `{"id": 1, "login": "test-user"}`,
+	"/users/test-user/repos?per_page=100": `[]`,
+	"/orgs/test-org":                      `{"id": 10, "login": "test-org"}`,
+	"/orgs/test-org/repos?per_page=100": `[
+		{"id": 100, "name": "api", "full_name": "test-org/api", "clone_url": "https://github.com/test-org/api.git", "default_branch": "main", "archived": false, "topics": ["go"]},
+		{"id": 101, "name": "web", "full_name": "test-org/web", "clone_url": "https://github.com/test-org/web.git", "default_branch": "main", "archived": false, "topics": ["go", "frontend"]},
+		{"id": 102, "name": "misc", "full_name": "test-org/misc", "clone_url": "https://github.com/test-org/misc.git", "default_branch": "main", "archived": false}
+	]`,
+	"/orgs/test-org/teams?per_page=100":                `[{"id": 20, "slug": "backend"}, {"id": 21, "slug": "frontend"}]`,
+	"/orgs/test-org/teams/backend/repos?per_page=100":  `[{"id": 100, "name": "api"}, {"id": 103, "name": "filtered-out"}]`,
+	"/orgs/test-org/teams/frontend/repos?per_page=100": `[{"id": 101, "name": "web"}]`,
+}
+
+// groupID returns the id of the group at path in the forge
+func groupID(t *testing.T, forge fstree.GitForge, path string) uint64 {
+	names := strings.Split(path, "/")
+	groups, err := forge.FetchRootGroupContent()
+	if err != nil {
+		t.Fatalf("FetchRootGroupContent() returned error: %v", err)
+	}
+	for i, name := range names {
+		group, found := groups[name]
+		if !found {
+			t.Fatalf("group %v not found", path)
+		}
+		if i == len(names)-1 {
+			return group.GetGroupID()
+		}
+		if groups, _, err = forge.FetchGroupContent(group.GetGroupID()); err != nil {
+			t.Fatalf("FetchGroupContent(%v) returned error: %v", name, err)
+		}
+	}
+	return 0
+}
+
+func TestOrgSubdirectories(t *testing.T) {
+	tests := map[string]struct {
+		subdirectories       []string
+		forbidTeams          bool
+		expectedGroups       []string
+		expectedRepositories map[string]uint64
+	}{
+		"Teams": {
+			subdirectories: []string{config.OrgSubdirectoryTeams},
+			expectedGroups: []string{"test-org", "test-org/teams", "test-org/teams/backend", "test-org/teams/frontend", "test-user"},
+			expectedRepositories: map[string]uint64{
+				"test-org/teams/backend/api":  100,
+				"test-org/teams/frontend/web": 101,
+				"test-org/misc":               102,
+			},
+		},
+		"TeamsAndTopics": {
+			subdirectories: []string{config.OrgSubdirectoryTeams, config.OrgSubdirectoryTopics},
+			expectedGroups: []string{"test-org", "test-org/teams", "test-org/teams/backend", "test-org/teams/frontend", "test-org/topics", "test-org/topics/frontend", "test-org/topics/go", "test-user"},
+			expectedRepositories: map[string]uint64{
+				"test-org/teams/backend/api":   100,
+				"test-org/teams/frontend/web":  101,
+				"test-org/topics/go/api":       100,
+				"test-org/topics/go/web":       101,
+				"test-org/topics/frontend/web": 101,
+				"test-org/misc":                102,
+			},
+		},
+		"TeamsForbidden": {
+			// such as a token without the read:org scope
+			subdirectories: []string{config.OrgSubdirectoryTeams},
+			forbidTeams:    true,
+			expectedGroups: []string{"test-org", "test-user"},
+			expectedRepositories: map[string]uint64{
+				"test-org/api":  100,
+				"test-org/web":  101,
+				"test-org/misc": 102,
+			},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			responses := map[string]string{}
+			for uri, response := range testOrgResponses {
+				responses[uri] = response
+			}
+			if test.forbidTeams {
+				delete(responses, "/orgs/test-org/teams?per_page=100")
+			}
+			server := forgetest.NewServer(t, "Bearer 12345", responses)
+
+			forge, err := github.NewClientWithBaseURL(slog.Default(), config.GithubClientConfig{
+				Token:                "12345",
+				OrgNames:             []string{"test-org"},
+				ArchivedRepoHandling: config.ArchivedProjectShow,
+				OrgSubdirectories:    test.subdirectories,
+				PullMethod:           config.PullMethodHTTP,
+			}, nil, server.URL)
+			if err != nil {
+				t.Fatalf("NewClient() returned error: %v", err)
+			}
+
+			groupPaths, repositories := forgetest.ListTree(t, forge)
+			if !reflect.DeepEqual(groupPaths, test.expectedGroups) {
+				t.Fatalf("forge has groups %v; expected %v", groupPaths, test.expectedGroups)
+			}
+			if got := forgetest.RepositoryIDs(repositories); !reflect.DeepEqual(got, test.expectedRepositories) {
+				t.Fatalf("forge has repositories %v; expected %v", got, test.expectedRepositories)
+			}
+		})
+	}
+}
+
+func TestOrgSubdirectoriesRestore(t *testing.T) {
+	server := forgetest.NewServer(t, "Bearer 12345", testOrgResponses)
+	githubConfig := config.GithubClientConfig{
+		Token:                "12345",
+		OrgNames:             []string{"test-org"},
+		ArchivedRepoHandling: config.ArchivedProjectShow,
+		OrgSubdirectories:    []string{config.OrgSubdirectoryTeams},
+		PullMethod:           config.PullMethodHTTP,
+	}
+	path := filepath.Join(t.TempDir(), "github.json")
+
+	store := cache.NewStore(slog.Default(), path)
+	forge, err := github.NewClientWithBaseURL(slog.Default(), githubConfig, store, server.URL)
+	if err != nil {
+		t.Fatalf("NewClient() returned error: %v", err)
+	}
+	groupPaths, repositories := forgetest.ListTree(t, forge)
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close() returned error: %v", err)
+	}
+
+	// the subdirectories are served from the metadata cache, with the same ids, while the forge is unreachable
+	server.Close()
+	restoredForge, err := github.NewClientWithBaseURL(slog.Default(), githubConfig, cache.NewStore(slog.Default(), path), server.URL)
+	if err != nil {
+		t.Fatalf("NewClient() returned error: %v", err)
+	}
+	restoredGroupPaths, restoredRepositories := forgetest.ListTree(t, restoredForge)
+	if !reflect.DeepEqual(restoredGroupPaths, groupPaths) {
+		t.Fatalf("restored forge has groups %v; expected %v", restoredGroupPaths, groupPaths)
+	}
+	if got, expected := forgetest.RepositoryIDs(restoredRepositories), forgetest.RepositoryIDs(repositories); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("restored forge has repositories %v; expected %v", got, expected)
+	}
+	for _, name := range []string{"teams", "teams/backend"} {
+		gid := groupID(t, forge, "test-org/"+name)
+		if restoredGID := groupID(t, restoredForge, "test-org/"+name); restoredGID != gid {
+			t.Fatalf("restored subdirectory %v has id %v; expected %v", name, restoredGID, gid)
+		}
+	}
+}
+
+func TestRootPartialFailure(t *testing.T) {
+	server := forgetest.NewServer(t, "Bearer 12345", testOrgResponses)
+	forge, err := github.NewClientWithBaseURL(slog.Default(), config.GithubClientConfig{
+		Token: "12345",
+		// the api of github answers 404 for gone-org
+		OrgNames:             []string{"test-org", "gone-org"},
+		ArchivedRepoHandling: config.ArchivedProjectShow,
+		PullMethod:           config.PullMethodHTTP,
+	}, nil, server.URL)
+	if err != nil {
+		t.Fatalf("NewClient() returned error: %v", err)
+	}
+
+	// the root is reported as failed instead of silently missing gone-org
+	rootContent, err := forge.FetchRootGroupContent()
+	if err == nil {
+		t.Fatalf("FetchRootGroupContent() returned no error; expected an error for gone-org")
+	}
+	if _, found := rootContent["test-org"]; !found {
+		t.Fatalf("FetchRootGroupContent() returned %v; expected the organizations that could be fetched", rootContent)
+	}
+}
@@ -0,0 +1,21 @@
+package github
+
+import (
+	"log/slog"
+	"net/url"
+
+	"github.com/badjware/gitforgefs/cache"
+	"github.com/badjware/gitforgefs/config"
+	"github.com/google/go-github/v63/github"
+)
+
+// NewClientWithBaseURL creates a client of the api of github served at baseURL
+func NewClientWithBaseURL(logger *slog.Logger, config config.GithubClientConfig, store *cache.Store, baseURL string) (*githubClient, error) {
+	client := github.NewClient(nil).WithAuthToken(config.Token)
+	parsedURL, err := url.Parse(baseURL + "/")
+	if err != nil {
+		return nil, err
+	}
+	client.BaseURL = parsedURL
+	return newClient(logger, config, client, store, nil)
+}
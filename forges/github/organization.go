@@ -3,8 +3,9 @@ package github
 import (
 	"context"
 	"fmt"
-	"sync"
+	"time"
 
+	"github.com/badjware/gitforgefs/cache"
 	"github.com/badjware/gitforgefs/fstree"
 	"github.com/google/go-github/v63/github"
 )
@@ -13,10 +14,8 @@ type Organization struct {
 	ID   int64
 	Name string
 
-	mux sync.Mutex
-
 	// hold org content
-	childRepositories map[string]fstree.RepositorySource
+	content *cache.ContentCache
 }
 
 func (o *Organization) GetGroupID() uint64 {
@@ -24,11 +23,19 @@ func (o *Organization) GetGroupID() uint64 {
 }
 
 func (o *Organization) InvalidateContentCache() {
-	o.mux.Lock()
-	defer o.mux.Unlock()
-
 	// clear child repositories from cache
-	o.childRepositories = nil
+	o.content.Invalidate()
+}
+
+func (c *githubClient) newOrganization(id int64, name string) *Organization {
+	org := &Organization{
+		ID:   id,
+		Name: name,
+
+		content: cache.NewContentCache(c.logger, c.rootCacheTTL(name)),
+	}
+	c.store.Track(uint64(id), name, organizationKind, org.content)
+	return org
 }
 
 func (c *githubClient) fetchOrganization(orgName string) (*Organization, error) {
@@ -40,49 +47,46 @@ func (c *githubClient) fetchOrganization(orgName string) (*Organization, error)
 
 		// if found in cache, return the cached reference
 		c.logger.Debug("Organization cache hit", "org_name", orgName)
+		c.metrics.ObserveCacheLookup(organizationKind, true)
 		return cachedOrg, nil
 	} else {
 		c.organizationCacheMux.RUnlock()
 
 		c.logger.Debug("Organization cache miss", "org_name", orgName)
+		c.metrics.ObserveCacheLookup(organizationKind, false)
 	}
 
 	// If not found in cache, fetch organization infos from API
+	start := time.Now()
 	githubOrg, _, err := c.client.Organizations.Get(context.Background(), orgName)
+	c.metrics.ObserveRequest("Organizations.Get", start, err)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch organization with name %v: %v", orgName, err)
 	}
-	newOrg := Organization{
-		ID:   *githubOrg.ID,
-		Name: *githubOrg.Login,
-
-		childRepositories: nil,
-	}
+	newOrg := c.newOrganization(*githubOrg.ID, *githubOrg.Login)
 
 	// save in cache
 	c.organizationCacheMux.Lock()
-	c.organizationCache[newOrg.ID] = &newOrg
+	c.organizationCache[newOrg.ID] = newOrg
 	c.organizationNameToIDMap[newOrg.Name] = newOrg.ID
 	c.organizationCacheMux.Unlock()
 
-	return &newOrg, nil
+	return newOrg, nil
 }
 
 func (c *githubClient) fetchOrganizationContent(org *Organization) (map[string]fstree.GroupSource, map[string]fstree.RepositorySource, error) {
-	org.mux.Lock()
-	defer org.mux.Unlock()
-
-	// Get cached data if available
-	// TODO: cache cache invalidation?
-	if org.childRepositories == nil {
+	return org.content.Get(func() (map[string]fstree.GroupSource, map[string]fstree.RepositorySource, error) {
 		childRepositories := make(map[string]fstree.RepositorySource)
+		topics := make(map[int64][]string)
 
 		// Fetch the organization repositories
 		repositoryListOpt := &github.RepositoryListByOrgOptions{
 			ListOptions: github.ListOptions{PerPage: 100},
 		}
 		for {
+			start := time.Now()
 			githubRepositories, response, err := c.client.Repositories.ListByOrg(context.Background(), org.Name, repositoryListOpt)
+			c.metrics.ObserveRequest("Repositories.ListByOrg", start, err)
 			if err != nil {
 				return nil, nil, fmt.Errorf("failed to fetch repository in github: %v", err)
 			}
@@ -90,6 +94,7 @@ func (c *githubClient) fetchOrganizationContent(org *Organization) (map[string]f
 				repository := c.newRepositoryFromGithubRepository(githubRepository)
 				if repository != nil {
 					childRepositories[repository.Path] = repository
+					topics[repository.ID] = githubRepository.Topics
 				}
 			}
 			if response.NextPage == 0 {
@@ -99,7 +104,10 @@ func (c *githubClient) fetchOrganizationContent(org *Organization) (map[string]f
 			repositoryListOpt.Page = response.NextPage
 		}
 
-		org.childRepositories = childRepositories
-	}
-	return make(map[string]fstree.GroupSource), org.childRepositories, nil
+		if len(c.OrgSubdirectories) > 0 {
+			childGroups, childRepositories := c.groupBySubdirectories(org, childRepositories, topics)
+			return childGroups, childRepositories, nil
+		}
+		return make(map[string]fstree.GroupSource), childRepositories, nil
+	})
 }
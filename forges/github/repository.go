@@ -4,20 +4,29 @@ import (
 	"path"
 
 	"github.com/badjware/gitforgefs/config"
+	"github.com/badjware/gitforgefs/filter"
+	"github.com/badjware/gitforgefs/fstree"
 	"github.com/google/go-github/v63/github"
 )
 
 type Repository struct {
 	ID            int64
+	FullPath      string
 	Path          string
 	CloneURL      string
 	DefaultBranch string
+
+	Metadata *fstree.RepositoryMetadata
 }
 
 func (r *Repository) GetRepositoryID() uint64 {
 	return uint64(r.ID)
 }
 
+func (r *Repository) GetRepositoryPath() string {
+	return r.FullPath
+}
+
 func (r *Repository) GetCloneURL() string {
 	return r.CloneURL
 }
@@ -26,24 +35,58 @@ func (r *Repository) GetDefaultBranch() string {
 	return r.DefaultBranch
 }
 
+func (r *Repository) GetMetadata() *fstree.RepositoryMetadata {
+	return r.Metadata
+}
+
 func (c *githubClient) newRepositoryFromGithubRepository(repository *github.Repository) *Repository {
 	if c.ArchivedRepoHandling == config.ArchivedProjectIgnore && *repository.Archived {
 		return nil
 	}
+	if c.ForkHandling == config.ForkIgnore && repository.GetFork() {
+		return nil
+	}
 	r := Repository{
 		ID:            *repository.ID,
+		FullPath:      repository.GetFullName(),
 		Path:          *repository.Name,
 		DefaultBranch: *repository.DefaultBranch,
 	}
 	if r.DefaultBranch == "" {
 		r.DefaultBranch = "master"
 	}
+	r.Metadata = &fstree.RepositoryMetadata{
+		Description:   repository.GetDescription(),
+		WebURL:        repository.GetHTMLURL(),
+		Visibility:    repository.GetVisibility(),
+		DefaultBranch: r.DefaultBranch,
+		Stars:         repository.GetStargazersCount(),
+		Archived:      repository.GetArchived(),
+	}
+	if r.Metadata.Visibility == "" {
+		// the visibility is not returned by older versions of the api
+		r.Metadata.Visibility = "public"
+		if repository.GetPrivate() {
+			r.Metadata.Visibility = "private"
+		}
+	}
+	if repository.PushedAt != nil {
+		r.Metadata.LastActivity = &repository.PushedAt.Time
+	}
+	if !c.filter.MatchRepository(filter.Repository{
+		FullPath:   repository.GetFullName(),
+		Topics:     repository.Topics,
+		Visibility: r.Metadata.Visibility,
+		Fork:       repository.GetFork(),
+	}) {
+		return nil
+	}
 	if c.PullMethod == config.PullMethodSSH {
 		r.CloneURL = *repository.SSHURL
 	} else {
 		r.CloneURL = *repository.CloneURL
 	}
-	if c.ArchivedRepoHandling == config.ArchivedProjectHide && *repository.Archived {
+	if (c.ArchivedRepoHandling == config.ArchivedProjectHide && *repository.Archived) || (c.ForkHandling == config.ForkHide && repository.GetFork()) {
 		r.Path = path.Join(path.Dir(r.Path), "."+path.Base(r.Path))
 	}
 	return &r
@@ -0,0 +1,81 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/badjware/gitforgefs/cache"
+	"github.com/badjware/gitforgefs/config"
+	"github.com/badjware/gitforgefs/fstree"
+	"github.com/google/go-github/v63/github"
+)
+
+// groupBySubdirectories moves the repositories of the organization to its subdirectories, as configured by
+// org_subdirectories. The repositories that don't belong to any subdirectory are left in the organization.
+func (c *githubClient) groupBySubdirectories(org *Organization, repositories map[string]fstree.RepositorySource, topics map[int64][]string) (map[string]fstree.GroupSource, map[string]fstree.RepositorySource) {
+	groupings := []cache.Grouping{}
+	if slices.Contains(c.OrgSubdirectories, config.OrgSubdirectoryTeams) {
+		teamRepositories, err := c.fetchTeamRepositories(org)
+		if err != nil {
+			// such as a token without the read:org scope, the repositories are left in the organization
+			c.logger.Warn("Failed to group the repositories of the organization by team", "org_name", org.Name, "error", err.Error())
+		} else {
+			groupings = append(groupings, cache.Grouping{Name: config.OrgSubdirectoryTeams, Kind: teamKind, Members: teamRepositories})
+		}
+	}
+	if slices.Contains(c.OrgSubdirectories, config.OrgSubdirectoryTopics) {
+		topicRepositories := make(map[string][]uint64)
+		for id, repositoryTopics := range topics {
+			for _, topic := range repositoryTopics {
+				topicRepositories[topic] = append(topicRepositories[topic], uint64(id))
+			}
+		}
+		groupings = append(groupings, cache.Grouping{Name: config.OrgSubdirectoryTopics, Kind: topicKind, Members: topicRepositories})
+	}
+
+	return c.subdirectories.Group(org, repositories, groupings)
+}
+
+// fetchTeamRepositories returns the ids of the repositories of each team of the organization, by team slug
+func (c *githubClient) fetchTeamRepositories(org *Organization) (map[string][]uint64, error) {
+	var teams []*github.Team
+	teamListOpt := &github.ListOptions{PerPage: 100}
+	for {
+		start := time.Now()
+		githubTeams, response, err := c.client.Teams.ListTeams(context.Background(), org.Name, teamListOpt)
+		c.metrics.ObserveRequest("Teams.ListTeams", start, err)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch teams of organization %v in github: %v", org.Name, err)
+		}
+		teams = append(teams, githubTeams...)
+		if response.NextPage == 0 {
+			break
+		}
+		// Get the next page
+		teamListOpt.Page = response.NextPage
+	}
+
+	teamRepositories := make(map[string][]uint64, len(teams))
+	for _, team := range teams {
+		repositoryListOpt := &github.ListOptions{PerPage: 100}
+		for {
+			start := time.Now()
+			githubRepositories, response, err := c.client.Teams.ListTeamReposBySlug(context.Background(), org.Name, team.GetSlug(), repositoryListOpt)
+			c.metrics.ObserveRequest("Teams.ListTeamReposBySlug", start, err)
+			if err != nil {
+				return nil, fmt.Errorf("failed to fetch repositories of team %v in github: %v", team.GetSlug(), err)
+			}
+			for _, githubRepository := range githubRepositories {
+				teamRepositories[team.GetSlug()] = append(teamRepositories[team.GetSlug()], uint64(githubRepository.GetID()))
+			}
+			if response.NextPage == 0 {
+				break
+			}
+			// Get the next page
+			repositoryListOpt.Page = response.NextPage
+		}
+	}
+	return teamRepositories, nil
+}
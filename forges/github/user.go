@@ -3,8 +3,9 @@ package github
 import (
 	"context"
 	"fmt"
-	"sync"
+	"time"
 
+	"github.com/badjware/gitforgefs/cache"
 	"github.com/badjware/gitforgefs/fstree"
 	"github.com/google/go-github/v63/github"
 )
@@ -13,10 +14,8 @@ type User struct {
 	ID   int64
 	Name string
 
-	mux sync.Mutex
-
 	// hold user content
-	childRepositories map[string]fstree.RepositorySource
+	content *cache.ContentCache
 }
 
 func (u *User) GetGroupID() uint64 {
@@ -24,11 +23,19 @@ func (u *User) GetGroupID() uint64 {
 }
 
 func (u *User) InvalidateContentCache() {
-	u.mux.Lock()
-	defer u.mux.Unlock()
-
 	// clear child repositories from cache
-	u.childRepositories = nil
+	u.content.Invalidate()
+}
+
+func (c *githubClient) newUser(id int64, name string) *User {
+	user := &User{
+		ID:   id,
+		Name: name,
+
+		content: cache.NewContentCache(c.logger, c.rootCacheTTL(name)),
+	}
+	c.store.Track(uint64(id), name, userKind, user.content)
+	return user
 }
 
 func (c *githubClient) fetchUser(userName string) (*User, error) {
@@ -40,41 +47,35 @@ func (c *githubClient) fetchUser(userName string) (*User, error) {
 
 		// if found in cache, return the cached reference
 		c.logger.Debug("User cache hit", "user_name", userName)
+		c.metrics.ObserveCacheLookup(userKind, true)
 		return cachedUser, nil
 	} else {
 		c.userCacheMux.RUnlock()
 
 		c.logger.Debug("User cache miss", "user_name", userName)
+		c.metrics.ObserveCacheLookup(userKind, false)
 	}
 
 	// If not found in cache, fetch user infos from API
+	start := time.Now()
 	githubUser, _, err := c.client.Users.Get(context.Background(), userName)
+	c.metrics.ObserveRequest("Users.Get", start, err)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch user with name %v: %v", userName, err)
 	}
-	newUser := User{
-		ID:   *githubUser.ID,
-		Name: *githubUser.Login,
-
-		childRepositories: nil,
-	}
+	newUser := c.newUser(*githubUser.ID, *githubUser.Login)
 
 	// save in cache
 	c.userCacheMux.Lock()
-	c.userCache[newUser.ID] = &newUser
+	c.userCache[newUser.ID] = newUser
 	c.userNameToIDMap[newUser.Name] = newUser.ID
 	c.userCacheMux.Unlock()
 
-	return &newUser, nil
+	return newUser, nil
 }
 
 func (c *githubClient) fetchUserContent(user *User) (map[string]fstree.GroupSource, map[string]fstree.RepositorySource, error) {
-	user.mux.Lock()
-	defer user.mux.Unlock()
-
-	// Get cached data if available
-	// TODO: cache cache invalidation?
-	if user.childRepositories == nil {
+	return user.content.Get(func() (map[string]fstree.GroupSource, map[string]fstree.RepositorySource, error) {
 		childRepositories := make(map[string]fstree.RepositorySource)
 
 		// Fetch the user repositories
@@ -82,7 +83,9 @@ func (c *githubClient) fetchUserContent(user *User) (map[string]fstree.GroupSour
 			ListOptions: github.ListOptions{PerPage: 100},
 		}
 		for {
+			start := time.Now()
 			githubRepositories, response, err := c.client.Repositories.ListByUser(context.Background(), user.Name, repositoryListOpt)
+			c.metrics.ObserveRequest("Repositories.ListByUser", start, err)
 			if err != nil {
 				return nil, nil, fmt.Errorf("failed to fetch repository in github: %v", err)
 			}
@@ -99,7 +102,6 @@ func (c *githubClient) fetchUserContent(user *User) (map[string]fstree.GroupSour
 			repositoryListOpt.Page = response.NextPage
 		}
 
-		user.childRepositories = childRepositories
-	}
-	return make(map[string]fstree.GroupSource), user.childRepositories, nil
+		return make(map[string]fstree.GroupSource), childRepositories, nil
+	})
 }
@@ -1,34 +1,67 @@
 package gitlab
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/badjware/gitforgefs/cache"
 	"github.com/badjware/gitforgefs/config"
+	"github.com/badjware/gitforgefs/filter"
 	"github.com/badjware/gitforgefs/fstree"
+	"github.com/badjware/gitforgefs/metrics"
 	"github.com/xanzy/go-gitlab"
 )
 
+const (
+	groupKind        = "group"
+	userKind         = "user"
+	subdirectoryKind = "subdirectory"
+
+	userIDsMetadataKey       = "user_ids"
+	currentUserIDMetadataKey = "current_user_id"
+	groupPathMetadataKey     = "group_path:"
+)
+
 type gitlabClient struct {
 	config.GitlabClientConfig
 	client *gitlab.Client
+	filter *filter.Filter
 
 	logger *slog.Logger
 
+	store   *cache.Store
+	metrics *metrics.ForgeMetrics
+
+	rootMux     sync.RWMutex
 	rootContent map[string]fstree.GroupSource
+	// the last root content fetched without error, served while the root cannot be fully fetched
+	lastRootContent map[string]fstree.GroupSource
 
-	userIDs []int
+	userIDs       []int
+	currentUserID int
+	// paths of the groups that could not be resolved yet because the forge was unreachable
+	pendingGroupPaths []string
 
 	// API response cache
 	groupCacheMux sync.RWMutex
 	groupCache    map[int]*Group
-	userCacheMux  sync.RWMutex
-	userCache     map[int]*User
+	// cache ttl inherited by the subgroups removed from the cache, restored when they are fetched again
+	groupCacheTTLs map[int]time.Duration
+	userCacheMux   sync.RWMutex
+	userCache      map[int]*User
+
+	subdirectoryCacheMux sync.RWMutex
+	subdirectoryCache    map[uint64]*UserSubdirectory
 }
 
-func NewClient(logger *slog.Logger, config config.GitlabClientConfig) (*gitlabClient, error) {
+func NewClient(logger *slog.Logger, config config.GitlabClientConfig, store *cache.Store, forgeMetrics *metrics.ForgeMetrics) (*gitlabClient, error) {
 	client, err := gitlab.NewClient(
 		config.Token,
 		gitlab.WithBaseURL(config.URL),
@@ -36,83 +69,347 @@ func NewClient(logger *slog.Logger, config config.GitlabClientConfig) (*gitlabCl
 	if err != nil {
 		return nil, fmt.Errorf("failed to create gitlab client: %v", err)
 	}
+	repositoryFilter, err := filter.New(config.Filters)
+	if err != nil {
+		return nil, err
+	}
 
 	gitlabClient := &gitlabClient{
 		GitlabClientConfig: config,
 		client:             client,
+		filter:             repositoryFilter,
 
 		logger: logger,
 
+		store:   store,
+		metrics: forgeMetrics,
+
 		rootContent: nil,
 
 		userIDs: []int{},
 
-		groupCache: map[int]*Group{},
-		userCache:  map[int]*User{},
+		groupCache:     map[int]*Group{},
+		groupCacheTTLs: map[int]time.Duration{},
+		userCache:      map[int]*User{},
+
+		subdirectoryCache: map[uint64]*UserSubdirectory{},
 	}
 
-	// Fetch current user and add it to the list
-	currentUser, _, err := client.Users.CurrentUser()
+	// the resolved groups are added to the group ids
+	gitlabClient.GroupIDs = slices.Clone(config.GroupIDs)
+
+	restored := gitlabClient.restore()
+	if err := gitlabClient.resolveGroups(); err != nil {
+		return nil, err
+	}
+
+	if restored {
+		// the filesystem can be served from the metadata cache, resolve the users in the background
+		go gitlabClient.resolveUsers()
+	} else {
+		gitlabClient.resolveUsers()
+	}
+
+	return gitlabClient, nil
+}
+
+// resolveGroups adds the groups listed by path or id in groups to the group ids. A path that doesn't exist or isn't
+// visible to the token is an error. If the forge is unreachable, the id saved in the metadata cache is used, or the
+// path is resolved once the forge can be reached.
+func (c *gitlabClient) resolveGroups() error {
+	for _, group := range c.Groups {
+		if gid, err := strconv.Atoi(group); err == nil {
+			c.addGroupID(gid)
+			continue
+		}
+
+		gid, err := c.resolveGroupPath(group)
+		if err != nil {
+			if !errors.Is(err, errGroupUnreachable) {
+				return err
+			}
+			if cachedGID, found := c.store.GetMetadata(groupPathMetadataKey + group); found {
+				if gid, err := strconv.Atoi(cachedGID); err == nil {
+					c.addGroupID(gid)
+					continue
+				}
+			}
+			c.logger.Warn("failed to resolve the group, retrying later", "group", group, "error", err.Error())
+			c.pendingGroupPaths = append(c.pendingGroupPaths, group)
+			continue
+		}
+		c.addGroupID(gid)
+	}
+	return nil
+}
+
+// resolvePendingGroups resolves the group paths that could not be resolved on startup. rootMux must be held.
+func (c *gitlabClient) resolvePendingGroups() {
+	pendingGroupPaths := []string{}
+	for _, group := range c.pendingGroupPaths {
+		gid, err := c.resolveGroupPath(group)
+		if err != nil {
+			c.logger.Warn("failed to resolve the group", "group", group, "error", err.Error())
+			if errors.Is(err, errGroupUnreachable) {
+				pendingGroupPaths = append(pendingGroupPaths, group)
+			}
+			continue
+		}
+		c.addGroupID(gid)
+	}
+	c.pendingGroupPaths = pendingGroupPaths
+}
+
+var errGroupUnreachable = errors.New("gitlab is unreachable")
+
+// resolveGroupPath returns the id of the group at fullPath
+func (c *gitlabClient) resolveGroupPath(fullPath string) (int, error) {
+	start := time.Now()
+	gitlabGroup, response, err := c.client.Groups.GetGroup(fullPath, &gitlab.GetGroupOptions{WithProjects: gitlab.Ptr(false)})
+	c.metrics.ObserveRequest("Groups.GetGroup", start, err)
 	if err != nil {
-		logger.Warn("failed to fetch the current user:", "error", err.Error())
+		if response != nil && (response.StatusCode == http.StatusNotFound || response.StatusCode == http.StatusForbidden) {
+			return 0, fmt.Errorf("gitlab group \"%v\" was not found or is not visible to the token", fullPath)
+		}
+		return 0, fmt.Errorf("%w: failed to fetch group \"%v\": %v", errGroupUnreachable, fullPath, err)
+	}
+	c.store.SetMetadata(groupPathMetadataKey+fullPath, strconv.Itoa(gitlabGroup.ID))
+	return gitlabGroup.ID, nil
+}
+
+func (c *gitlabClient) addGroupID(gid int) {
+	if !slices.Contains(c.GroupIDs, gid) {
+		c.GroupIDs = append(c.GroupIDs, gid)
+	}
+}
+
+// resolveUsers resolves the ids of the current user and of the configured users. If any of them cannot be fetched, the
+// users resolved before, or restored from the metadata cache, are kept as is.
+func (c *gitlabClient) resolveUsers() {
+	userIDs := []int{}
+	currentUserID := 0
+
+	// Fetch current user and add it to the list
+	start := time.Now()
+	currentUser, response, err := c.client.Users.CurrentUser()
+	c.metrics.ObserveRequest("Users.CurrentUser", start, err)
+	if err != nil && response != nil && response.StatusCode == http.StatusUnauthorized {
+		// anonymous access, there is no current user
+		c.logger.Warn("failed to fetch the current user:", "error", err.Error())
+	} else if err != nil {
+		c.logger.Warn("failed to fetch the current user, keeping the known users:", "error", err.Error())
+		return
 	} else {
-		gitlabClient.userIDs = append(gitlabClient.userIDs, currentUser.ID)
+		userIDs = append(userIDs, currentUser.ID)
+		currentUserID = currentUser.ID
 	}
 
 	// Fetch the configured users and add them to the list
-	for _, userName := range config.UserNames {
-		user, _, err := client.Users.ListUsers(&gitlab.ListUsersOptions{Username: &userName})
-		if err != nil || len(user) != 1 {
-			logger.Warn("failed to fetch the user", "userName", userName, "error", err.Error())
+	for _, userName := range c.UserNames {
+		start := time.Now()
+		user, _, err := c.client.Users.ListUsers(&gitlab.ListUsersOptions{Username: &userName})
+		c.metrics.ObserveRequest("Users.ListUsers", start, err)
+		if err != nil {
+			c.logger.Warn("failed to fetch the user, keeping the known users", "userName", userName, "error", err)
+			return
+		} else if len(user) != 1 {
+			c.logger.Warn("failed to fetch the user", "userName", userName, "error", "user not found")
 		} else {
-			gitlabClient.userIDs = append(gitlabClient.userIDs, user[0].ID)
+			userIDs = append(userIDs, user[0].ID)
 		}
 	}
 
-	return gitlabClient, nil
+	c.rootMux.Lock()
+	defer c.rootMux.Unlock()
+	if !slices.Equal(c.userIDs, userIDs) {
+		c.userIDs = userIDs
+		c.rootContent = nil
+	}
+	if currentUserID != 0 {
+		c.currentUserID = currentUserID
+		c.store.SetMetadata(currentUserIDMetadataKey, strconv.Itoa(currentUserID))
+	}
+
+	// save the users in the metadata cache
+	userIDStrings := make([]string, 0, len(userIDs))
+	for _, uid := range userIDs {
+		userIDStrings = append(userIDStrings, strconv.Itoa(uid))
+	}
+	c.store.SetMetadata(userIDsMetadataKey, strings.Join(userIDStrings, ","))
+}
+
+// restore populates the caches from the metadata cache. It returns false if there was nothing to restore.
+func (c *gitlabClient) restore() bool {
+	snapshot := c.store.Load(c.filter.CacheSource(c.URL))
+	if snapshot == nil {
+		return false
+	}
+
+	// restore the groups and users first so they can be referenced as child groups
+	for _, groupSnapshot := range snapshot.Groups {
+		switch groupSnapshot.Kind {
+		case groupKind:
+			c.groupCache[int(groupSnapshot.ID)] = c.newGroup(int(groupSnapshot.ID), groupSnapshot.Name, c.CacheTTL)
+		case userKind:
+			c.userCache[int(groupSnapshot.ID)] = c.newUser(int(groupSnapshot.ID), groupSnapshot.Name, c.CacheTTL)
+		}
+	}
+
+	// the subdirectories of the users are restored once their user exists
+	for _, groupSnapshot := range snapshot.Groups {
+		if groupSnapshot.Kind != subdirectoryKind {
+			continue
+		}
+		uid := int((groupSnapshot.ID &^ subdirectoryIDFlag) >> 1)
+		if user, found := c.userCache[uid]; found {
+			c.newUserSubdirectory(user, groupSnapshot.Name)
+		}
+	}
+
+	// restore the content
+	for _, groupSnapshot := range snapshot.Groups {
+		if groupSnapshot.FetchedAt.IsZero() {
+			continue
+		}
+		childGroups := make(map[string]fstree.GroupSource, len(groupSnapshot.Groups))
+		for name, gid := range groupSnapshot.Groups {
+			if childGroup, found := c.groupCache[int(gid)]; found {
+				childGroups[name] = childGroup
+			} else if subdirectory, found := c.subdirectoryCache[gid]; found {
+				childGroups[name] = subdirectory
+			}
+		}
+		childProjects := make(map[string]fstree.RepositorySource, len(groupSnapshot.Repositories))
+		for name, repositorySnapshot := range groupSnapshot.Repositories {
+			childProjects[name] = &Project{
+				ID:            int(repositorySnapshot.ID),
+				FullPath:      repositorySnapshot.FullPath,
+				Path:          name,
+				CloneURL:      repositorySnapshot.CloneURL,
+				DefaultBranch: repositorySnapshot.DefaultBranch,
+
+				Metadata: repositorySnapshot.Metadata,
+			}
+		}
+
+		if group, found := c.groupCache[int(groupSnapshot.ID)]; found {
+			group.content.Restore(childGroups, childProjects, groupSnapshot.FetchedAt)
+		} else if user, found := c.userCache[int(groupSnapshot.ID)]; found {
+			user.content.Restore(childGroups, childProjects, groupSnapshot.FetchedAt)
+		} else if subdirectory, found := c.subdirectoryCache[groupSnapshot.ID]; found {
+			subdirectory.content.Restore(childGroups, childProjects, groupSnapshot.FetchedAt)
+		}
+	}
+
+	// restore the users
+	if userIDs, found := c.store.GetMetadata(userIDsMetadataKey); found {
+		for _, uidString := range strings.Split(userIDs, ",") {
+			if uid, err := strconv.Atoi(uidString); err == nil {
+				c.userIDs = append(c.userIDs, uid)
+			}
+		}
+	}
+	if currentUserID, found := c.store.GetMetadata(currentUserIDMetadataKey); found {
+		c.currentUserID, _ = strconv.Atoi(currentUserID)
+	}
+
+	return true
 }
 
 func (c *gitlabClient) FetchRootGroupContent() (map[string]fstree.GroupSource, error) {
+	c.rootMux.Lock()
+	defer c.rootMux.Unlock()
+
 	// use cached values if available
 	if c.rootContent == nil {
 		rootGroupCache := make(map[string]fstree.GroupSource)
 
 		// fetch root groups
+		if len(c.pendingGroupPaths) > 0 {
+			c.resolvePendingGroups()
+		}
 		for _, gid := range c.GroupIDs {
-			group, err := c.fetchGroup(gid)
+			group, err := c.fetchGroup(gid, c.CacheTTL)
 			if err != nil {
-				return nil, err
+				return c.lastRootContent, err
 			}
+			group.content.SetTTL(c.rootCacheTTL(group.Name))
 			rootGroupCache[group.Name] = group
 		}
 		// fetch users
 		for _, uid := range c.userIDs {
-			user, err := c.fetchUser(uid)
+			user, err := c.fetchUser(uid, c.CacheTTL)
 			if err != nil {
-				return nil, err
+				return c.lastRootContent, err
 			}
+			user.content.SetTTL(c.rootCacheTTL(user.Name))
 			rootGroupCache[user.Name] = user
 		}
+		if len(c.pendingGroupPaths) > 0 {
+			// retry on the next call instead of caching a root missing the groups not resolved yet
+			if c.lastRootContent != nil {
+				rootGroupCache = c.lastRootContent
+			}
+			return rootGroupCache, fmt.Errorf("failed to resolve the groups %v", strings.Join(c.pendingGroupPaths, ", "))
+		}
 
 		c.rootContent = rootGroupCache
+		c.lastRootContent = rootGroupCache
+		c.store.SetRoot(rootGroupCache)
 	}
 	return c.rootContent, nil
 }
 
+func (c *gitlabClient) InvalidateRootContentCache() {
+	c.rootMux.Lock()
+	c.rootContent = nil
+	c.rootMux.Unlock()
+
+	// pick up a change of the current user or of the configured users
+	go c.resolveUsers()
+}
+
 func (c *gitlabClient) FetchGroupContent(gid uint64) (map[string]fstree.GroupSource, map[string]fstree.RepositorySource, error) {
-	if slices.Contains[[]int, int](c.userIDs, int(gid)) {
+	c.rootMux.RLock()
+	isUser := slices.Contains[[]int, int](c.userIDs, int(gid))
+	c.rootMux.RUnlock()
+
+	c.subdirectoryCacheMux.RLock()
+	subdirectory, isSubdirectory := c.subdirectoryCache[gid]
+	c.subdirectoryCacheMux.RUnlock()
+
+	if isSubdirectory {
+		return c.fetchUserSubdirectoryContent(subdirectory)
+	} else if isUser {
 		// gid is a user
-		user, err := c.fetchUser(int(gid))
+		user, err := c.fetchUser(int(gid), c.CacheTTL)
 		if err != nil {
 			return nil, nil, err
 		}
 		return c.fetchUserContent(user)
 	} else {
 		// gid is a group
-		group, err := c.fetchGroup(int(gid))
+		group, err := c.fetchGroup(int(gid), c.groupCacheTTL(int(gid)))
 		if err != nil {
 			return nil, nil, err
 		}
 		return c.fetchGroupContent(group)
 	}
 }
+
+// groupCacheTTL returns the cache ttl a subgroup inherited from its parent before being removed from the cache
+func (c *gitlabClient) groupCacheTTL(gid int) time.Duration {
+	c.groupCacheMux.RLock()
+	defer c.groupCacheMux.RUnlock()
+	if ttl, found := c.groupCacheTTLs[gid]; found {
+		return ttl
+	}
+	return c.CacheTTL
+}
+
+func (c *gitlabClient) rootCacheTTL(name string) time.Duration {
+	if ttl, found := c.CacheTTLOverrides[name]; found {
+		return ttl
+	}
+	return c.CacheTTL
+}
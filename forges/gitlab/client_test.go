@@ -0,0 +1,127 @@
+package gitlab_test
+
+import (
+	"log/slog"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/badjware/gitforgefs/config"
+	"github.com/badjware/gitforgefs/forges/forgetest"
+	"github.com/badjware/gitforgefs/forges/gitlab"
+)
+
+func TestNewClientGroups(t *testing.T) {
+	server := forgetest.NewServer(t, "", map[string]string{
+		"/api/v4/user": `{"id": 1, "username": "test-user"}`,
+		"/api/v4/groups/gitlab-org%2Fsecurity?with_projects=false": `{"id": 42, "path": "security", "full_path": "gitlab-org/security"}`,
+		"/api/v4/groups/42":  `{"id": 42, "path": "security", "full_path": "gitlab-org/security"}`,
+		"/api/v4/groups/123": `{"id": 123, "path": "other-group", "full_path": "other-group"}`,
+		"/api/v4/users/1":    `{"id": 1, "username": "test-user"}`,
+	})
+
+	tests := map[string]struct {
+		input    []string
+		expected []string
+	}{
+		"PathAndID": {
+			input:    []string{"gitlab-org/security", "123"},
+			expected: []string{"other-group", "security", "test-user"},
+		},
+		"NotFound": {
+			input:    []string{"gitlab-org/missing"},
+			expected: nil,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			clientConfig := config.GitlabClientConfig{
+				URL:                     server.URL,
+				GroupIDs:                []int{},
+				Groups:                  test.input,
+				UserNames:               []string{},
+				ArchivedProjectHandling: config.ArchivedProjectHide,
+				PullMethod:              config.PullMethodHTTP,
+			}
+			client, err := gitlab.NewClient(slog.Default(), clientConfig, nil, nil)
+			if test.expected == nil {
+				if err == nil || !strings.Contains(err.Error(), "gitlab-org/missing") {
+					t.Fatalf("NewClient() returned error %v; expected an error naming the missing group", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewClient() returned error: %v", err)
+			}
+
+			rootContent, err := client.FetchRootGroupContent()
+			if err != nil {
+				t.Fatalf("FetchRootGroupContent() returned error: %v", err)
+			}
+			got := []string{}
+			for name := range rootContent {
+				got = append(got, name)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Fatalf("FetchRootGroupContent() returned %v; expected %v", got, test.expected)
+			}
+		})
+	}
+}
+
+func TestSubgroupCacheTTL(t *testing.T) {
+	t.Parallel()
+	server := forgetest.NewServer(t, "", map[string]string{
+		"/api/v4/user":       `{"id": 1, "username": "test-user"}`,
+		"/api/v4/groups/123": `{"id": 123, "path": "top-group", "full_path": "top-group"}`,
+		"/api/v4/groups/123/subgroups?all_available=true&page=1&per_page=100": `[{"id": 456, "path": "sub-group", "full_path": "top-group/sub-group"}]`,
+		"/api/v4/groups/123/projects?page=1&per_page=100":                     `[]`,
+		"/api/v4/groups/456": `{"id": 456, "path": "sub-group", "full_path": "top-group/sub-group"}`,
+		"/api/v4/groups/456/subgroups?all_available=true&page=1&per_page=100": `[]`,
+		"/api/v4/groups/456/projects?page=1&per_page=100":                     `[]`,
+		"/api/v4/users/1": `{"id": 1, "username": "test-user"}`,
+	})
+	clientConfig := config.GitlabClientConfig{
+		URL:                     server.URL,
+		GroupIDs:                []int{123},
+		UserNames:               []string{},
+		ArchivedProjectHandling: config.ArchivedProjectHide,
+		PullMethod:              config.PullMethodHTTP,
+		CacheTTL:                time.Hour,
+		CacheTTLOverrides:       map[string]time.Duration{"top-group": 10 * time.Minute},
+	}
+	client, err := gitlab.NewClient(slog.Default(), clientConfig, nil, nil)
+	if err != nil {
+		t.Fatalf("NewClient() returned error: %v", err)
+	}
+
+	rootContent, err := client.FetchRootGroupContent()
+	if err != nil {
+		t.Fatalf("FetchRootGroupContent() returned error: %v", err)
+	}
+	topGroup, found := rootContent["top-group"]
+	if !found {
+		t.Fatalf("FetchRootGroupContent() returned %v; expected top-group", rootContent)
+	}
+	if _, _, err := client.FetchGroupContent(topGroup.GetGroupID()); err != nil {
+		t.Fatalf("FetchGroupContent(123) returned error: %v", err)
+	}
+	if got := client.GroupCacheTTL(456); got != 10*time.Minute {
+		t.Fatalf("GroupCacheTTL(456) returned %v; expected %v", got, 10*time.Minute)
+	}
+
+	// a refresh of the parent removes the subgroup from the cache, which must keep the inherited ttl when fetched again
+	topGroup.InvalidateContentCache()
+	if _, _, err := client.FetchGroupContent(456); err != nil {
+		t.Fatalf("FetchGroupContent(456) returned error: %v", err)
+	}
+	if got := client.GroupCacheTTL(456); got != 10*time.Minute {
+		t.Fatalf("GroupCacheTTL(456) returned %v after a refresh of its parent; expected %v", got, 10*time.Minute)
+	}
+}
@@ -0,0 +1,13 @@
+package gitlab
+
+import "time"
+
+// GroupCacheTTL returns the cache ttl of the group gid, or 0 if the group is not in the cache
+func (c *gitlabClient) GroupCacheTTL(gid int) time.Duration {
+	c.groupCacheMux.RLock()
+	defer c.groupCacheMux.RUnlock()
+	if group, found := c.groupCache[gid]; found {
+		return group.content.TTL()
+	}
+	return 0
+}
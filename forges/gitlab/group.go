@@ -2,8 +2,9 @@ package gitlab
 
 import (
 	"fmt"
-	"sync"
+	"time"
 
+	"github.com/badjware/gitforgefs/cache"
 	"github.com/badjware/gitforgefs/fstree"
 	"github.com/xanzy/go-gitlab"
 )
@@ -14,11 +15,8 @@ type Group struct {
 
 	gitlabClient *gitlabClient
 
-	mux sync.Mutex
-
 	// hold group content
-	childGroups   map[string]fstree.GroupSource
-	childProjects map[string]fstree.RepositorySource
+	content *cache.ContentCache
 }
 
 func (g *Group) GetGroupID() uint64 {
@@ -26,103 +24,105 @@ func (g *Group) GetGroupID() uint64 {
 }
 
 func (g *Group) InvalidateContentCache() {
-	g.mux.Lock()
-	defer g.mux.Unlock()
-
 	// clear child group from cache
+	childGroups, _ := g.content.Peek()
 	g.gitlabClient.groupCacheMux.Lock()
-	for _, childGroup := range g.childGroups {
+	for _, childGroup := range childGroups {
 		gid := int(childGroup.GetGroupID())
+		if cachedGroup, found := g.gitlabClient.groupCache[gid]; found {
+			// remember the inherited ttl in case the group is fetched again outside of its parent
+			g.gitlabClient.groupCacheTTLs[gid] = cachedGroup.content.TTL()
+		}
 		delete(g.gitlabClient.groupCache, gid)
 	}
 	g.gitlabClient.groupCacheMux.Unlock()
-	g.childGroups = nil
 
-	// clear child repositories from cache
-	g.childGroups = nil
+	// clear child groups and repositories from cache
+	g.content.Invalidate()
 }
 
-func (c *gitlabClient) fetchGroup(gid int) (*Group, error) {
+func (c *gitlabClient) newGroup(gid int, name string, cacheTTL time.Duration) *Group {
+	group := &Group{
+		ID:   gid,
+		Name: name,
+
+		gitlabClient: c,
+
+		content: cache.NewContentCache(c.logger, cacheTTL),
+	}
+	c.store.Track(uint64(gid), name, groupKind, group.content)
+	return group
+}
+
+func (c *gitlabClient) fetchGroup(gid int, cacheTTL time.Duration) (*Group, error) {
 	// start by searching the cache
-	// TODO: cache invalidation?
 	c.groupCacheMux.RLock()
 	group, found := c.groupCache[gid]
 	c.groupCacheMux.RUnlock()
 	if found {
 		c.logger.Debug("Group cache hit", "gid", gid)
+		c.metrics.ObserveCacheLookup(groupKind, true)
 		return group, nil
 	} else {
 		c.logger.Debug("Group cache miss; fetching group", "gid", gid)
+		c.metrics.ObserveCacheLookup(groupKind, false)
 	}
 
 	// If not in cache, fetch group infos from API
+	start := time.Now()
 	gitlabGroup, _, err := c.client.Groups.GetGroup(gid, &gitlab.GetGroupOptions{})
+	c.metrics.ObserveRequest("Groups.GetGroup", start, err)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch group with id %v: %v", gid, err)
 	}
 	c.logger.Debug("Fetched group", "gid", gid)
-	newGroup := Group{
-		ID:   gitlabGroup.ID,
-		Name: gitlabGroup.Path,
-
-		gitlabClient: c,
-
-		childGroups:   nil,
-		childProjects: nil,
-	}
+	newGroup := c.newGroup(gitlabGroup.ID, gitlabGroup.Path, cacheTTL)
 
 	// save in cache
 	c.groupCacheMux.Lock()
-	c.groupCache[gid] = &newGroup
+	c.groupCache[gid] = newGroup
+	delete(c.groupCacheTTLs, gid)
 	c.groupCacheMux.Unlock()
 
-	return &newGroup, nil
+	return newGroup, nil
 }
 
-func (c *gitlabClient) newGroupFromGitlabGroup(gitlabGroup *gitlab.Group) (*Group, error) {
+func (c *gitlabClient) newGroupFromGitlabGroup(gitlabGroup *gitlab.Group, cacheTTL time.Duration) (*Group, error) {
 	gid := gitlabGroup.ID
 
 	// start by searching the cache
+	// a cached group that has since been renamed is discarded
 	c.groupCacheMux.RLock()
 	group, found := c.groupCache[gid]
 	c.groupCacheMux.RUnlock()
-	if found {
+	if found && group.Name == gitlabGroup.Path {
 		// if found in cache, return the cached reference
 		c.logger.Debug("Group cache hit", "gid", gid)
+		c.metrics.ObserveCacheLookup(groupKind, true)
+		group.content.SetTTL(cacheTTL)
 		return group, nil
 	} else {
 		c.logger.Debug("Group cache miss; registering group", "gid", gid)
+		c.metrics.ObserveCacheLookup(groupKind, false)
 	}
 
 	// if not found in cache, convert and save to cache now
-	newGroup := Group{
-		ID:   gitlabGroup.ID,
-		Name: gitlabGroup.Path,
-
-		gitlabClient: c,
-
-		childGroups:   nil,
-		childProjects: nil,
-	}
+	newGroup := c.newGroup(gitlabGroup.ID, gitlabGroup.Path, cacheTTL)
 
 	// save in cache
 	c.groupCacheMux.Lock()
-	c.groupCache[gid] = &newGroup
+	c.groupCache[gid] = newGroup
+	delete(c.groupCacheTTLs, gid)
 	c.groupCacheMux.Unlock()
 
-	return &newGroup, nil
+	return newGroup, nil
 }
 
 func (c *gitlabClient) fetchGroupContent(group *Group) (map[string]fstree.GroupSource, map[string]fstree.RepositorySource, error) {
-	// Only a single routine can fetch the group content at the time.
-	// We lock for the whole duration of the function to avoid fetching the same data from the API
-	// multiple times if concurrent calls where to occur.
-	group.mux.Lock()
-	defer group.mux.Unlock()
-
-	// Get cached data if available
-	// TODO: cache cache invalidation?
-	if group.childGroups == nil || group.childProjects == nil {
+	// subgroups inherit the cache ttl of their parent
+	cacheTTL := group.content.TTL()
+
+	return group.content.Get(func() (map[string]fstree.GroupSource, map[string]fstree.RepositorySource, error) {
 		childGroups := make(map[string]fstree.GroupSource)
 		childProjects := make(map[string]fstree.RepositorySource)
 
@@ -135,13 +135,18 @@ func (c *gitlabClient) fetchGroupContent(group *Group) (map[string]fstree.GroupS
 			AllAvailable: gitlab.Ptr(true),
 		}
 		for {
+			start := time.Now()
 			gitlabGroups, response, err := c.client.Groups.ListSubGroups(group.ID, listGroupsOpt)
+			c.metrics.ObserveRequest("Groups.ListSubGroups", start, err)
 			if err != nil {
 				return nil, nil, fmt.Errorf("failed to fetch groups in gitlab: %v", err)
 			}
 			for _, gitlabGroup := range gitlabGroups {
-				group, _ := c.newGroupFromGitlabGroup(gitlabGroup)
-				childGroups[group.Name] = group
+				if !c.filter.MatchGroup(gitlabGroup.FullPath) {
+					continue
+				}
+				childGroup, _ := c.newGroupFromGitlabGroup(gitlabGroup, cacheTTL)
+				childGroups[childGroup.Name] = childGroup
 			}
 			if response.CurrentPage >= response.TotalPages {
 				break
@@ -157,7 +162,9 @@ func (c *gitlabClient) fetchGroupContent(group *Group) (map[string]fstree.GroupS
 				PerPage: 100,
 			}}
 		for {
+			start := time.Now()
 			gitlabProjects, response, err := c.client.Groups.ListGroupProjects(group.ID, listProjectOpt)
+			c.metrics.ObserveRequest("Groups.ListGroupProjects", start, err)
 			if err != nil {
 				return nil, nil, fmt.Errorf("failed to fetch projects in gitlab: %v", err)
 			}
@@ -174,8 +181,6 @@ func (c *gitlabClient) fetchGroupContent(group *Group) (map[string]fstree.GroupS
 			listProjectOpt.Page = response.NextPage
 		}
 
-		group.childGroups = childGroups
-		group.childProjects = childProjects
-	}
-	return group.childGroups, group.childProjects, nil
+		return childGroups, childProjects, nil
+	})
 }
@@ -4,20 +4,29 @@ import (
 	"path"
 
 	"github.com/badjware/gitforgefs/config"
+	"github.com/badjware/gitforgefs/filter"
+	"github.com/badjware/gitforgefs/fstree"
 	"github.com/xanzy/go-gitlab"
 )
 
 type Project struct {
 	ID            int
+	FullPath      string
 	Path          string
 	CloneURL      string
 	DefaultBranch string
+
+	Metadata *fstree.RepositoryMetadata
 }
 
 func (p *Project) GetRepositoryID() uint64 {
 	return uint64(p.ID)
 }
 
+func (p *Project) GetRepositoryPath() string {
+	return p.FullPath
+}
+
 func (p *Project) GetCloneURL() string {
 	return p.CloneURL
 }
@@ -26,25 +35,51 @@ func (p *Project) GetDefaultBranch() string {
 	return p.DefaultBranch
 }
 
+func (p *Project) GetMetadata() *fstree.RepositoryMetadata {
+	return p.Metadata
+}
+
 func (c *gitlabClient) newProjectFromGitlabProject(project *gitlab.Project) *Project {
 	// https://godoc.org/github.com/xanzy/go-gitlab#Project
+	fork := project.ForkedFromProject != nil
 	if c.ArchivedProjectHandling == config.ArchivedProjectIgnore && project.Archived {
 		return nil
 	}
+	if c.ForkHandling == config.ForkIgnore && fork {
+		return nil
+	}
+	if !c.filter.MatchRepository(filter.Repository{
+		FullPath:   project.PathWithNamespace,
+		Topics:     project.Topics,
+		Visibility: string(project.Visibility),
+		Fork:       fork,
+	}) {
+		return nil
+	}
 	p := Project{
 		ID:            project.ID,
+		FullPath:      project.PathWithNamespace,
 		Path:          project.Path,
 		DefaultBranch: project.DefaultBranch,
 	}
 	if p.DefaultBranch == "" {
 		p.DefaultBranch = "master"
 	}
+	p.Metadata = &fstree.RepositoryMetadata{
+		Description:   project.Description,
+		WebURL:        project.WebURL,
+		Visibility:    string(project.Visibility),
+		DefaultBranch: p.DefaultBranch,
+		Stars:         project.StarCount,
+		LastActivity:  project.LastActivityAt,
+		Archived:      project.Archived,
+	}
 	if c.PullMethod == config.PullMethodSSH {
 		p.CloneURL = project.SSHURLToRepo
 	} else {
 		p.CloneURL = project.HTTPURLToRepo
 	}
-	if c.ArchivedProjectHandling == config.ArchivedProjectHide && project.Archived {
+	if (c.ArchivedProjectHandling == config.ArchivedProjectHide && project.Archived) || (c.ForkHandling == config.ForkHide && fork) {
 		p.Path = path.Join(path.Dir(p.Path), "."+path.Base(p.Path))
 	}
 	return &p
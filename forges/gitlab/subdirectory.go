@@ -0,0 +1,191 @@
+package gitlab
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/badjware/gitforgefs/cache"
+	"github.com/badjware/gitforgefs/config"
+	"github.com/badjware/gitforgefs/fstree"
+	"github.com/xanzy/go-gitlab"
+)
+
+const (
+	// ids of subdirectories are in a range that is not used by the ids of groups and users
+	subdirectoryIDFlag = uint64(1) << 47
+)
+
+// UserSubdirectory is a virtual directory in the directory of the current user, listing the groups the user is a
+// member of or the projects the user starred
+type UserSubdirectory struct {
+	ID   uint64
+	Name string
+
+	user *User
+
+	// hold subdirectory content
+	content *cache.ContentCache
+}
+
+func (s *UserSubdirectory) GetGroupID() uint64 {
+	return s.ID
+}
+
+func (s *UserSubdirectory) InvalidateContentCache() {
+	// clear child groups and repositories from cache
+	s.content.Invalidate()
+}
+
+// userSubdirectoryID returns a stable id for the subdirectory name of the user with id uid
+func userSubdirectoryID(uid int, name string) uint64 {
+	id := subdirectoryIDFlag | uint64(uid)<<1
+	if name == config.CurrentUserSubdirectoryStarred {
+		id |= 1
+	}
+	return id
+}
+
+func (c *gitlabClient) newUserSubdirectory(user *User, name string) *UserSubdirectory {
+	id := userSubdirectoryID(user.ID, name)
+
+	c.subdirectoryCacheMux.Lock()
+	defer c.subdirectoryCacheMux.Unlock()
+
+	if subdirectory, found := c.subdirectoryCache[id]; found {
+		return subdirectory
+	}
+	subdirectory := &UserSubdirectory{
+		ID:   id,
+		Name: name,
+
+		user: user,
+
+		content: cache.NewContentCache(c.logger, user.content.TTL()),
+	}
+	c.subdirectoryCache[id] = subdirectory
+	c.store.Track(id, name, subdirectoryKind, subdirectory.content)
+	return subdirectory
+}
+
+// userSubdirectories returns the subdirectories to add in the directory of user
+func (c *gitlabClient) userSubdirectories(user *User) map[string]fstree.GroupSource {
+	childGroups := make(map[string]fstree.GroupSource)
+
+	c.rootMux.RLock()
+	isCurrentUser := user.ID == c.currentUserID
+	c.rootMux.RUnlock()
+	// the membership and starred apis only describe the user the token belongs to
+	if !isCurrentUser {
+		return childGroups
+	}
+
+	for _, name := range c.CurrentUserSubdirectories {
+		childGroups[name] = c.newUserSubdirectory(user, name)
+	}
+	return childGroups
+}
+
+func (c *gitlabClient) fetchUserSubdirectoryContent(subdirectory *UserSubdirectory) (map[string]fstree.GroupSource, map[string]fstree.RepositorySource, error) {
+	return subdirectory.content.Get(func() (map[string]fstree.GroupSource, map[string]fstree.RepositorySource, error) {
+		if subdirectory.Name == config.CurrentUserSubdirectoryStarred {
+			childProjects, err := c.fetchStarredProjects(subdirectory.content.TTL())
+			return make(map[string]fstree.GroupSource), childProjects, err
+		}
+		childGroups, err := c.fetchMembershipGroups(subdirectory.content.TTL())
+		return childGroups, make(map[string]fstree.RepositorySource), err
+	})
+}
+
+// fetchMembershipGroups returns the groups the current user is a member of. The subgroups of a group the user is
+// also a member of are left out, they can be found in their parent.
+func (c *gitlabClient) fetchMembershipGroups(cacheTTL time.Duration) (map[string]fstree.GroupSource, error) {
+	gitlabGroups := []*gitlab.Group{}
+	listGroupsOpt := &gitlab.ListGroupsOptions{
+		ListOptions: gitlab.ListOptions{
+			Page:    1,
+			PerPage: 100,
+		},
+		MinAccessLevel: gitlab.Ptr(gitlab.GuestPermissions),
+	}
+	for {
+		start := time.Now()
+		gitlabGroupsPage, response, err := c.client.Groups.ListGroups(listGroupsOpt)
+		c.metrics.ObserveRequest("Groups.ListGroups", start, err)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch groups in gitlab: %v", err)
+		}
+		gitlabGroups = append(gitlabGroups, gitlabGroupsPage...)
+		if response.CurrentPage >= response.TotalPages {
+			break
+		}
+		// Get the next page
+		listGroupsOpt.Page = response.NextPage
+	}
+
+	fullPaths := make(map[string]bool, len(gitlabGroups))
+	for _, gitlabGroup := range gitlabGroups {
+		fullPaths[gitlabGroup.FullPath] = true
+	}
+
+	childGroups := make(map[string]fstree.GroupSource)
+	for _, gitlabGroup := range gitlabGroups {
+		if hasAncestor(gitlabGroup.FullPath, fullPaths) || !c.filter.MatchGroup(gitlabGroup.FullPath) {
+			continue
+		}
+		childGroup, _ := c.newGroupFromGitlabGroup(gitlabGroup, cacheTTL)
+		childGroups[uniqueName(childGroups, childGroup.Name, gitlabGroup.FullPath)] = childGroup
+	}
+	return childGroups, nil
+}
+
+// fetchStarredProjects returns the projects the current user starred
+func (c *gitlabClient) fetchStarredProjects(cacheTTL time.Duration) (map[string]fstree.RepositorySource, error) {
+	childProjects := make(map[string]fstree.RepositorySource)
+	listProjectOpt := &gitlab.ListProjectsOptions{
+		ListOptions: gitlab.ListOptions{
+			Page:    1,
+			PerPage: 100,
+		},
+		Starred: gitlab.Ptr(true),
+	}
+	for {
+		start := time.Now()
+		gitlabProjects, response, err := c.client.Projects.ListProjects(listProjectOpt)
+		c.metrics.ObserveRequest("Projects.ListProjects", start, err)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch projects in gitlab: %v", err)
+		}
+		for _, gitlabProject := range gitlabProjects {
+			project := c.newProjectFromGitlabProject(gitlabProject)
+			if project != nil {
+				childProjects[uniqueName(childProjects, project.Path, gitlabProject.PathWithNamespace)] = project
+			}
+		}
+		if response.CurrentPage >= response.TotalPages {
+			break
+		}
+		// Get the next page
+		listProjectOpt.Page = response.NextPage
+	}
+	return childProjects, nil
+}
+
+// hasAncestor returns whether one of the parents of the group at fullPath is in fullPaths
+func hasAncestor(fullPath string, fullPaths map[string]bool) bool {
+	for i := strings.LastIndex(fullPath, "/"); i > 0; i = strings.LastIndex(fullPath[:i], "/") {
+		if fullPaths[fullPath[:i]] {
+			return true
+		}
+	}
+	return false
+}
+
+// uniqueName returns name, or fullPath with its slashes replaced if name is already taken in content. Groups and
+// projects from different namespaces can share the same name.
+func uniqueName[T any](content map[string]T, name string, fullPath string) string {
+	if _, found := content[name]; !found {
+		return name
+	}
+	return strings.ReplaceAll(fullPath, "/", "-")
+}
@@ -2,8 +2,9 @@ package gitlab
 
 import (
 	"fmt"
-	"sync"
+	"time"
 
+	"github.com/badjware/gitforgefs/cache"
 	"github.com/badjware/gitforgefs/fstree"
 	"github.com/xanzy/go-gitlab"
 )
@@ -12,10 +13,8 @@ type User struct {
 	ID   int
 	Name string
 
-	mux sync.Mutex
-
 	// hold user content
-	childProjects map[string]fstree.RepositorySource
+	content *cache.ContentCache
 }
 
 func (u *User) GetGroupID() uint64 {
@@ -23,57 +22,55 @@ func (u *User) GetGroupID() uint64 {
 }
 
 func (u *User) InvalidateContentCache() {
-	u.mux.Lock()
-	defer u.mux.Unlock()
-
 	// clear child repositories from cache
-	u.childProjects = nil
+	u.content.Invalidate()
+}
+
+func (c *gitlabClient) newUser(uid int, name string, cacheTTL time.Duration) *User {
+	user := &User{
+		ID:   uid,
+		Name: name,
+
+		content: cache.NewContentCache(c.logger, cacheTTL),
+	}
+	c.store.Track(uint64(uid), name, userKind, user.content)
+	return user
 }
 
-func (c *gitlabClient) fetchUser(uid int) (*User, error) {
+func (c *gitlabClient) fetchUser(uid int, cacheTTL time.Duration) (*User, error) {
 	// start by searching the cache
-	// TODO: cache invalidation?
 	c.userCacheMux.RLock()
 	user, found := c.userCache[uid]
 	c.userCacheMux.RUnlock()
 	if found {
 		// if found in cache, return the cached reference
 		c.logger.Debug("User cache hit", "uid", uid)
+		c.metrics.ObserveCacheLookup(userKind, true)
 		return user, nil
 	} else {
 		c.logger.Debug("User cache miss", "uid", uid)
+		c.metrics.ObserveCacheLookup(userKind, false)
 	}
 
 	// If not found in cache, fetch group infos from API
+	start := time.Now()
 	gitlabUser, _, err := c.client.Users.GetUser(uid, gitlab.GetUsersOptions{})
+	c.metrics.ObserveRequest("Users.GetUser", start, err)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch user with id %v: %v", uid, err)
 	}
-	newUser := User{
-		ID:   gitlabUser.ID,
-		Name: gitlabUser.Username,
-
-		childProjects: nil,
-	}
+	newUser := c.newUser(gitlabUser.ID, gitlabUser.Username, cacheTTL)
 
 	// save in cache
 	c.userCacheMux.Lock()
-	c.userCache[uid] = &newUser
+	c.userCache[uid] = newUser
 	c.userCacheMux.Unlock()
 
-	return &newUser, nil
+	return newUser, nil
 }
 
 func (c *gitlabClient) fetchUserContent(user *User) (map[string]fstree.GroupSource, map[string]fstree.RepositorySource, error) {
-	// Only a single routine can fetch the user content at the time.
-	// We lock for the whole duration of the function to avoid fetching the same data from the API
-	// multiple times if concurrent calls where to occur.
-	user.mux.Lock()
-	defer user.mux.Unlock()
-
-	// Get cached data if available
-	// TODO: cache cache invalidation?
-	if user.childProjects == nil {
+	return user.content.Get(func() (map[string]fstree.GroupSource, map[string]fstree.RepositorySource, error) {
 		childProjects := make(map[string]fstree.RepositorySource)
 
 		// Fetch the user repositories
@@ -83,7 +80,9 @@ func (c *gitlabClient) fetchUserContent(user *User) (map[string]fstree.GroupSour
 				PerPage: 100,
 			}}
 		for {
+			start := time.Now()
 			gitlabProjects, response, err := c.client.Projects.ListUserProjects(user.ID, listProjectOpt)
+			c.metrics.ObserveRequest("Projects.ListUserProjects", start, err)
 			if err != nil {
 				return nil, nil, fmt.Errorf("failed to fetch projects in gitlab: %v", err)
 			}
@@ -100,7 +99,6 @@ func (c *gitlabClient) fetchUserContent(user *User) (map[string]fstree.GroupSour
 			listProjectOpt.Page = response.NextPage
 		}
 
-		user.childProjects = childProjects
-	}
-	return make(map[string]fstree.GroupSource), user.childProjects, nil
+		return c.userSubdirectories(user), childProjects, nil
+	})
 }
@@ -0,0 +1,107 @@
+package manifest
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/badjware/gitforgefs/config"
+	"github.com/badjware/gitforgefs/fstree"
+	"github.com/badjware/gitforgefs/utils"
+)
+
+type manifestClient struct {
+	config.ManifestClientConfig
+
+	logger *slog.Logger
+
+	// the content of the manifest, replaced as a whole every time the manifest is reloaded
+	mux         sync.RWMutex
+	rootContent map[string]fstree.GroupSource
+	groups      map[uint64]*Group
+
+	// stops watching the manifest
+	stopWatch func()
+}
+
+func NewClient(logger *slog.Logger, config config.ManifestClientConfig) (*manifestClient, error) {
+	manifestClient := &manifestClient{
+		ManifestClientConfig: config,
+
+		logger: logger,
+	}
+
+	if err := manifestClient.load(); err != nil {
+		return nil, err
+	}
+
+	// reload the manifest when it changes
+	if manifestClient.PollInterval > 0 {
+		manifestClient.stopWatch = utils.WatchFile(manifestClient.Path, manifestClient.PollInterval, manifestClient.reload)
+	}
+
+	return manifestClient, nil
+}
+
+// load reads the manifest and replaces the content of the filesystem with it
+func (c *manifestClient) load() error {
+	m, err := readManifest(c.Path)
+	if err != nil {
+		return err
+	}
+
+	rootContent := make(map[string]fstree.GroupSource, len(m.Groups))
+	groups := map[uint64]*Group{}
+	for i := range m.Groups {
+		group := c.newGroupFromManifestGroup(m.Groups[i].Name, &m.Groups[i], groups)
+		rootContent[group.Name] = group
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.rootContent = rootContent
+	c.groups = groups
+	return nil
+}
+
+// Close stops watching the manifest for changes
+func (c *manifestClient) Close() error {
+	c.mux.Lock()
+	stopWatch := c.stopWatch
+	c.stopWatch = nil
+	c.mux.Unlock()
+
+	if stopWatch != nil {
+		stopWatch()
+	}
+	return nil
+}
+
+func (c *manifestClient) reload() {
+	if err := c.load(); err != nil {
+		c.logger.Warn("Failed to reload the manifest, keeping the previous content", "path", c.Path, "error", err)
+		return
+	}
+	c.logger.Info("Reloaded the manifest", "path", c.Path)
+}
+
+func (c *manifestClient) FetchRootGroupContent() (map[string]fstree.GroupSource, error) {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	return c.rootContent, nil
+}
+
+func (c *manifestClient) InvalidateRootContentCache() {
+	c.reload()
+}
+
+func (c *manifestClient) FetchGroupContent(gid uint64) (map[string]fstree.GroupSource, map[string]fstree.RepositorySource, error) {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	group, found := c.groups[gid]
+	if !found {
+		// the group may have been removed from the manifest
+		return nil, nil, fmt.Errorf("invalid gid: %v", gid)
+	}
+	return group.groups, group.repositories, nil
+}
@@ -0,0 +1,175 @@
+package manifest_test
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/badjware/gitforgefs/config"
+	"github.com/badjware/gitforgefs/forges/manifest"
+	"github.com/badjware/gitforgefs/fstree"
+)
+
+const testManifest = `
+groups:
+  - name: mirrors
+    repositories:
+      - name: linux
+        clone_url: git@mirror.example.com:linux.git
+        default_branch: main
+        description: The Linux kernel
+      - name: old
+        clone_url: git@mirror.example.com:old.git
+        archived: true
+    groups:
+      - name: tools
+        repositories:
+          - name: git
+            id: 42
+            clone_url: git@mirror.example.com:tools/git.git
+`
+
+type repository struct {
+	ID            uint64
+	CloneURL      string
+	DefaultBranch string
+	Description   string
+}
+
+// listTree returns every repositories of the forge by path
+func listTree(t *testing.T, forge fstree.GitForge) map[string]repository {
+	repositories := map[string]repository{}
+
+	var walk func(prefix string, gid uint64)
+	walk = func(prefix string, gid uint64) {
+		groups, repos, err := forge.FetchGroupContent(gid)
+		if err != nil {
+			t.Fatalf("FetchGroupContent(%v) returned error: %v", prefix, err)
+		}
+		for name, group := range groups {
+			walk(prefix+"/"+name, group.GetGroupID())
+		}
+		for name, repo := range repos {
+			repositories[prefix+"/"+name] = repository{
+				ID:            repo.GetRepositoryID(),
+				CloneURL:      repo.GetCloneURL(),
+				DefaultBranch: repo.GetDefaultBranch(),
+				Description:   repo.(fstree.RepositoryMetadataSource).GetMetadata().Description,
+			}
+		}
+	}
+
+	rootGroups, err := forge.FetchRootGroupContent()
+	if err != nil {
+		t.Fatalf("FetchRootGroupContent() returned error: %v", err)
+	}
+	for name, group := range rootGroups {
+		walk(name, group.GetGroupID())
+	}
+	return repositories
+}
+
+func writeManifest(t *testing.T, path string, content string) {
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestManifest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manifest.yaml")
+	writeManifest(t, path, testManifest)
+
+	forge, err := manifest.NewClient(slog.Default(), config.ManifestClientConfig{
+		Path:                 path,
+		ArchivedRepoHandling: config.ArchivedProjectHide,
+	})
+	if err != nil {
+		t.Fatalf("NewClient() returned error: %v", err)
+	}
+
+	repositories := listTree(t, forge)
+	linux, found := repositories["mirrors/linux"]
+	if !found || linux.CloneURL != "git@mirror.example.com:linux.git" || linux.DefaultBranch != "main" || linux.Description != "The Linux kernel" {
+		t.Fatalf("mirrors/linux is %v; expected to be cloned from git@mirror.example.com:linux.git on main", linux)
+	}
+	if old, found := repositories["mirrors/.old"]; !found || old.DefaultBranch != "master" {
+		t.Fatalf("archived repository mirrors/.old is %v; expected to default on master", old)
+	}
+	if git := repositories["mirrors/tools/git"]; git.ID != 42 {
+		t.Fatalf("mirrors/tools/git has id %v; expected 42", git.ID)
+	}
+	if len(repositories) != 3 {
+		t.Fatalf("forge has repositories %v; expected 3 repositories", repositories)
+	}
+
+	// ids are stable across loads
+	other, err := manifest.NewClient(slog.Default(), config.ManifestClientConfig{
+		Path:                 path,
+		ArchivedRepoHandling: config.ArchivedProjectHide,
+	})
+	if err != nil {
+		t.Fatalf("NewClient() returned error: %v", err)
+	}
+	if otherRepositories := listTree(t, other); !reflect.DeepEqual(repositories, otherRepositories) {
+		t.Fatalf("manifest loaded twice returned %v and %v; expected the same content", repositories, otherRepositories)
+	}
+}
+
+func TestManifestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	writeManifest(t, path, `{"groups": [{"name": "mirrors", "repositories": [{"name": "linux", "clone_url": "git@mirror.example.com:linux.git"}]}]}`)
+
+	forge, err := manifest.NewClient(slog.Default(), config.ManifestClientConfig{
+		Path:                 path,
+		ArchivedRepoHandling: config.ArchivedProjectHide,
+		PollInterval:         10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("NewClient() returned error: %v", err)
+	}
+	rootGroups, _ := forge.FetchRootGroupContent()
+	mirrors := rootGroups["mirrors"]
+
+	waitForRepositories := func(expected []string) {
+		var names []string
+		for i := 0; i < 100; i++ {
+			_, repositories, err := forge.FetchGroupContent(mirrors.GetGroupID())
+			if err != nil {
+				t.Fatalf("FetchGroupContent(mirrors) returned error: %v", err)
+			}
+			names = make([]string, 0, len(repositories))
+			for _, name := range expected {
+				if _, found := repositories[name]; found {
+					names = append(names, name)
+				}
+			}
+			if len(names) == len(expected) && len(repositories) == len(expected) {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("mirrors has repositories %v; expected %v", names, expected)
+	}
+	waitForRepositories([]string{"linux"})
+
+	// the manifest is reloaded when it changes
+	time.Sleep(20 * time.Millisecond)
+	writeManifest(t, path, `{"groups": [{"name": "mirrors", "repositories": [{"name": "linux", "clone_url": "git@mirror.example.com:linux.git"}, {"name": "git", "clone_url": "git@mirror.example.com:git.git"}]}]}`)
+	waitForRepositories([]string{"linux", "git"})
+
+	// an invalid manifest is ignored
+	writeManifest(t, path, `{"groups": [{"name": "mirrors", "repositories": [{"name": "linux"}]}]}`)
+	time.Sleep(50 * time.Millisecond)
+	waitForRepositories([]string{"linux", "git"})
+
+	// the manifest is no longer reloaded once the client is closed
+	if err := forge.Close(); err != nil {
+		t.Fatalf("Close() returned error: %v", err)
+	}
+	writeManifest(t, path, `{"groups": [{"name": "mirrors", "repositories": [{"name": "git", "clone_url": "git@mirror.example.com:git.git"}]}]}`)
+	time.Sleep(50 * time.Millisecond)
+	waitForRepositories([]string{"linux", "git"})
+}
@@ -0,0 +1,51 @@
+package manifest
+
+import (
+	"github.com/badjware/gitforgefs/fstree"
+)
+
+type Group struct {
+	ID   uint64
+	Name string
+
+	client *manifestClient
+
+	// hold group content
+	groups       map[string]fstree.GroupSource
+	repositories map[string]fstree.RepositorySource
+}
+
+func (g *Group) GetGroupID() uint64 {
+	return g.ID
+}
+
+func (g *Group) InvalidateContentCache() {
+	// the content of every groups comes from the manifest, so reload it
+	g.client.reload()
+}
+
+// newGroupFromManifestGroup converts a group of the manifest and its content, registering every converted groups in groups.
+// Groups are identified by their path in the manifest, so they keep the same id when the manifest is reloaded.
+func (c *manifestClient) newGroupFromManifestGroup(groupPath string, manifestGroup *manifestGroup, groups map[uint64]*Group) *Group {
+	group := &Group{
+		ID:   hashID(groupPath),
+		Name: manifestGroup.Name,
+
+		client: c,
+
+		groups:       make(map[string]fstree.GroupSource, len(manifestGroup.Groups)),
+		repositories: make(map[string]fstree.RepositorySource, len(manifestGroup.Repositories)),
+	}
+	for i := range manifestGroup.Groups {
+		childGroup := c.newGroupFromManifestGroup(groupPath+"/"+manifestGroup.Groups[i].Name, &manifestGroup.Groups[i], groups)
+		group.groups[childGroup.Name] = childGroup
+	}
+	for i := range manifestGroup.Repositories {
+		repository := c.newRepositoryFromManifestRepository(groupPath, &manifestGroup.Repositories[i])
+		if repository != nil {
+			group.repositories[repository.Path] = repository
+		}
+	}
+	groups[group.ID] = group
+	return group
+}
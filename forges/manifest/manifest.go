@@ -0,0 +1,108 @@
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// Ids are kept small enough to leave room for the namespacing done when exposing multiple forges.
+	idMask = 1<<47 - 1
+)
+
+// manifest is the content of the manifest file
+type manifest struct {
+	Groups []manifestGroup `yaml:"groups" json:"groups"`
+}
+
+type manifestGroup struct {
+	Name         string               `yaml:"name" json:"name"`
+	Groups       []manifestGroup      `yaml:"groups" json:"groups"`
+	Repositories []manifestRepository `yaml:"repositories" json:"repositories"`
+}
+
+type manifestRepository struct {
+	// Optional, the id of the local clone of the repository. Default to a hash of the clone url.
+	ID            uint64 `yaml:"id" json:"id"`
+	Name          string `yaml:"name" json:"name"`
+	CloneURL      string `yaml:"clone_url" json:"clone_url"`
+	DefaultBranch string `yaml:"default_branch" json:"default_branch"`
+	Archived      bool   `yaml:"archived" json:"archived"`
+	Description   string `yaml:"description" json:"description"`
+	WebURL        string `yaml:"web_url" json:"web_url"`
+}
+
+// readManifest parses the manifest file at path. Json is used if the file has a .json extension, yaml otherwise.
+func readManifest(path string) (*manifest, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest file: %v", err)
+	}
+
+	m := &manifest{}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(content, m)
+	} else {
+		err = yaml.UnmarshalStrict(content, m)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse manifest file: %v", err)
+	}
+
+	if err := validateGroupContent("", m.Groups, nil); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// validateGroupContent validates the groups and the repositories of the same directory.
+// prefix is the path of the directory in the manifest, and is empty for the top-level directory.
+func validateGroupContent(prefix string, groups []manifestGroup, repositories []manifestRepository) error {
+	names := map[string]bool{}
+	for i, group := range groups {
+		if !isValidName(group.Name) {
+			return fmt.Errorf("%vgroups[%v].name must be a valid directory name", prefix, i)
+		}
+		if names[group.Name] {
+			return fmt.Errorf("%vgroups[%v].name \"%v\" is used more than once", prefix, i, group.Name)
+		}
+		names[group.Name] = true
+
+		if err := validateGroupContent(fmt.Sprintf("%vgroups[%v].", prefix, group.Name), group.Groups, group.Repositories); err != nil {
+			return err
+		}
+	}
+	for i, repository := range repositories {
+		if !isValidName(repository.Name) {
+			return fmt.Errorf("%vrepositories[%v].name must be a valid file name", prefix, i)
+		}
+		if names[repository.Name] {
+			return fmt.Errorf("%vrepositories[%v].name \"%v\" is used more than once", prefix, i, repository.Name)
+		}
+		names[repository.Name] = true
+
+		if repository.CloneURL == "" {
+			return fmt.Errorf("%vrepositories[%v].clone_url must be set", prefix, repository.Name)
+		}
+		if repository.ID > idMask {
+			return fmt.Errorf("%vrepositories[%v].id must be lower than %v", prefix, repository.Name, uint64(idMask)+1)
+		}
+	}
+	return nil
+}
+
+func isValidName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.Contains(name, "/")
+}
+
+func hashID(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64() & idMask
+}
@@ -0,0 +1,68 @@
+package manifest
+
+import (
+	"path"
+
+	"github.com/badjware/gitforgefs/config"
+	"github.com/badjware/gitforgefs/fstree"
+)
+
+type Repository struct {
+	ID            uint64
+	FullPath      string
+	Path          string
+	CloneURL      string
+	DefaultBranch string
+
+	Metadata *fstree.RepositoryMetadata
+}
+
+func (r *Repository) GetRepositoryID() uint64 {
+	return r.ID
+}
+
+func (r *Repository) GetRepositoryPath() string {
+	return r.FullPath
+}
+
+func (r *Repository) GetCloneURL() string {
+	return r.CloneURL
+}
+
+func (r *Repository) GetDefaultBranch() string {
+	return r.DefaultBranch
+}
+
+func (r *Repository) GetMetadata() *fstree.RepositoryMetadata {
+	return r.Metadata
+}
+
+func (c *manifestClient) newRepositoryFromManifestRepository(groupPath string, repository *manifestRepository) *Repository {
+	if c.ArchivedRepoHandling == config.ArchivedProjectIgnore && repository.Archived {
+		return nil
+	}
+	r := Repository{
+		ID:            repository.ID,
+		FullPath:      groupPath + "/" + repository.Name,
+		Path:          repository.Name,
+		CloneURL:      repository.CloneURL,
+		DefaultBranch: repository.DefaultBranch,
+	}
+	if r.ID == 0 {
+		// the local clone of the repository is kept as long as it is cloned from the same url
+		r.ID = hashID(repository.CloneURL)
+	}
+	if r.DefaultBranch == "" {
+		r.DefaultBranch = "master"
+	}
+	r.Metadata = &fstree.RepositoryMetadata{
+		Description:   repository.Description,
+		WebURL:        repository.WebURL,
+		DefaultBranch: r.DefaultBranch,
+		Archived:      repository.Archived,
+	}
+	if c.ArchivedRepoHandling == config.ArchivedProjectHide && repository.Archived {
+		r.Path = path.Join(path.Dir(r.Path), "."+path.Base(r.Path))
+	}
+	return &r
+}
@@ -0,0 +1,121 @@
+package fstree
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/badjware/gitforgefs/control"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// controlHandler executes the commands received on the control socket against the mounted filesystem
+type controlHandler struct {
+	param *FSParam
+
+	mountpoint string
+	server     *fuse.Server
+}
+
+func newControlHandler(mountpoint string, server *fuse.Server, param *FSParam) *controlHandler {
+	return &controlHandler{
+		param:      param,
+		mountpoint: mountpoint,
+		server:     server,
+	}
+}
+
+func (h *controlHandler) handle(request control.Request) (string, error) {
+	switch request.Command {
+	case control.CommandStatus:
+		statuses := h.param.GitClient.FetchCloneStatuses()
+		return fmt.Sprintf("Mounted on %v\n%v\n%s", h.mountpoint, h.param.healthSummary(), formatCloneStatuses(statuses)), nil
+	case control.CommandQueue:
+		statuses := make([]CloneStatus, 0)
+		for _, status := range h.param.GitClient.FetchCloneStatuses() {
+			if status.State == StatusQueued || status.State == StatusCloning || status.State == StatusPulling {
+				statuses = append(statuses, status)
+			}
+		}
+		return string(formatCloneStatuses(statuses)), nil
+	case control.CommandRefresh:
+		group, _, err := h.resolvePath(request.Path)
+		if err != nil {
+			return "", err
+		}
+		if group == nil {
+			return "", fmt.Errorf("%v is not a group", request.Path)
+		}
+		group.InvalidateContentCache()
+		return fmt.Sprintf("Refreshed %v\n", request.Path), nil
+	case control.CommandPull, control.CommandClone:
+		_, repository, err := h.resolvePath(request.Path)
+		if err != nil {
+			return "", err
+		}
+		if repository == nil {
+			return "", fmt.Errorf("%v is not a repository", request.Path)
+		}
+		if request.Command == control.CommandClone {
+			localRepositoryPath, err := h.param.GitClient.CloneRepository(repository)
+			if err != nil {
+				return "", fmt.Errorf("failed to clone %v: %v", request.Path, err)
+			}
+			return fmt.Sprintf("Cloned %v in %v\n", request.Path, localRepositoryPath), nil
+		}
+		localRepositoryPath, err := h.param.GitClient.PullRepository(repository)
+		if err != nil {
+			return "", fmt.Errorf("failed to pull %v: %v", request.Path, err)
+		}
+		return fmt.Sprintf("Queued pull of %v in %v\n", request.Path, localRepositoryPath), nil
+	case control.CommandGC:
+		orphans, err := h.param.collectGarbage(request.Action)
+		if err != nil {
+			return "", err
+		}
+		return string(formatOrphanedClones(orphans)), nil
+	case control.CommandUnmount:
+		if err := h.server.Unmount(); err != nil {
+			return "", fmt.Errorf("failed to unmount: %v", err)
+		}
+		return fmt.Sprintf("Unmounted %v\n", h.mountpoint), nil
+	default:
+		return "", fmt.Errorf("unknown command \"%v\"", request.Command)
+	}
+}
+
+// resolvePath returns the group or the repository at path, which must be an absolute path in the filesystem
+func (h *controlHandler) resolvePath(path string) (GroupSource, RepositorySource, error) {
+	relativePath, err := filepath.Rel(h.mountpoint, filepath.Clean(path))
+	if err != nil || relativePath == ".." || strings.HasPrefix(relativePath, "../") {
+		return nil, nil, fmt.Errorf("%v is not in the filesystem mounted on %v", path, h.mountpoint)
+	}
+	if relativePath == "." {
+		return nil, nil, fmt.Errorf("%v is the root of the filesystem", path)
+	}
+
+	groups, err := h.param.gitForge().FetchRootGroupContent()
+	if err != nil && len(groups) == 0 {
+		return nil, nil, err
+	}
+	repositories := map[string]RepositorySource{}
+	names := strings.Split(relativePath, "/")
+	for i, name := range names {
+		last := i == len(names)-1
+		if repository, found := repositories[name]; found && last {
+			return nil, repository, nil
+		}
+		group, found := groups[name]
+		if !found {
+			return nil, nil, fmt.Errorf("%v: no such group or repository", path)
+		}
+		if last {
+			return group, nil, nil
+		}
+		groups, repositories, err = h.param.fetchGroupContent(names[0], group.GetGroupID())
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return nil, nil, nil
+}
@@ -0,0 +1,80 @@
+package fstree
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/badjware/gitforgefs/metrics"
+)
+
+// degradedState is why and since when the last known content of a top-level directory is served
+type degradedState struct {
+	err   error
+	since time.Time
+}
+
+// fetchGroupContent fetches the content of the group, in the top-level directory topLevel, from the forge. If the
+// forge is unreachable, the content last fetched, as returned by the forge along with the error, is served instead and
+// the top-level directory is marked as degraded until the forge can be reached again.
+func (p *FSParam) fetchGroupContent(topLevel string, gid uint64) (map[string]GroupSource, map[string]RepositorySource, error) {
+	groups, repositories, err := p.gitForge().FetchGroupContent(gid)
+	p.reportFetch(topLevel, err)
+	if err != nil {
+		if groups == nil || repositories == nil {
+			return nil, nil, err
+		}
+		p.logger.Warn("Failed to fetch the content of the group, serving the last known content", "error", err)
+	}
+	return groups, repositories, nil
+}
+
+// reportFetch marks the top-level directory topLevel as degraded if the forge could not be reached, or as healthy
+// otherwise. The root of the filesystem is reported as the top-level directory "". Each top-level directory is
+// tracked on its own, so a single unreachable forge doesn't flip the state of the whole filesystem.
+func (p *FSParam) reportFetch(topLevel string, err error) {
+	p.contentMux.Lock()
+	defer p.contentMux.Unlock()
+	if err == nil {
+		delete(p.degraded, topLevel)
+	} else {
+		if p.degraded == nil {
+			p.degraded = map[string]degradedState{}
+		}
+		state, found := p.degraded[topLevel]
+		if !found {
+			state.since = time.Now()
+		}
+		state.err = err
+		p.degraded[topLevel] = state
+	}
+	metrics.SetDegraded(len(p.degraded) > 0)
+}
+
+// healthSummary describes how the content of the filesystem is served, or returns an empty string if it is served
+// from the forge as usual
+func (p *FSParam) healthSummary() string {
+	if p.Offline {
+		return "OFFLINE: serving the content saved in the metadata cache, the forge is never queried\n"
+	}
+
+	p.contentMux.Lock()
+	defer p.contentMux.Unlock()
+	names := make([]string, 0, len(p.degraded))
+	for name := range p.degraded {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var summary strings.Builder
+	for _, name := range names {
+		state := p.degraded[name]
+		if name == "" {
+			fmt.Fprintf(&summary, "DEGRADED since %v: serving the last known top-level directories, the forge is unreachable: %v\n", state.since.Format(time.RFC3339), state.err)
+		} else {
+			fmt.Fprintf(&summary, "DEGRADED since %v: serving the last known content of %v, the forge is unreachable: %v\n", state.since.Format(time.RFC3339), name, state.err)
+		}
+	}
+	return summary.String()
+}
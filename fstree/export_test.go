@@ -0,0 +1,6 @@
+package fstree
+
+// CollectGarbage collects the orphaned local clones, see collectGarbage
+func (p *FSParam) CollectGarbage(action string) ([]OrphanedClone, error) {
+	return p.collectGarbage(action)
+}
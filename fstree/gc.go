@@ -0,0 +1,91 @@
+package fstree
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"text/tabwriter"
+)
+
+const (
+	OrphanOrphaned = "orphaned"
+	OrphanDeleted  = "deleted"
+	OrphanArchived = "archived"
+	OrphanKept     = "kept"
+)
+
+// OrphanedClone describes a local clone whose repository is no longer exposed by the forge, and what was done with it
+type OrphanedClone struct {
+	Path   string `json:"path"`
+	State  string `json:"state"`
+	Reason string `json:"reason,omitempty"`
+	// where the local clone was moved, if it was archived
+	ArchivePath string `json:"archive_path,omitempty"`
+}
+
+// fetchAllRepositories returns every repository exposed by the forge. It fails if any of the top-level directories or
+// the content of any group cannot be fully fetched from the forge, even if the forge returns the content it last
+// fetched, since the local clones of the repositories missing from the list would be collected.
+func (p *FSParam) fetchAllRepositories() ([]RepositorySource, error) {
+	if p.Offline {
+		return nil, errors.New("the forge is never queried while offline")
+	}
+
+	gitForge := p.gitForge()
+	groups, err := gitForge.FetchRootGroupContent()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the root groups: %v", err)
+	}
+
+	repositories := make([]RepositorySource, 0)
+	// the same group can be reached by multiple paths, such as the subdirectories of an organization
+	visited := map[uint64]bool{}
+	pending := make([]GroupSource, 0, len(groups))
+	for _, group := range groups {
+		pending = append(pending, group)
+	}
+	for len(pending) > 0 {
+		group := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if visited[group.GetGroupID()] {
+			continue
+		}
+		visited[group.GetGroupID()] = true
+
+		childGroups, childRepositories, err := gitForge.FetchGroupContent(group.GetGroupID())
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch the content of group %v: %v", group.GetGroupID(), err)
+		}
+		for _, childGroup := range childGroups {
+			pending = append(pending, childGroup)
+		}
+		for _, repository := range childRepositories {
+			repositories = append(repositories, repository)
+		}
+	}
+	return repositories, nil
+}
+
+// collectGarbage collects the local clones of the repositories no longer exposed by the forge, see git.gc_action
+func (p *FSParam) collectGarbage(action string) ([]OrphanedClone, error) {
+	repositories, err := p.fetchAllRepositories()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the repositories of the forge, no local clone was collected: %v", err)
+	}
+	return p.GitClient.CollectGarbage(repositories, action)
+}
+
+func formatOrphanedClones(orphans []OrphanedClone) []byte {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STATE\tPATH\tREASON")
+	for _, orphan := range orphans {
+		reason := orphan.Reason
+		if orphan.ArchivePath != "" {
+			reason = fmt.Sprintf("archived in %v", orphan.ArchivePath)
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\n", orphan.State, orphan.Path, reason)
+	}
+	w.Flush()
+	return buf.Bytes()
+}
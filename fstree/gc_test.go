@@ -0,0 +1,120 @@
+package fstree_test
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/badjware/gitforgefs/config"
+	"github.com/badjware/gitforgefs/fstree"
+)
+
+type testGroup struct {
+	id uint64
+}
+
+func (g *testGroup) GetGroupID() uint64 {
+	return g.id
+}
+
+func (g *testGroup) InvalidateContentCache() {}
+
+type testRepository struct {
+	id uint64
+}
+
+func (r *testRepository) GetRepositoryID() uint64 {
+	return r.id
+}
+
+func (r *testRepository) GetCloneURL() string {
+	return "https://example.com/repository.git"
+}
+
+func (r *testRepository) GetDefaultBranch() string {
+	return "main"
+}
+
+// testForge exposes the organizations test-org and other-org, with one repository each. With failRoot, other-org
+// cannot be fetched. The group with id failGroup cannot be fetched, its last known content is returned along with an
+// error instead.
+type testForge struct {
+	failRoot  bool
+	failGroup uint64
+}
+
+func (f *testForge) FetchRootGroupContent() (map[string]fstree.GroupSource, error) {
+	groups := map[string]fstree.GroupSource{"test-org": &testGroup{id: 1}, "other-org": &testGroup{id: 2}}
+	if f.failRoot {
+		// other-org could not be fetched
+		delete(groups, "other-org")
+		return groups, errors.New("failed to fetch organization other-org")
+	}
+	return groups, nil
+}
+
+func (f *testForge) FetchGroupContent(gid uint64) (map[string]fstree.GroupSource, map[string]fstree.RepositorySource, error) {
+	repositories := map[string]fstree.RepositorySource{"repo": &testRepository{id: gid * 100}}
+	if gid == f.failGroup {
+		return map[string]fstree.GroupSource{}, repositories, errors.New("failed to fetch the repositories")
+	}
+	return map[string]fstree.GroupSource{}, repositories, nil
+}
+
+// testGitClient records the repositories garbage collection was run against
+type testGitClient struct {
+	fstree.GitClient
+
+	collected []uint64
+}
+
+func (c *testGitClient) CollectGarbage(repositories []fstree.RepositorySource, action string) ([]fstree.OrphanedClone, error) {
+	c.collected = []uint64{}
+	for _, repository := range repositories {
+		c.collected = append(c.collected, repository.GetRepositoryID())
+	}
+	sort.Slice(c.collected, func(i, j int) bool { return c.collected[i] < c.collected[j] })
+	return []fstree.OrphanedClone{}, nil
+}
+
+func TestCollectGarbage(t *testing.T) {
+	tests := map[string]struct {
+		forge    *testForge
+		expected []uint64
+	}{
+		"Complete": {
+			forge:    &testForge{},
+			expected: []uint64{100, 200},
+		},
+		"RootFails": {
+			// the local clones of other-org must survive
+			forge:    &testForge{failRoot: true},
+			expected: nil,
+		},
+		"GroupFails": {
+			// the last known content of other-org may be missing repositories
+			forge:    &testForge{failGroup: 2},
+			expected: nil,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			gitClient := &testGitClient{}
+			param := &fstree.FSParam{
+				GitClient: gitClient,
+				GitForge:  test.forge,
+			}
+			_, err := param.CollectGarbage(config.GCActionDelete)
+			if test.expected == nil && err == nil {
+				t.Fatalf("CollectGarbage() returned no error; expected the garbage collection to be refused")
+			}
+			if !reflect.DeepEqual(gitClient.collected, test.expected) {
+				t.Fatalf("CollectGarbage() collected against repositories %v; expected %v", gitClient.collected, test.expected)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"syscall"
 
+	"github.com/badjware/gitforgefs/metrics"
 	"github.com/hanwen/go-fuse/v2/fs"
 	"github.com/hanwen/go-fuse/v2/fuse"
 )
@@ -16,7 +17,9 @@ type groupNode struct {
 	fs.Inode
 	param *FSParam
 
-	source      GroupSource
+	source GroupSource
+	// the name of the top-level directory the group is in
+	topLevel    string
 	staticNodes map[string]staticNode
 }
 
@@ -31,19 +34,24 @@ var _ = (fs.NodeReaddirer)((*groupNode)(nil))
 // Ensure we are implementing the NodeLookuper interface
 var _ = (fs.NodeLookuper)((*groupNode)(nil))
 
-func newGroupNodeFromSource(source GroupSource, param *FSParam) (*groupNode, error) {
+func newGroupNodeFromSource(source GroupSource, topLevel string, param *FSParam) (*groupNode, error) {
 	node := &groupNode{
-		param:  param,
-		source: source,
+		param:    param,
+		source:   source,
+		topLevel: topLevel,
 		staticNodes: map[string]staticNode{
 			".refresh": newRefreshNode(source, param),
 		},
 	}
+	if param.MetadataFiles {
+		node.staticNodes[".meta"] = newMetadataNode(source, topLevel, param)
+	}
 	return node, nil
 }
 
 func (n *groupNode) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
-	groups, repositories, err := n.param.GitForge.FetchGroupContent(n.source.GetGroupID())
+	metrics.ObserveFUSEOperation("readdir")
+	groups, repositories, err := n.param.fetchGroupContent(n.topLevel, n.source.GetGroupID())
 	if err != nil {
 		n.param.logger.Error(err.Error())
 	}
@@ -74,7 +82,8 @@ func (n *groupNode) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
 }
 
 func (n *groupNode) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
-	groups, repositories, err := n.param.GitForge.FetchGroupContent(n.source.GetGroupID())
+	metrics.ObserveFUSEOperation("lookup")
+	groups, repositories, err := n.param.fetchGroupContent(n.topLevel, n.source.GetGroupID())
 	if err != nil {
 		n.param.logger.Error(err.Error())
 	} else {
@@ -85,7 +94,7 @@ func (n *groupNode) Lookup(ctx context.Context, name string, out *fuse.EntryOut)
 				Ino:  group.GetGroupID() + groupBaseInode,
 				Mode: fuse.S_IFDIR,
 			}
-			groupNode, _ := newGroupNodeFromSource(group, n.param)
+			groupNode, _ := newGroupNodeFromSource(group, n.topLevel, n.param)
 			return n.NewInode(ctx, groupNode, attrs), 0
 		}
 
@@ -0,0 +1,167 @@
+package fstree
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/badjware/gitforgefs/metrics"
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+const (
+	metadataFileSuffix = ".json"
+)
+
+// RepositoryMetadata holds the information of a repository as known by its forge
+type RepositoryMetadata struct {
+	Description   string     `json:"description"`
+	WebURL        string     `json:"web_url"`
+	Visibility    string     `json:"visibility,omitempty"`
+	DefaultBranch string     `json:"default_branch"`
+	Stars         int        `json:"stars"`
+	LastActivity  *time.Time `json:"last_activity,omitempty"`
+	Archived      bool       `json:"archived"`
+}
+
+// RepositoryMetadataSource is implemented by repository sources able to describe their repository
+type RepositoryMetadataSource interface {
+	GetMetadata() *RepositoryMetadata
+}
+
+// metadataNode is a directory exposing the metadata of every repositories of a group as read-only json files
+type metadataNode struct {
+	fs.Inode
+	ino   uint64
+	param *FSParam
+
+	source   GroupSource
+	topLevel string
+}
+
+// Ensure we are implementing the NodeReaddirer interface
+var _ = (fs.NodeReaddirer)((*metadataNode)(nil))
+
+// Ensure we are implementing the NodeLookuper interface
+var _ = (fs.NodeLookuper)((*metadataNode)(nil))
+
+type metadataFileNode struct {
+	fs.Inode
+
+	content []byte
+}
+
+// Ensure we are implementing the NodeOpener interface
+var _ = (fs.NodeOpener)((*metadataFileNode)(nil))
+
+// Ensure we are implementing the NodeReader interface
+var _ = (fs.NodeReader)((*metadataFileNode)(nil))
+
+// Ensure we are implementing the NodeGetattrer interface
+var _ = (fs.NodeGetattrer)((*metadataFileNode)(nil))
+
+func newMetadataNode(source GroupSource, topLevel string, param *FSParam) *metadataNode {
+	return &metadataNode{
+		ino:      0,
+		param:    param,
+		source:   source,
+		topLevel: topLevel,
+	}
+}
+
+func (n *metadataNode) Ino() uint64 {
+	return n.ino
+}
+
+func (n *metadataNode) Mode() uint32 {
+	return fuse.S_IFDIR
+}
+
+// fetchMetadata returns the metadata of the repositories of the group, by name of repository
+func (n *metadataNode) fetchMetadata() map[string]*RepositoryMetadata {
+	_, repositories, err := n.param.fetchGroupContent(n.topLevel, n.source.GetGroupID())
+	if err != nil {
+		n.param.logger.Error(err.Error())
+	}
+
+	metadata := make(map[string]*RepositoryMetadata, len(repositories))
+	for repositoryName, repository := range repositories {
+		metadataSource, ok := UnwrapRepositorySource(repository).(RepositoryMetadataSource)
+		if !ok {
+			continue
+		}
+		if repositoryMetadata := metadataSource.GetMetadata(); repositoryMetadata != nil {
+			metadata[repositoryName] = repositoryMetadata
+		}
+	}
+	return metadata
+}
+
+func (n *metadataNode) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	metrics.ObserveFUSEOperation("readdir")
+	metadata := n.fetchMetadata()
+
+	entries := make([]fuse.DirEntry, 0, len(metadata))
+	for repositoryName := range metadata {
+		entries = append(entries, fuse.DirEntry{
+			Name: repositoryName + metadataFileSuffix,
+			Mode: fuse.S_IFREG,
+		})
+	}
+	return fs.NewListDirStream(entries), 0
+}
+
+func (n *metadataNode) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	metrics.ObserveFUSEOperation("lookup")
+	repositoryName, found := strings.CutSuffix(name, metadataFileSuffix)
+	if !found {
+		return nil, syscall.ENOENT
+	}
+	repositoryMetadata, found := n.fetchMetadata()[repositoryName]
+	if !found {
+		return nil, syscall.ENOENT
+	}
+
+	content, err := json.MarshalIndent(repositoryMetadata, "", "  ")
+	if err != nil {
+		n.param.logger.Error(err.Error())
+		return nil, syscall.EIO
+	}
+	fileNode := &metadataFileNode{
+		content: append(content, '\n'),
+	}
+	fileNode.fillAttr(&out.Attr)
+	return n.NewInode(ctx, fileNode, fs.StableAttr{Mode: fuse.S_IFREG}), 0
+}
+
+func (n *metadataFileNode) fillAttr(out *fuse.Attr) {
+	out.Mode = fuse.S_IFREG | 0444
+	out.Size = uint64(len(n.content))
+}
+
+func (n *metadataFileNode) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	n.fillAttr(&out.Attr)
+	return 0
+}
+
+func (n *metadataFileNode) Open(ctx context.Context, flags uint32) (fh fs.FileHandle, fuseFlags uint32, errno syscall.Errno) {
+	metrics.ObserveFUSEOperation("open")
+	if flags&(syscall.O_WRONLY|syscall.O_RDWR) != 0 {
+		return nil, 0, syscall.EROFS
+	}
+	return nil, 0, 0
+}
+
+func (n *metadataFileNode) Read(ctx context.Context, fh fs.FileHandle, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+	if off >= int64(len(n.content)) {
+		return fuse.ReadResultData(nil), 0
+	}
+	end := off + int64(len(dest))
+	if end > int64(len(n.content)) {
+		end = int64(len(n.content))
+	}
+	return fuse.ReadResultData(n.content[off:end]), 0
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"syscall"
 
+	"github.com/badjware/gitforgefs/metrics"
 	"github.com/hanwen/go-fuse/v2/fs"
 	"github.com/hanwen/go-fuse/v2/fuse"
 )
@@ -41,6 +42,7 @@ func (n *refreshNode) Setattr(ctx context.Context, fh fs.FileHandle, in *fuse.Se
 }
 
 func (n *refreshNode) Open(ctx context.Context, flags uint32) (fh fs.FileHandle, fuseFlags uint32, errno syscall.Errno) {
+	metrics.ObserveFUSEOperation("refresh")
 	n.source.InvalidateContentCache()
 	return nil, 0, 0
 }
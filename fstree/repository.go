@@ -4,6 +4,7 @@ import (
 	"context"
 	"syscall"
 
+	"github.com/badjware/gitforgefs/metrics"
 	"github.com/hanwen/go-fuse/v2/fs"
 )
 
@@ -25,6 +26,17 @@ type RepositorySource interface {
 	GetDefaultBranch() string
 }
 
+// RepositoryPathSource is implemented by repository sources knowing the path of their repository in the forge,
+// eg: "group/subgroup/repository"
+type RepositoryPathSource interface {
+	GetRepositoryPath() string
+}
+
+// RepositorySourceWrapper is implemented by repository sources that wrap the repository source of another forge
+type RepositorySourceWrapper interface {
+	Unwrap() RepositorySource
+}
+
 // Ensure we are implementing the NodeReaddirer interface
 var _ = (fs.NodeReadlinker)((*repositoryNode)(nil))
 
@@ -39,11 +51,23 @@ func newRepositoryNodeFromSource(source RepositorySource, param *FSParam) (*repo
 }
 
 func (n *repositoryNode) Readlink(ctx context.Context) ([]byte, syscall.Errno) {
+	metrics.ObserveFUSEOperation("readlink")
 	// Create the local copy of the repo
-	// TODO: cleanup
+	// This may block until the clone completes, depending on git.clone_wait_timeout
 	localRepositoryPath, err := n.param.GitClient.FetchLocalRepositoryPath(n.source)
 	if err != nil {
 		n.param.logger.Error(err.Error())
 	}
 	return []byte(localRepositoryPath), 0
 }
+
+// UnwrapRepositorySource returns the repository source of the forge the repository comes from
+func UnwrapRepositorySource(source RepositorySource) RepositorySource {
+	for {
+		wrapper, ok := source.(RepositorySourceWrapper)
+		if !ok {
+			return source
+		}
+		source = wrapper.Unwrap()
+	}
+}
@@ -6,12 +6,23 @@ import (
 	"log/slog"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"sync"
 	"syscall"
+	"time"
 
+	"github.com/badjware/gitforgefs/control"
+	"github.com/badjware/gitforgefs/metrics"
 	"github.com/hanwen/go-fuse/v2/fs"
 	"github.com/hanwen/go-fuse/v2/fuse"
 )
 
+const (
+	// Delays between the attempts to fetch the root groups while the forge is unreachable
+	rootRetryMinDelay = time.Second
+	rootRetryMaxDelay = time.Minute
+)
+
 type staticNode interface {
 	fs.InodeEmbedder
 	Ino() uint64
@@ -20,27 +31,76 @@ type staticNode interface {
 
 type GitClient interface {
 	FetchLocalRepositoryPath(source RepositorySource) (string, error)
+	FetchCloneStatuses() []CloneStatus
+	CloneRepository(source RepositorySource) (string, error)
+	PullRepository(source RepositorySource) (string, error)
+	// CollectGarbage handles the local clones of the repositories missing from repositories according to action
+	CollectGarbage(repositories []RepositorySource, action string) ([]OrphanedClone, error)
+	// StartGarbageCollector collects the garbage periodically, see git.gc_interval. It returns a function to stop.
+	StartGarbageCollector(fetchRepositories func() ([]RepositorySource, error)) (stop func())
 }
 
+// GitForge is a forge exposed by the filesystem. When the content cannot be fully fetched, the forge returns an error,
+// along with the content last fetched if it has any.
 type GitForge interface {
 	FetchRootGroupContent() (map[string]GroupSource, error)
 	FetchGroupContent(gid uint64) (map[string]GroupSource, map[string]RepositorySource, error)
 }
 
+// RootContentCacheInvalidator is implemented by the forges caching their root content
+type RootContentCacheInvalidator interface {
+	InvalidateRootContentCache()
+}
+
 type FSParam struct {
 	GitClient GitClient
-	GitForge  GitForge
+	// The forge exposed by the filesystem. Use ReloadGitForge to replace it once the filesystem is mounted.
+	GitForge GitForge
+
+	// Expose the metadata of the repositories of each group in a .meta directory
+	MetadataFiles bool
+
+	// Path of the unix socket on which the commands to control the filesystem are received
+	ControlSocket string
+
+	// The forge is never queried, the content saved in the metadata cache is served instead
+	Offline bool
 
 	logger *slog.Logger
+
+	mux  sync.RWMutex
+	root *rootNode
+
+	contentMux sync.Mutex
+	// the top-level directories that could not be fetched from the forge, by name
+	degraded map[string]degradedState
 }
 
 type rootNode struct {
 	fs.Inode
 	param *FSParam
+
+	staticNodes map[string]staticNode
+
+	mux sync.Mutex
+	// the last known root groups, served while the forge is unreachable
+	rootGroups map[string]GroupSource
+}
+
+// rootGroup is the source of the root of the filesystem, refreshing it refreshes the root content of the forge
+type rootGroup struct {
+	param *FSParam
 }
 
+// Ensure we are implementing the NodeOnAdder interface
 var _ = (fs.NodeOnAdder)((*rootNode)(nil))
 
+// Ensure we are implementing the NodeReaddirer interface
+var _ = (fs.NodeReaddirer)((*rootNode)(nil))
+
+// Ensure we are implementing the NodeLookuper interface
+var _ = (fs.NodeLookuper)((*rootNode)(nil))
+
 func Start(logger *slog.Logger, mountpoint string, mountoptions []string, param *FSParam, debug bool) error {
 	logger.Info("Mounting", "mountpoint", mountpoint)
 
@@ -50,15 +110,43 @@ func Start(logger *slog.Logger, mountpoint string, mountoptions []string, param
 
 	param.logger = logger
 	root := &rootNode{
-		param: param,
+		param:      param,
+		rootGroups: map[string]GroupSource{},
 	}
+	root.staticNodes = map[string]staticNode{
+		".refresh": newRefreshNode(&rootGroup{param: param}, param),
+		// Report the state of the local clones
+		".status":      newStatusNode(param, false),
+		".status.json": newStatusNode(param, true),
+	}
+	param.mux.Lock()
+	param.root = root
+	param.mux.Unlock()
 
 	server, err := fs.Mount(mountpoint, root, opts)
 	if err != nil {
 		return fmt.Errorf("mount failed: %v", err)
 	}
 
-	signalChan := make(chan os.Signal)
+	if param.ControlSocket != "" {
+		absoluteMountpoint, err := filepath.Abs(mountpoint)
+		if err != nil {
+			absoluteMountpoint = mountpoint
+		}
+		stopControl, err := control.Serve(logger, param.ControlSocket, newControlHandler(absoluteMountpoint, server, param).handle)
+		if err != nil {
+			server.Unmount()
+			return err
+		}
+		defer stopControl()
+	}
+
+	if !param.Offline {
+		stopGC := param.GitClient.StartGarbageCollector(param.fetchAllRepositories)
+		defer stopGC()
+	}
+
+	signalChan := make(chan os.Signal, 1)
 	go signalHandler(logger, signalChan, server)
 	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
 
@@ -68,26 +156,153 @@ func Start(logger *slog.Logger, mountpoint string, mountoptions []string, param
 	return nil
 }
 
+// gitForge returns the forge exposed by the filesystem
+func (p *FSParam) gitForge() GitForge {
+	p.mux.RLock()
+	defer p.mux.RUnlock()
+	return p.GitForge
+}
+
+// ReloadGitForge replaces the forge exposed by the filesystem and updates its top-level directories.
+// It can also be called with the current forge once its root groups changed.
+func (p *FSParam) ReloadGitForge(gitForge GitForge) error {
+	p.mux.Lock()
+	p.GitForge = gitForge
+	root := p.root
+	p.mux.Unlock()
+
+	if root == nil {
+		// not mounted yet
+		return nil
+	}
+	return root.notifyRootGroups()
+}
+
+func (g *rootGroup) GetGroupID() uint64 {
+	return 0
+}
+
+func (g *rootGroup) InvalidateContentCache() {
+	if invalidator, ok := g.param.gitForge().(RootContentCacheInvalidator); ok {
+		invalidator.InvalidateRootContentCache()
+	}
+}
+
 func (n *rootNode) OnAdd(ctx context.Context) {
-	rootGroups, err := n.param.GitForge.FetchRootGroupContent()
+	// Fetch the root groups in the background, so the filesystem is mounted even if the forge is unreachable
+	go n.prefetchRootGroups()
+
+	n.param.logger.Info("Mounted and ready to use")
+}
+
+// prefetchRootGroups fetches the root groups until it succeeds, waiting longer after each failure
+func (n *rootNode) prefetchRootGroups() {
+	delay := rootRetryMinDelay
+	for {
+		_, err := n.fetchRootGroups()
+		if err == nil {
+			return
+		}
+		n.param.logger.Warn("Failed to fetch the root groups, retrying", "error", err, "delay", delay)
+		time.Sleep(delay)
+		delay = min(delay*2, rootRetryMaxDelay)
+	}
+}
+
+// fetchRootGroups returns the root groups of the forge. If the forge is unreachable, the last known root groups are
+// returned along with the error, either as returned by the forge or as last fetched.
+func (n *rootNode) fetchRootGroups() (map[string]GroupSource, error) {
+	rootGroups, err := n.param.gitForge().FetchRootGroupContent()
+	n.param.reportFetch("", err)
+
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	if err != nil {
+		if len(rootGroups) > 0 {
+			return rootGroups, err
+		}
+		return n.rootGroups, err
+	}
+	n.rootGroups = rootGroups
+	return rootGroups, nil
+}
+
+// notifyRootGroups tells the kernel to forget the top-level directories that changed since they were last fetched
+func (n *rootNode) notifyRootGroups() error {
+	n.mux.Lock()
+	previousRootGroups := n.rootGroups
+	n.mux.Unlock()
+
+	rootGroups, err := n.fetchRootGroups()
+	if err != nil {
+		return err
+	}
+	for groupName, group := range previousRootGroups {
+		if newGroup, found := rootGroups[groupName]; !found || newGroup != group {
+			n.NotifyEntry(groupName)
+		}
+	}
+	for groupName := range rootGroups {
+		if _, found := previousRootGroups[groupName]; !found {
+			n.NotifyEntry(groupName)
+		}
+	}
+	return nil
+}
+
+func (n *rootNode) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	metrics.ObserveFUSEOperation("readdir")
+	rootGroups, err := n.fetchRootGroups()
 	if err != nil {
-		panic(err)
+		n.param.logger.Error(err.Error())
 	}
 
+	entries := make([]fuse.DirEntry, 0, len(rootGroups)+len(n.staticNodes))
 	for groupName, group := range rootGroups {
-		groupNode, _ := newGroupNodeFromSource(group, n.param)
-		persistentInode := n.NewPersistentInode(
-			ctx,
-			groupNode,
-			fs.StableAttr{
-				Ino:  group.GetGroupID() + groupBaseInode,
-				Mode: fuse.S_IFDIR,
-			},
-		)
-		n.AddChild(groupName, persistentInode, false)
+		entries = append(entries, fuse.DirEntry{
+			Name: groupName,
+			Ino:  group.GetGroupID() + groupBaseInode,
+			Mode: fuse.S_IFDIR,
+		})
+	}
+	for name, staticNode := range n.staticNodes {
+		entries = append(entries, fuse.DirEntry{
+			Name: name,
+			Ino:  staticNode.Ino(),
+			Mode: staticNode.Mode(),
+		})
+	}
+	return fs.NewListDirStream(entries), 0
+}
+
+func (n *rootNode) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	metrics.ObserveFUSEOperation("lookup")
+	// Check if the map of static nodes contains it
+	staticNode, ok := n.staticNodes[name]
+	if ok {
+		attrs := fs.StableAttr{
+			Ino:  staticNode.Ino(),
+			Mode: staticNode.Mode(),
+		}
+		return n.NewInode(ctx, staticNode, attrs), 0
 	}
 
-	n.param.logger.Info("Mounted and ready to use")
+	rootGroups, err := n.fetchRootGroups()
+	if err != nil {
+		n.param.logger.Error(err.Error())
+	}
+	// Check if the map of groups contains it
+	group, found := rootGroups[name]
+	if found {
+		attrs := fs.StableAttr{
+			Ino:  group.GetGroupID() + groupBaseInode,
+			Mode: fuse.S_IFDIR,
+		}
+		groupNode, _ := newGroupNodeFromSource(group, name, n.param)
+		return n.NewInode(ctx, groupNode, attrs), 0
+	}
+
+	return nil, syscall.ENOENT
 }
 
 func signalHandler(logger *slog.Logger, signalChan <-chan os.Signal, server *fuse.Server) {
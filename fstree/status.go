@@ -0,0 +1,126 @@
+package fstree
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/badjware/gitforgefs/metrics"
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+const (
+	StatusQueued  = "queued"
+	StatusCloning = "cloning"
+	StatusCloned  = "cloned"
+	StatusPulling = "pulling"
+	StatusFailed  = "failed"
+)
+
+// CloneStatus describes the state of the local clone of a repository
+type CloneStatus struct {
+	Path      string    `json:"path"`
+	CloneURL  string    `json:"clone_url"`
+	State     string    `json:"state"`
+	Error     string    `json:"error,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// statusNode is a read-only file reporting the state of the local clones, either as a table or as json
+type statusNode struct {
+	fs.Inode
+	ino   uint64
+	param *FSParam
+
+	asJSON bool
+}
+
+type statusFileHandle struct {
+	content []byte
+}
+
+// Ensure we are implementing the NodeOpener interface
+var _ = (fs.NodeOpener)((*statusNode)(nil))
+
+// Ensure we are implementing the NodeGetattrer interface
+var _ = (fs.NodeGetattrer)((*statusNode)(nil))
+
+// Ensure we are implementing the FileReader interface
+var _ = (fs.FileReader)((*statusFileHandle)(nil))
+
+func newStatusNode(param *FSParam, asJSON bool) *statusNode {
+	return &statusNode{
+		ino:    0,
+		param:  param,
+		asJSON: asJSON,
+	}
+}
+
+func (n *statusNode) Ino() uint64 {
+	return n.ino
+}
+
+func (n *statusNode) Mode() uint32 {
+	return fuse.S_IFREG
+}
+
+func (n *statusNode) render() []byte {
+	statuses := n.param.GitClient.FetchCloneStatuses()
+
+	if n.asJSON {
+		content, err := json.MarshalIndent(statuses, "", "  ")
+		if err != nil {
+			n.param.logger.Error(err.Error())
+		}
+		return append(content, '\n')
+	}
+
+	return append([]byte(n.param.healthSummary()), formatCloneStatuses(statuses)...)
+}
+
+// formatCloneStatuses renders the state of the local clones as a table
+func formatCloneStatuses(statuses []CloneStatus) []byte {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STATE\tUPDATED\tPATH\tERROR")
+	for _, status := range statuses {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", status.State, status.UpdatedAt.Format(time.RFC3339), status.Path, status.Error)
+	}
+	w.Flush()
+	return buf.Bytes()
+}
+
+func (n *statusNode) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = fuse.S_IFREG | 0444
+	if statusFile, ok := fh.(*statusFileHandle); ok {
+		out.Size = uint64(len(statusFile.content))
+	} else {
+		out.Size = uint64(len(n.render()))
+	}
+	return 0
+}
+
+func (n *statusNode) Open(ctx context.Context, flags uint32) (fh fs.FileHandle, fuseFlags uint32, errno syscall.Errno) {
+	metrics.ObserveFUSEOperation("open")
+	if flags&(syscall.O_WRONLY|syscall.O_RDWR) != 0 {
+		return nil, 0, syscall.EROFS
+	}
+	// the content changes all the time, so it must not be cached by the kernel
+	return &statusFileHandle{content: n.render()}, fuse.FOPEN_DIRECT_IO, 0
+}
+
+func (fh *statusFileHandle) Read(ctx context.Context, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+	if off >= int64(len(fh.content)) {
+		return fuse.ReadResultData(nil), 0
+	}
+	end := off + int64(len(dest))
+	if end > int64(len(fh.content)) {
+		end = int64(len(fh.content))
+	}
+	return fuse.ReadResultData(fh.content[off:end]), 0
+}
@@ -2,23 +2,35 @@ package git
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/badjware/gitforgefs/config"
 	"github.com/badjware/gitforgefs/fstree"
+	"github.com/badjware/gitforgefs/metrics"
 	"github.com/badjware/gitforgefs/utils"
 	"github.com/vmihailenco/taskq/v3"
 	"github.com/vmihailenco/taskq/v3/memqueue"
 )
 
+const (
+	// The most time the clone command waits for a clone, so that it doesn't hold the control socket at unmount
+	cloneRepositoryTimeout = 5 * time.Minute
+)
+
 type gitClient struct {
+	// the configuration can be updated while the client is running, see UpdateConfig
+	configMux sync.RWMutex
 	config.GitClientConfig
+	// the compiled path patterns of git.rules, by index
+	rulePatterns []*regexp.Regexp
 
 	logger *slog.Logger
 
@@ -31,6 +43,26 @@ type gitClient struct {
 	queue     taskq.Queue
 	cloneTask *taskq.Task
 	pullTask  *taskq.Task
+
+	// state of the local clones, by path
+	statusMux sync.Mutex
+	statuses  map[string]fstree.CloneStatus
+	// clones in progress, closed when the clone completes
+	cloneDone map[string]chan struct{}
+	// local clones being evicted or collected, no git operation is started in them meanwhile
+	busy map[string]bool
+
+	// serializes the moves of the local clones
+	layoutMux sync.Mutex
+
+	// last access and size of the local clones, by path, see git.max_size
+	accessMux sync.Mutex
+	accessed  map[string]time.Time
+	sizes     map[string]int64
+	// local clones cloned or pulled since their size was last measured
+	resized map[string]bool
+	// pending eviction of the local clones
+	evictionRequests chan struct{}
 }
 
 func NewClient(logger *slog.Logger, p config.GitClientConfig) (*gitClient, error) {
@@ -38,6 +70,7 @@ func NewClient(logger *slog.Logger, p config.GitClientConfig) (*gitClient, error
 	// Create the client
 	c := &gitClient{
 		GitClientConfig: p,
+		rulePatterns:    compileRules(p.Rules),
 
 		logger: logger,
 
@@ -49,6 +82,15 @@ func NewClient(logger *slog.Logger, p config.GitClientConfig) (*gitClient, error
 			BufferSize:   p.QueueSize,
 			Storage:      taskq.NewLocalStorage(),
 		}),
+
+		statuses:  map[string]fstree.CloneStatus{},
+		cloneDone: map[string]chan struct{}{},
+		busy:      map[string]bool{},
+
+		accessed:         map[string]time.Time{},
+		sizes:            map[string]int64{},
+		resized:          map[string]bool{},
+		evictionRequests: make(chan struct{}, 1),
 	}
 
 	// Parse git version
@@ -81,13 +123,18 @@ func NewClient(logger *slog.Logger, p config.GitClientConfig) (*gitClient, error
 		RetryLimit: 1,
 	})
 
+	// Evict the local clones if the local repository cache already exceeds git.max_size
+	go c.evictionWorker()
+	c.requestEviction("")
+
 	return c, nil
 }
 
-func (c *gitClient) FetchLocalRepositoryPath(source fstree.RepositorySource) (localRepoLoc string, err error) {
+// getLocalRepositoryPath returns the path of the local clone of the repository
+func (c *gitClient) getLocalRepositoryPath(source fstree.RepositorySource) (string, error) {
+	// the local clone is identified by the id of the repository in its forge, see git.layout
 	rid := source.GetRepositoryID()
 	cloneUrl := source.GetCloneURL()
-	defaultBranch := source.GetDefaultBranch()
 
 	// Parse the url
 	hostname := c.hostnameProg.FindString(cloneUrl)
@@ -95,17 +142,127 @@ func (c *gitClient) FetchLocalRepositoryPath(source fstree.RepositorySource) (lo
 		return "", fmt.Errorf("failed to match a valid hostname from \"%v\"", cloneUrl)
 	}
 
-	localRepoLoc = filepath.Join(c.CloneLocation, hostname, strconv.Itoa(int(rid)))
-	if _, err := os.Stat(localRepoLoc); os.IsNotExist(err) {
+	gitConfig := c.currentConfig()
+	return c.resolveLocalRepositoryPath(filepath.Join(gitConfig.CloneLocation, hostname), rid, repositoryPathOf(source), gitConfig.Layout), nil
+}
+
+// queueClone dispatches the clone of the repository to localRepoLoc, unless its clone is already in progress.
+// It returns a channel closed when the clone completes.
+func (c *gitClient) queueClone(source fstree.RepositorySource, localRepoLoc string) <-chan struct{} {
+	cloneUrl := source.GetCloneURL()
+	done, queued := c.startClone(localRepoLoc, cloneUrl)
+	if queued && c.isBusy(localRepoLoc) {
+		c.finishClone(localRepoLoc, cloneUrl, fmt.Errorf("failed to queue clone: the local clone is being evicted or collected"))
+	} else if queued {
 		// Dispatch clone msg
-		msg := c.cloneTask.WithArgs(context.Background(), cloneUrl, defaultBranch, localRepoLoc)
-		msg.OnceInPeriod(time.Second, rid)
-		c.queue.Add(msg)
-	} else if c.AutoPull {
-		// Dispatch pull msg
-		msg := c.pullTask.WithArgs(context.Background(), localRepoLoc, defaultBranch)
-		msg.OnceInPeriod(time.Second, rid)
-		c.queue.Add(msg)
+		msg := c.cloneTask.WithArgs(context.Background(), cloneUrl, source.GetDefaultBranch(), localRepoLoc, repositoryPathOf(source))
+		// the ids of the repositories are only unique within their forge, deduplicate on the local clone instead
+		msg.OnceInPeriod(time.Second, "clone", localRepoLoc)
+		if err := c.queue.Add(msg); err != nil {
+			c.finishClone(localRepoLoc, cloneUrl, fmt.Errorf("failed to queue clone: %v", err))
+		} else if msg.Err == taskq.ErrDuplicate {
+			// the duplicate is never run, fail the clone so the next access queues it again
+			c.finishClone(localRepoLoc, cloneUrl, fmt.Errorf("failed to queue clone: %v", msg.Err))
+		} else {
+			metrics.GitOperationQueued()
+		}
+	}
+	return done
+}
+
+// queuePull dispatches the pull of the local clone at localRepoLoc
+func (c *gitClient) queuePull(source fstree.RepositorySource, localRepoLoc string) error {
+	if c.isBusy(localRepoLoc) {
+		return fmt.Errorf("failed to queue pull of %v: the local clone is being evicted or collected", localRepoLoc)
+	}
+	// Dispatch pull msg
+	msg := c.pullTask.WithArgs(context.Background(), source.GetCloneURL(), localRepoLoc, source.GetDefaultBranch(), repositoryPathOf(source))
+	msg.OnceInPeriod(time.Second, "pull", localRepoLoc)
+	if err := c.queue.Add(msg); err != nil {
+		return fmt.Errorf("failed to queue pull of %v: %v", localRepoLoc, err)
+	}
+	// the duplicates are never run, so they never leave the queue
+	if msg.Err != taskq.ErrDuplicate {
+		metrics.GitOperationQueued()
+	}
+	return nil
+}
+
+func (c *gitClient) FetchLocalRepositoryPath(source fstree.RepositorySource) (localRepoLoc string, err error) {
+	source = fstree.UnwrapRepositorySource(source)
+	localRepoLoc, err = c.getLocalRepositoryPath(source)
+	if err != nil {
+		return "", err
+	}
+	c.touch(localRepoLoc)
+
+	if _, err := os.Stat(localRepoLoc); os.IsNotExist(err) {
+		done := c.queueClone(source, localRepoLoc)
+		c.waitForClone(localRepoLoc, done)
+	} else if gitConfig, _ := c.repositoryConfig(repositoryPathOf(source)); gitConfig.AutoPull {
+		c.queuePull(source, localRepoLoc)
+	} else if _, found := c.getStatus(localRepoLoc); !found {
+		// the local clone was created by a previous run
+		c.setStatus(localRepoLoc, source.GetCloneURL(), fstree.StatusCloned, nil)
+	}
+	return localRepoLoc, nil
+}
+
+// CloneRepository clones the repository if it has no local clone yet, and waits for the clone to complete, for at
+// most cloneRepositoryTimeout
+func (c *gitClient) CloneRepository(source fstree.RepositorySource) (string, error) {
+	source = fstree.UnwrapRepositorySource(source)
+	localRepoLoc, err := c.getLocalRepositoryPath(source)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := os.Stat(localRepoLoc); os.IsNotExist(err) {
+		select {
+		case <-c.queueClone(source, localRepoLoc):
+		case <-time.After(cloneRepositoryTimeout):
+			return localRepoLoc, fmt.Errorf("timed out waiting for the clone to complete, it continues in the background")
+		}
+		if status, found := c.getStatus(localRepoLoc); found && status.State == fstree.StatusFailed {
+			return localRepoLoc, errors.New(status.Error)
+		}
 	}
 	return localRepoLoc, nil
 }
+
+// PullRepository dispatches the pull of the local clone of the repository, regardless of git.auto_pull
+func (c *gitClient) PullRepository(source fstree.RepositorySource) (string, error) {
+	source = fstree.UnwrapRepositorySource(source)
+	localRepoLoc, err := c.getLocalRepositoryPath(source)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := os.Stat(localRepoLoc); os.IsNotExist(err) {
+		return localRepoLoc, fmt.Errorf("%v is not cloned", localRepoLoc)
+	}
+	return localRepoLoc, c.queuePull(source, localRepoLoc)
+}
+
+// currentConfig returns the configuration of the client
+func (c *gitClient) currentConfig() config.GitClientConfig {
+	c.configMux.RLock()
+	defer c.configMux.RUnlock()
+	return c.GitClientConfig
+}
+
+// UpdateConfig applies the new configuration to the next git operations.
+// The location of the clones and the size of the queue cannot be changed while the client is running.
+func (c *gitClient) UpdateConfig(p config.GitClientConfig) {
+	c.configMux.Lock()
+	defer c.configMux.Unlock()
+
+	if p.CloneLocation != c.CloneLocation || p.QueueSize != c.QueueSize || p.QueueWorkerCount != c.QueueWorkerCount {
+		c.logger.Warn("Changes to git.clone_location, git.queue_size and git.worker_count require a restart, ignoring them")
+		p.CloneLocation = c.CloneLocation
+		p.QueueSize = c.QueueSize
+		p.QueueWorkerCount = c.QueueWorkerCount
+	}
+	c.GitClientConfig = p
+	c.rulePatterns = compileRules(p.Rules)
+}
@@ -3,94 +3,165 @@ package git
 import (
 	"fmt"
 	"strconv"
+	"time"
 
+	"github.com/badjware/gitforgefs/config"
+	"github.com/badjware/gitforgefs/fstree"
+	"github.com/badjware/gitforgefs/metrics"
 	"github.com/badjware/gitforgefs/utils"
 )
 
-func (c *gitClient) clone(url string, defaultBranch string, dst string) error {
-	if c.GitClientConfig.OnClone == "init" {
+func (c *gitClient) clone(url string, defaultBranch string, dst string, repositoryPath string) (err error) {
+	gitConfig, gitConfigKeys := c.repositoryConfig(repositoryPath)
+	metrics.GitOperationStarted()
+	start := time.Now()
+	c.setStatus(dst, url, fstree.StatusCloning, nil)
+	defer func() {
+		metrics.ObserveGitOperation("clone", start, err)
+		c.finishClone(dst, url, err)
+		c.requestEviction(dst)
+	}()
+
+	if gitConfig.OnClone == config.OnCloneInit {
 		// "Fake" cloning the repo by never actually talking to the git server
 		// This skip a fetch operation that we would do if we where to do a proper clone
 		// We can save a lot of time and network i/o doing it this way, at the cost of
 		// resulting in a very barebone local copy
-
-		// Init the local repo
-		c.logger.Info("Initializing git repository", "directory", dst, "repository", url)
+		if err := c.initRepository(gitConfig, url, defaultBranch, dst); err != nil {
+			return err
+		}
+		if err := c.setGitConfig(dst, gitConfigKeys); err != nil {
+			return err
+		}
+	} else {
+		// Clone the repo
+		c.logger.Info("Cloning git repository", "directory", dst, "repository", url, "strategy", gitConfig.OnClone)
 		args := []string{
-			"init",
+			"clone",
+			"--origin", gitConfig.Remote,
+		}
+		if gitConfig.CredentialHelperCommand != "" {
+			// the option is also saved in the config of the local clone
+			args = append(args, "--config", "credential.helper="+gitConfig.CredentialHelperCommand)
 		}
-		if c.majorVersion > 2 || c.majorVersion == 2 && c.minorVersion >= 28 {
-			args = append(args, "--initial-branch", defaultBranch)
-		} else {
-			c.logger.Warn("Version of git is too old to support --initial-branch. Consider upgrading git to version >= 2.28.0")
+		for _, key := range sortedKeys(gitConfigKeys) {
+			args = append(args, "--config", key+"="+gitConfigKeys[key])
+		}
+		if gitConfig.Depth != 0 {
+			args = append(args, "--depth", strconv.Itoa(gitConfig.Depth))
+		}
+		switch gitConfig.OnClone {
+		case config.OnCloneBlobless:
+			// the blobs are downloaded on demand
+			args = append(args, "--filter=blob:none")
+		case config.OnCloneTreeless:
+			// the trees and the blobs are downloaded on demand
+			args = append(args, "--filter=tree:0")
+		case config.OnCloneSparse:
+			// only the blobs of the files checked out are downloaded
+			args = append(args, "--filter=blob:none", "--sparse")
+		case config.OnCloneSingleBranch:
+			args = append(args, "--single-branch", "--branch", defaultBranch)
 		}
 		args = append(args,
 			"--",
+			url, // repository
 			dst, // directory
 		)
+
 		_, err := utils.ExecProcess(c.logger, "git", args...)
 		if err != nil {
-			return fmt.Errorf("failed to init git repo %v to %v: %v", url, dst, err)
+			return fmt.Errorf("failed to clone git repo %v to %v: %v", url, dst, err)
 		}
 
-		// Configure the remote
-		_, err = utils.ExecProcessInDir(
-			c.logger,
-			dst, // workdir
-			"git", "remote", "add",
-			"-m", defaultBranch,
-			"--",
-			c.GitClientConfig.Remote, // name
-			url,                      // url
-		)
-		if err != nil {
-			return fmt.Errorf("failed to setup remote %v in git repo %v: %v", url, dst, err)
+		if gitConfig.OnClone == config.OnCloneSparse {
+			// Check out the configured paths
+			_, err = utils.ExecProcessInDir(
+				c.logger,
+				dst, // workdir
+				"git", append([]string{"sparse-checkout", "set", "--"}, gitConfig.SparseCheckout...)...,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to setup sparse checkout in git repo %v: %v", dst, err)
+			}
 		}
+	}
+	return nil
+}
 
-		// Configure the default branch
-		_, err = utils.ExecProcessInDir(
-			c.logger,
-			dst, // workdir
-			"git", "config", "--local",
-			"--",
-			fmt.Sprintf("branch.%s.remote", defaultBranch), // key
-			c.GitClientConfig.Remote,                       // value
+// initRepository initializes an empty local clone of the repository at dst, with its remote configured
+func (c *gitClient) initRepository(gitConfig config.GitClientConfig, url string, defaultBranch string, dst string) error {
+	// Init the local repo
+	c.logger.Info("Initializing git repository", "directory", dst, "repository", url)
+	args := []string{
+		"init",
+	}
+	if c.majorVersion > 2 || c.majorVersion == 2 && c.minorVersion >= 28 {
+		args = append(args, "--initial-branch", defaultBranch)
+	} else {
+		c.logger.Warn("Version of git is too old to support --initial-branch. Consider upgrading git to version >= 2.28.0")
+	}
+	args = append(args,
+		"--",
+		dst, // directory
+	)
+	_, err := utils.ExecProcess(c.logger, "git", args...)
+	if err != nil {
+		return fmt.Errorf("failed to init git repo %v to %v: %v", url, dst, err)
+	}
 
-		)
-		if err != nil {
-			return fmt.Errorf("failed to setup default branch remote in git repo %v: %v", dst, err)
-		}
+	// Configure the remote
+	_, err = utils.ExecProcessInDir(
+		c.logger,
+		dst, // workdir
+		"git", "remote", "add",
+		"-m", defaultBranch,
+		"--",
+		gitConfig.Remote, // name
+		url,              // url
+	)
+	if err != nil {
+		return fmt.Errorf("failed to setup remote %v in git repo %v: %v", url, dst, err)
+	}
+
+	// Configure the default branch
+	_, err = utils.ExecProcessInDir(
+		c.logger,
+		dst, // workdir
+		"git", "config", "--local",
+		"--",
+		fmt.Sprintf("branch.%s.remote", defaultBranch), // key
+		gitConfig.Remote, // value
+
+	)
+	if err != nil {
+		return fmt.Errorf("failed to setup default branch remote in git repo %v: %v", dst, err)
+	}
+	_, err = utils.ExecProcessInDir(
+		c.logger,
+		dst, // workdir
+		"git", "config", "--local",
+		"--",
+		fmt.Sprintf("branch.%s.merge", defaultBranch), // key
+		fmt.Sprintf("refs/heads/%s", defaultBranch),   // value
+
+	)
+	if err != nil {
+		return fmt.Errorf("failed to setup default branch merge in git repo %v: %v", dst, err)
+	}
+
+	// Configure the credential helper
+	if gitConfig.CredentialHelperCommand != "" {
 		_, err = utils.ExecProcessInDir(
 			c.logger,
 			dst, // workdir
 			"git", "config", "--local",
 			"--",
-			fmt.Sprintf("branch.%s.merge", defaultBranch), // key
-			fmt.Sprintf("refs/heads/%s", defaultBranch),   // value
-
+			"credential.helper",               // key
+			gitConfig.CredentialHelperCommand, // value
 		)
 		if err != nil {
-			return fmt.Errorf("failed to setup default branch merge in git repo %v: %v", dst, err)
-		}
-	} else {
-		// Clone the repo
-		c.logger.Info("Cloning git repository", "directory", dst, "repository", url)
-		args := []string{
-			"clone",
-			"--origin", c.GitClientConfig.Remote,
-		}
-		if c.GitClientConfig.Depth != 0 {
-			args = append(args, "--depth", strconv.Itoa(c.GitClientConfig.Depth))
-		}
-		args = append(args,
-			"--",
-			url, // repository
-			dst, // directory
-		)
-
-		_, err := utils.ExecProcess(c.logger, "git", args...)
-		if err != nil {
-			return fmt.Errorf("failed to clone git repo %v to %v: %v", url, dst, err)
+			return fmt.Errorf("failed to setup credential helper in git repo %v: %v", dst, err)
 		}
 	}
 	return nil
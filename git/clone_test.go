@@ -0,0 +1,244 @@
+package git_test
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/badjware/gitforgefs/config"
+	"github.com/badjware/gitforgefs/utils"
+)
+
+type strategyRepository struct {
+	id   uint64
+	url  string
+	path string
+}
+
+func (r *strategyRepository) GetRepositoryID() uint64   { return r.id }
+func (r *strategyRepository) GetCloneURL() string       { return r.url }
+func (r *strategyRepository) GetDefaultBranch() string  { return "main" }
+func (r *strategyRepository) GetRepositoryPath() string { return r.path }
+
+// gitOutput runs git in dir and returns its output
+func gitOutput(t *testing.T, dir string, args ...string) string {
+	output, err := utils.ExecProcessInDir(slog.Default(), dir, "git", args...)
+	if err != nil {
+		t.Fatalf("git %v in %v failed: %v", args, dir, err)
+	}
+	return output
+}
+
+// newRemoteRepository creates a repository to clone, with a file in each of two directories and a second branch
+func newRemoteRepository(t *testing.T) string {
+	remote := filepath.Join(t.TempDir(), "strategy.example.com", "remote")
+	for _, args := range [][]string{
+		{"init", "--initial-branch", "main", remote},
+		{"-C", remote, "config", "uploadpack.allowFilter", "true"},
+	} {
+		gitOutput(t, "", args...)
+	}
+	for _, name := range []string{"docs/readme", "src/main"} {
+		if err := os.MkdirAll(filepath.Join(remote, filepath.Dir(name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(remote, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gitOutput(t, remote, "add", ".")
+	gitOutput(t, remote, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-m", "content")
+	gitOutput(t, remote, "branch", "other")
+	return remote
+}
+
+func TestCloneStrategy(t *testing.T) {
+	t.Cleanup(func() { gitClient.UpdateConfig(testGitConfig) })
+	remote := newRemoteRepository(t)
+
+	// the local repository cache is shared by the runs of the test
+	base := uint64(time.Now().UnixNano())
+
+	gitConfig := testGitConfig
+	gitConfig.OnClone = config.OnCloneClone
+	gitConfig.SparseCheckout = []string{"src"}
+	gitConfig.Rules = []config.GitRule{
+		{Path: "group/blobless", OnClone: config.OnCloneBlobless},
+		{Path: "group/treeless", OnClone: config.OnCloneTreeless},
+		{Path: "group/sparse/*", OnClone: config.OnCloneSparse, SparseCheckout: []string{"docs"}},
+		{Path: "group/sparse-default", OnClone: config.OnCloneSparse},
+		{Path: "re:^group/single-", OnClone: config.OnCloneSingleBranch},
+	}
+	gitClient.UpdateConfig(gitConfig)
+
+	tests := map[string]struct {
+		input    *strategyRepository
+		expected map[string]string
+	}{
+		"Clone": {
+			input: &strategyRepository{id: 1, path: "group/full"},
+			expected: map[string]string{
+				"docs/readme":                      "present",
+				"src/main":                         "present",
+				"remote.origin.partialclonefilter": "",
+				"branches":                         "origin/HEAD -> origin/main\norigin/main\norigin/other",
+			},
+		},
+		"Blobless": {
+			input: &strategyRepository{id: 2, path: "group/blobless"},
+			expected: map[string]string{
+				"docs/readme":                      "present",
+				"src/main":                         "present",
+				"remote.origin.partialclonefilter": "blob:none",
+				"branches":                         "origin/HEAD -> origin/main\norigin/main\norigin/other",
+			},
+		},
+		"Treeless": {
+			input: &strategyRepository{id: 3, path: "group/treeless"},
+			expected: map[string]string{
+				"docs/readme":                      "present",
+				"src/main":                         "present",
+				"remote.origin.partialclonefilter": "tree:0",
+				"branches":                         "origin/HEAD -> origin/main\norigin/main\norigin/other",
+			},
+		},
+		"Sparse": {
+			input: &strategyRepository{id: 4, path: "group/sparse/repo"},
+			expected: map[string]string{
+				"docs/readme":                      "present",
+				"src/main":                         "missing",
+				"remote.origin.partialclonefilter": "blob:none",
+				"branches":                         "origin/HEAD -> origin/main\norigin/main\norigin/other",
+			},
+		},
+		"SparseDefault": {
+			input: &strategyRepository{id: 5, path: "group/sparse-default"},
+			expected: map[string]string{
+				"docs/readme":                      "missing",
+				"src/main":                         "present",
+				"remote.origin.partialclonefilter": "blob:none",
+				"branches":                         "origin/HEAD -> origin/main\norigin/main\norigin/other",
+			},
+		},
+		"SingleBranch": {
+			input: &strategyRepository{id: 6, path: "group/single-branch"},
+			expected: map[string]string{
+				"docs/readme":                      "present",
+				"src/main":                         "present",
+				"remote.origin.partialclonefilter": "",
+				"branches":                         "origin/HEAD -> origin/main\norigin/main",
+			},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			test.input.id += base
+			test.input.url = "file://" + remote
+			path, err := gitClient.CloneRepository(test.input)
+			if err != nil {
+				t.Fatalf("CloneRepository() returned error: %v", err)
+			}
+
+			got := map[string]string{}
+			for _, name := range []string{"docs/readme", "src/main"} {
+				got[name] = "present"
+				if _, err := os.Stat(filepath.Join(path, name)); os.IsNotExist(err) {
+					got[name] = "missing"
+				}
+			}
+			// the key is unset for the strategies without a filter
+			got["remote.origin.partialclonefilter"], _ = utils.ExecProcessInDir(slog.Default(), path, "git", "config", "remote.origin.partialclonefilter")
+			got["branches"] = gitOutput(t, path, "branch", "--remotes", "--format=%(refname:short)%(if)%(symref)%(then) -> %(symref:short)%(end)")
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Fatalf("clone of %v is %v; expected %v", test.input.path, got, test.expected)
+			}
+		})
+	}
+}
+
+func TestCloneRules(t *testing.T) {
+	t.Cleanup(func() { gitClient.UpdateConfig(testGitConfig) })
+	remote := newRemoteRepository(t)
+
+	// the rules matching the repository are applied in order
+	gitConfig := testGitConfig
+	gitConfig.OnClone = config.OnCloneClone
+	gitConfig.Rules = []config.GitRule{
+		{Path: "work/**", Remote: "upstream", Config: map[string]string{"user.email": "me@work.example.com", "core.hooksPath": "/etc/git/hooks"}},
+		{Path: "work/team/*", OnClone: config.OnCloneSingleBranch, Config: map[string]string{"user.email": "team@work.example.com"}},
+		{Path: "other/**", Remote: "other"},
+	}
+	gitClient.UpdateConfig(gitConfig)
+
+	repository := &strategyRepository{id: uint64(time.Now().UnixNano()), url: "file://" + remote, path: "work/team/repo"}
+	path, err := gitClient.CloneRepository(repository)
+	if err != nil {
+		t.Fatalf("CloneRepository() returned error: %v", err)
+	}
+
+	expected := map[string]string{
+		"remotes":        "upstream",
+		"branches":       "upstream/HEAD -> upstream/main\nupstream/main",
+		"user.email":     "team@work.example.com",
+		"core.hooksPath": "/etc/git/hooks",
+	}
+	got := map[string]string{
+		"remotes":        gitOutput(t, path, "remote"),
+		"branches":       gitOutput(t, path, "branch", "--remotes", "--format=%(refname:short)%(if)%(symref)%(then) -> %(symref:short)%(end)"),
+		"user.email":     gitOutput(t, path, "config", "user.email"),
+		"core.hooksPath": gitOutput(t, path, "config", "core.hooksPath"),
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("clone of %v is %v; expected %v", repository.path, got, expected)
+	}
+
+	// the changes to the rules are applied on the next pull
+	gitConfig.Rules = append(gitConfig.Rules, config.GitRule{Path: "work/**", Config: map[string]string{"user.email": "new@work.example.com"}})
+	gitClient.UpdateConfig(gitConfig)
+	if _, err := gitClient.PullRepository(repository); err != nil {
+		t.Fatalf("PullRepository() returned error: %v", err)
+	}
+	for deadline := time.Now().Add(10 * time.Second); gitOutput(t, path, "config", "user.email") != "new@work.example.com"; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("user.email of %v was not updated by the pull", path)
+		}
+	}
+}
+
+func TestCloneSameID(t *testing.T) {
+	remote := newRemoteRepository(t)
+	otherRemote := filepath.Join(t.TempDir(), "other.example.com", "remote")
+	gitOutput(t, "", "clone", "--quiet", remote, otherRemote)
+
+	// the ids of the repositories are only unique within their forge
+	id := uint64(time.Now().UnixNano())
+	repositories := []*strategyRepository{
+		{id: id, url: "file://" + remote, path: "group/repo"},
+		{id: id, url: "file://" + otherRemote, path: "group/repo"},
+	}
+
+	errs := make(chan error, len(repositories))
+	for _, repository := range repositories {
+		repository := repository
+		go func() {
+			_, err := gitClient.CloneRepository(repository)
+			errs <- err
+		}()
+	}
+	for range repositories {
+		select {
+		case err := <-errs:
+			if err != nil {
+				t.Fatalf("CloneRepository() returned error: %v", err)
+			}
+		case <-time.After(30 * time.Second):
+			t.Fatalf("CloneRepository() did not return")
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package git
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	CredentialGet   = "get"
+	CredentialStore = "store"
+	CredentialErase = "erase"
+)
+
+// Credential is the username and password of a forge, for the git repositories served over http(s) by host
+type Credential struct {
+	// the protocol and the host of the git repositories, eg: "https" and "gitlab.com"
+	Protocol string
+	Host     string
+
+	Username string
+	Password string
+}
+
+// ServeCredential answers a request of git to the credential helper, see gitcredentials(7).
+// For operation "get", the credential matching the protocol and the host read from in is written to out. There is
+// nothing to do for "store" and "erase", the credentials come from the config file.
+func ServeCredential(operation string, in io.Reader, out io.Writer, credentials []Credential) error {
+	attributes := map[string]string{}
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			break
+		}
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			return fmt.Errorf("invalid credential attribute \"%v\"", line)
+		}
+		attributes[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read the credential request: %v", err)
+	}
+
+	switch operation {
+	case CredentialGet:
+		for _, credential := range credentials {
+			if credential.Protocol != attributes["protocol"] || credential.Host != attributes["host"] {
+				continue
+			}
+			if username, found := attributes["username"]; found && username != credential.Username {
+				// git asks for the password of another user
+				continue
+			}
+			_, err := fmt.Fprintf(out, "username=%v\npassword=%v\n", credential.Username, credential.Password)
+			return err
+		}
+		// git falls back to the next credential helper
+		return nil
+	case CredentialStore, CredentialErase:
+		return nil
+	}
+	return fmt.Errorf("unknown credential operation \"%v\"", operation)
+}
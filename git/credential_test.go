@@ -0,0 +1,74 @@
+package git_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/badjware/gitforgefs/git"
+)
+
+func TestServeCredential(t *testing.T) {
+	credentials := []git.Credential{
+		{Protocol: "https", Host: "gitlab.com", Username: "oauth2", Password: "gitlab-token"},
+		{Protocol: "https", Host: "gitea.example.com:3000", Username: "oauth2", Password: "gitea-token"},
+	}
+
+	tests := map[string]struct {
+		operation string
+		input     string
+		expected  string
+	}{
+		"Get": {
+			operation: git.CredentialGet,
+			input:     "protocol=https\nhost=gitlab.com\npath=group/repo.git\n\n",
+			expected:  "username=oauth2\npassword=gitlab-token\n",
+		},
+		"GetWithPort": {
+			operation: git.CredentialGet,
+			input:     "protocol=https\nhost=gitea.example.com:3000\n\n",
+			expected:  "username=oauth2\npassword=gitea-token\n",
+		},
+		"GetOtherHost": {
+			operation: git.CredentialGet,
+			input:     "protocol=https\nhost=github.com\n\n",
+			expected:  "",
+		},
+		"GetOtherProtocol": {
+			operation: git.CredentialGet,
+			input:     "protocol=http\nhost=gitlab.com\n\n",
+			expected:  "",
+		},
+		"GetOtherUser": {
+			operation: git.CredentialGet,
+			input:     "protocol=https\nhost=gitlab.com\nusername=someone\n\n",
+			expected:  "",
+		},
+		"Store": {
+			operation: git.CredentialStore,
+			input:     "protocol=https\nhost=gitlab.com\nusername=oauth2\npassword=gitlab-token\n\n",
+			expected:  "",
+		},
+		"Invalid": {
+			operation: "invalid",
+			input:     "protocol=https\nhost=gitlab.com\n\n",
+			expected:  "error",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var out bytes.Buffer
+			err := git.ServeCredential(test.operation, strings.NewReader(test.input), &out, credentials)
+			got := out.String()
+			if err != nil {
+				got = "error"
+			}
+			if got != test.expected {
+				t.Fatalf("ServeCredential(%v, %q) returned %q; expected %q; error: %v", test.operation, test.input, got, test.expected, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,293 @@
+package git
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/badjware/gitforgefs/utils"
+)
+
+const (
+	// The least time between two evictions, since each of them lists the local clones
+	evictionInterval = time.Minute
+)
+
+// touch records that the local clone at path was accessed
+func (c *gitClient) touch(path string) {
+	c.accessMux.Lock()
+	defer c.accessMux.Unlock()
+	c.accessed[path] = time.Now()
+}
+
+// lastAccess returns when the local clone at path was last accessed. The local clones not accessed since startup
+// fall back to the last modification of their git directory.
+func (c *gitClient) lastAccess(path string) time.Time {
+	c.accessMux.Lock()
+	accessedAt, found := c.accessed[path]
+	c.accessMux.Unlock()
+	if found {
+		return accessedAt
+	}
+	if info, err := os.Stat(filepath.Join(path, ".git")); err == nil {
+		return info.ModTime()
+	}
+	return time.Time{}
+}
+
+// moveAccess records that the local clone at src was moved to dst
+func (c *gitClient) moveAccess(src string, dst string) {
+	c.accessMux.Lock()
+	defer c.accessMux.Unlock()
+	if accessedAt, found := c.accessed[src]; found {
+		delete(c.accessed, src)
+		c.accessed[dst] = accessedAt
+	}
+	if size, found := c.sizes[src]; found {
+		delete(c.sizes, src)
+		c.sizes[dst] = size
+	}
+	if c.resized[src] {
+		delete(c.resized, src)
+		c.resized[dst] = true
+	}
+}
+
+// forgetAccess forgets the local clone at path, once it is removed
+func (c *gitClient) forgetAccess(path string) {
+	c.accessMux.Lock()
+	defer c.accessMux.Unlock()
+	delete(c.accessed, path)
+	delete(c.sizes, path)
+	delete(c.resized, path)
+}
+
+// localCloneSize returns the size of the local clone at path. The size is only measured again once the local clone
+// is cloned or pulled.
+func (c *gitClient) localCloneSize(path string) int64 {
+	c.accessMux.Lock()
+	size, found := c.sizes[path]
+	resized := c.resized[path]
+	delete(c.resized, path)
+	c.accessMux.Unlock()
+	if found && !resized {
+		return size
+	}
+
+	size = directorySize(path)
+	c.accessMux.Lock()
+	c.sizes[path] = size
+	c.accessMux.Unlock()
+	return size
+}
+
+// requestEviction evicts the local clones in the background if the local repository cache exceeds git.max_size, once
+// the local clone at path was cloned or pulled
+func (c *gitClient) requestEviction(path string) {
+	if path != "" {
+		c.accessMux.Lock()
+		c.resized[path] = true
+		c.accessMux.Unlock()
+	}
+	select {
+	case c.evictionRequests <- struct{}{}:
+	default:
+		// an eviction is already pending
+	}
+}
+
+func (c *gitClient) evictionWorker() {
+	var lastEviction time.Time
+	for range c.evictionRequests {
+		if c.currentConfig().MaxSize <= 0 {
+			continue
+		}
+		// the requests received in the meantime are handled by the next eviction
+		if wait := evictionInterval - time.Since(lastEviction); wait > 0 {
+			time.Sleep(wait)
+		}
+		lastEviction = time.Now()
+		c.evictLocalClones()
+	}
+}
+
+// evictLocalClones resets the least recently used local clones to the state of a clone made with on_clone "init",
+// until the local repository cache fits in git.max_size. Only the local clones with no local work are evicted.
+func (c *gitClient) evictLocalClones() {
+	gitConfig := c.currentConfig()
+	if gitConfig.MaxSize <= 0 {
+		return
+	}
+
+	clones, err := findAllLocalClones(gitConfig.CloneLocation)
+	if err != nil {
+		c.logger.Warn("Failed to list the local clones, skipping eviction", "error", err)
+		return
+	}
+	sizes := map[string]int64{}
+	var total int64
+	for _, clone := range clones {
+		sizes[clone.path] = c.localCloneSize(clone.path)
+		total += sizes[clone.path]
+	}
+	if total <= int64(gitConfig.MaxSize) {
+		return
+	}
+	c.logger.Info("Local repository cache exceeds git.max_size, evicting the least recently used local clones", "size", total, "max_size", int64(gitConfig.MaxSize))
+
+	accessedAt := map[string]time.Time{}
+	for _, clone := range clones {
+		accessedAt[clone.path] = c.lastAccess(clone.path)
+	}
+	sort.Slice(clones, func(i, j int) bool {
+		return accessedAt[clones[i].path].Before(accessedAt[clones[j].path])
+	})
+
+	for _, clone := range clones {
+		if total <= int64(gitConfig.MaxSize) {
+			return
+		}
+		if err := c.evictLocalClone(gitConfig.Remote, clone.path); err != nil {
+			c.logger.Debug("Skipping eviction of local clone", "path", clone.path, "reason", err.Error())
+			continue
+		}
+		size := directorySize(clone.path)
+		c.accessMux.Lock()
+		c.sizes[clone.path] = size
+		c.accessMux.Unlock()
+		total -= sizes[clone.path] - size
+		c.logger.Info("Evicted local clone", "path", clone.path, "freed", sizes[clone.path]-size)
+	}
+	if total > int64(gitConfig.MaxSize) {
+		c.logger.Warn("Local repository cache still exceeds git.max_size, the remaining local clones hold local work or are already evicted", "size", total, "max_size", int64(gitConfig.MaxSize))
+	}
+}
+
+// evictLocalClone resets the local clone at path to the state of a clone made with on_clone "init", keeping its git
+// config. It fails if the local clone holds local work or is already evicted.
+func (c *gitClient) evictLocalClone(remote string, path string) error {
+	// no git operation is started in the local clone until it is evicted
+	if !c.markBusy(path) {
+		return fmt.Errorf("a git operation is in progress")
+	}
+	defer c.unmarkBusy(path)
+
+	if _, err := utils.ExecProcessInDir(c.logger, path, "git", "rev-parse", "--verify", "--quiet", "HEAD"); err != nil {
+		return fmt.Errorf("nothing to evict")
+	}
+	if reason, err := c.localWork(path); err != nil {
+		return err
+	} else if reason != "" {
+		return fmt.Errorf("%v", reason)
+	}
+
+	url, err := utils.ExecProcessInDir(c.logger, path, "git", "remote", "get-url", "--", remote)
+	if err != nil {
+		// the remote may be renamed by git.rules, use the remote of the local clone
+		if remotes, remotesErr := utils.ExecProcessInDir(c.logger, path, "git", "remote"); remotesErr == nil && remotes != "" && !strings.Contains(remotes, "\n") {
+			remote = remotes
+			url, err = utils.ExecProcessInDir(c.logger, path, "git", "remote", "get-url", "--", remote)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to retrieve the url of remote %v: %v", remote, err)
+	}
+	defaultBranch, err := utils.ExecProcessInDir(c.logger, path, "git", "symbolic-ref", "--short", "refs/remotes/"+remote+"/HEAD")
+	if err == nil {
+		defaultBranch = strings.TrimPrefix(defaultBranch, remote+"/")
+	} else if defaultBranch, err = utils.ExecProcessInDir(c.logger, path, "git", "branch", "--show-current"); err != nil || defaultBranch == "" {
+		return fmt.Errorf("failed to retrieve the default branch")
+	}
+
+	// init the evicted local clone next to the local clone, then swap them so the symlinks keep pointing to a local clone
+	evictDir, err := os.MkdirTemp(filepath.Dir(path), ".evict-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(evictDir)
+	initPath := filepath.Join(evictDir, "init")
+	oldPath := filepath.Join(evictDir, "old")
+	gitConfig := c.currentConfig()
+	gitConfig.Remote = remote
+	if err := c.initRepository(gitConfig, url, defaultBranch, initPath); err != nil {
+		return fmt.Errorf("failed to evict: %v", err)
+	}
+	// keep the git config, along with the paths checked out by on_clone "sparse"
+	for _, name := range []string{"config", filepath.Join("info", "sparse-checkout")} {
+		content, err := os.ReadFile(filepath.Join(path, ".git", name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err == nil {
+			err = os.MkdirAll(filepath.Dir(filepath.Join(initPath, ".git", name)), 0755)
+		}
+		if err == nil {
+			err = os.WriteFile(filepath.Join(initPath, ".git", name), content, 0644)
+		}
+		if err != nil {
+			c.logger.Warn("Failed to keep the git config of the evicted local clone", "path", path, "error", err.Error())
+		}
+	}
+
+	// only hold the moves of the local clones while the evicted local clone is swapped in
+	c.layoutMux.Lock()
+	defer c.layoutMux.Unlock()
+	if !isLocalClone(path) {
+		return fmt.Errorf("the local clone was moved")
+	}
+	if err := os.Rename(path, oldPath); err != nil {
+		return fmt.Errorf("failed to evict: %v", err)
+	}
+	if err := os.Rename(initPath, path); err != nil {
+		// put back the local clone
+		if renameErr := os.Rename(oldPath, path); renameErr != nil {
+			c.logger.Error("Failed to restore the local clone after a failed eviction", "path", path, "error", renameErr.Error())
+		}
+		return fmt.Errorf("failed to evict: %v", err)
+	}
+	return nil
+}
+
+// findAllLocalClones returns the local clones of every host in cloneLocation
+func findAllLocalClones(cloneLocation string) ([]localClone, error) {
+	entries, err := os.ReadDir(cloneLocation)
+	if os.IsNotExist(err) {
+		return []localClone{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to list the local repository cache %v: %v", cloneLocation, err)
+	}
+	clones := make([]localClone, 0)
+	for _, entry := range entries {
+		// skip the control socket, the metadata caches and the archived local clones
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		hostClones, err := findLocalClones(filepath.Join(cloneLocation, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		clones = append(clones, hostClones...)
+	}
+	return clones, nil
+}
+
+// directorySize returns the size of the files in dir
+func directorySize(dir string) int64 {
+	var size int64
+	filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if entry.Type().IsRegular() {
+			if info, err := entry.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
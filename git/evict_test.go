@@ -0,0 +1,84 @@
+package git_test
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/badjware/gitforgefs/utils"
+)
+
+type evictRepository struct {
+	id uint64
+}
+
+func (r *evictRepository) GetRepositoryID() uint64 { return r.id }
+func (r *evictRepository) GetCloneURL() string {
+	return fmt.Sprintf("https://evict.example.com/%v.git", r.id)
+}
+func (r *evictRepository) GetDefaultBranch() string { return "main" }
+
+func TestEviction(t *testing.T) {
+	t.Cleanup(func() { gitClient.UpdateConfig(testGitConfig) })
+
+	// the local repository cache is shared by the runs of the test
+	base := uint64(time.Now().UnixNano())
+
+	// clone the repositories, then fill them with pushed commits, from the least to the most recently used
+	paths := map[uint64]string{}
+	for _, id := range []uint64{base + 1, base + 2} {
+		path, err := gitClient.FetchLocalRepositoryPath(&evictRepository{id: id})
+		if err != nil {
+			t.Fatalf("FetchLocalRepositoryPath() returned error: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(path, "content"), bytes.Repeat([]byte{byte(id)}, 4<<20), 0644); err != nil {
+			t.Fatalf("failed to write content of %v: %v", path, err)
+		}
+		for _, args := range [][]string{
+			{"add", "content"},
+			{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-m", "content"},
+			// pretend the commit was pushed
+			{"update-ref", "refs/remotes/origin/main", "HEAD"},
+		} {
+			if _, err := utils.ExecProcessInDir(slog.Default(), path, "git", args...); err != nil {
+				t.Fatalf("git %v in %v failed: %v", args, path, err)
+			}
+		}
+		paths[id-base] = path
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// the next clone completing evicts the least recently used local clone
+	gitConfig := testGitConfig
+	gitConfig.MaxSize = 6 << 20
+	gitClient.UpdateConfig(gitConfig)
+	if _, err := gitClient.CloneRepository(&evictRepository{id: base + 3}); err != nil {
+		t.Fatalf("CloneRepository() returned error: %v", err)
+	}
+
+	evicted := func(path string) bool {
+		_, contentErr := os.Stat(filepath.Join(path, "content"))
+		_, gitErr := os.Stat(filepath.Join(path, ".git"))
+		return os.IsNotExist(contentErr) && gitErr == nil
+	}
+	// the evictions are at least a minute apart, which delays the eviction when the test runs more than once
+	for deadline := time.Now().Add(90 * time.Second); !evicted(paths[1]); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("local clone %v was not evicted", paths[1])
+		}
+	}
+	if evicted(paths[2]) {
+		t.Fatalf("local clone %v was evicted; expected the least recently used local clone only", paths[2])
+	}
+
+	// the evicted local clone is still usable
+	url, err := utils.ExecProcessInDir(slog.Default(), paths[1], "git", "remote", "get-url", "origin")
+	expected := (&evictRepository{id: base + 1}).GetCloneURL()
+	if err != nil || url != expected {
+		t.Fatalf("remote of evicted local clone %v is %v; expected %v; error: %v", paths[1], url, expected, err)
+	}
+}
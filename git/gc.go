@@ -0,0 +1,310 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/badjware/gitforgefs/config"
+	"github.com/badjware/gitforgefs/fstree"
+	"github.com/badjware/gitforgefs/utils"
+)
+
+const (
+	// archiveDirName is the directory of the local repository cache where the orphaned local clones are archived
+	archiveDirName = ".archive"
+
+	// How often git.gc_interval is checked, so changes to it apply without a restart
+	gcPollInterval = time.Minute
+)
+
+// localClone is a local clone found in the local repository cache
+type localClone struct {
+	hostDir string
+	rid     uint64
+	path    string
+}
+
+// CollectGarbage finds the local clones of the repositories missing from repositories, such as the repositories
+// deleted, transferred away or filtered out, and deletes or archives them according to action. The local clones with
+// uncommitted changes, unpushed commits or stashes are always kept.
+// The hosts none of repositories are on are skipped, since their forge is likely no longer configured.
+func (c *gitClient) CollectGarbage(repositories []fstree.RepositorySource, action string) ([]fstree.OrphanedClone, error) {
+	if action == "" {
+		action = config.GCActionReport
+	}
+	if action != config.GCActionReport && action != config.GCActionDelete && action != config.GCActionArchive {
+		return nil, fmt.Errorf("unknown gc action \"%v\"", action)
+	}
+
+	// the ids of the repositories, by host
+	live := map[string]map[uint64]bool{}
+	for _, source := range repositories {
+		source = fstree.UnwrapRepositorySource(source)
+		hostname := c.hostnameProg.FindString(source.GetCloneURL())
+		if hostname == "" {
+			continue
+		}
+		if _, found := live[hostname]; !found {
+			live[hostname] = map[uint64]bool{}
+		}
+		live[hostname][source.GetRepositoryID()] = true
+	}
+
+	gitConfig := c.currentConfig()
+	orphans := make([]fstree.OrphanedClone, 0)
+	for hostname, rids := range live {
+		hostDir := filepath.Join(gitConfig.CloneLocation, hostname)
+		clones, err := findLocalClones(hostDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, clone := range clones {
+			if rids[clone.rid] {
+				continue
+			}
+			orphans = append(orphans, c.collectLocalClone(gitConfig.CloneLocation, clone, action))
+		}
+	}
+
+	sort.Slice(orphans, func(i, j int) bool {
+		return orphans[i].Path < orphans[j].Path
+	})
+	return orphans, nil
+}
+
+// collectLocalClone deletes or archives the orphaned local clone according to action, unless it holds local work
+func (c *gitClient) collectLocalClone(cloneLocation string, clone localClone, action string) fstree.OrphanedClone {
+	orphan := fstree.OrphanedClone{
+		Path:  clone.path,
+		State: fstree.OrphanOrphaned,
+	}
+	// no git operation is started in the local clone until it is collected
+	if !c.markBusy(clone.path) {
+		orphan.State = fstree.OrphanKept
+		orphan.Reason = "a git operation is in progress"
+		return orphan
+	}
+	defer c.unmarkBusy(clone.path)
+
+	if reason, err := c.localWork(clone.path); err != nil {
+		orphan.State = fstree.OrphanKept
+		orphan.Reason = fmt.Sprintf("failed to inspect the local clone: %v", err)
+		return orphan
+	} else if reason != "" {
+		orphan.State = fstree.OrphanKept
+		orphan.Reason = reason
+		return orphan
+	}
+
+	switch action {
+	case config.GCActionDelete:
+		// move the local clone out of the way first, so that it is deleted without holding the moves of the local clones
+		trashDir, err := os.MkdirTemp(clone.hostDir, ".gc-")
+		if err != nil {
+			orphan.State = fstree.OrphanKept
+			orphan.Reason = fmt.Sprintf("failed to delete the local clone: %v", err)
+			return orphan
+		}
+		defer os.RemoveAll(trashDir)
+		if err := c.removeLocalClone(clone, filepath.Join(trashDir, "clone")); err != nil {
+			orphan.State = fstree.OrphanKept
+			orphan.Reason = fmt.Sprintf("failed to delete the local clone: %v", err)
+			return orphan
+		}
+		if err := os.RemoveAll(trashDir); err != nil {
+			c.logger.Warn("Failed to delete the files of the orphaned local clone", "path", trashDir, "error", err.Error())
+		}
+		orphan.State = fstree.OrphanDeleted
+		c.logger.Info("Deleted orphaned local clone", "path", clone.path)
+	case config.GCActionArchive:
+		relativePath, err := filepath.Rel(cloneLocation, clone.path)
+		if err != nil {
+			orphan.State = fstree.OrphanKept
+			orphan.Reason = fmt.Sprintf("failed to archive the local clone: %v", err)
+			return orphan
+		}
+		archivePath := filepath.Join(cloneLocation, archiveDirName, relativePath)
+		if _, err := os.Lstat(archivePath); err == nil {
+			// a repository with the same path was archived before
+			archivePath = fmt.Sprintf("%v.%v", archivePath, time.Now().Unix())
+		}
+		if err := os.MkdirAll(filepath.Dir(archivePath), 0755); err != nil {
+			orphan.State = fstree.OrphanKept
+			orphan.Reason = fmt.Sprintf("failed to archive the local clone: %v", err)
+			return orphan
+		}
+		if err := c.removeLocalClone(clone, archivePath); err != nil {
+			orphan.State = fstree.OrphanKept
+			orphan.Reason = fmt.Sprintf("failed to archive the local clone: %v", err)
+			return orphan
+		}
+		orphan.State = fstree.OrphanArchived
+		orphan.ArchivePath = archivePath
+		c.logger.Info("Archived orphaned local clone", "path", clone.path, "archive", archivePath)
+	}
+	return orphan
+}
+
+// removeLocalClone moves the orphaned local clone out of the local repository cache to dst and forgets it. Only the
+// move holds the moves of the local clones.
+func (c *gitClient) removeLocalClone(clone localClone, dst string) error {
+	c.layoutMux.Lock()
+	defer c.layoutMux.Unlock()
+
+	if !isLocalClone(clone.path) {
+		return fmt.Errorf("the local clone was moved")
+	}
+	if err := os.Rename(clone.path, dst); err != nil {
+		return err
+	}
+
+	// forget the local clone
+	c.statusMux.Lock()
+	delete(c.statuses, clone.path)
+	c.statusMux.Unlock()
+	c.forgetAccess(clone.path)
+	indexPath := filepath.Join(clone.hostDir, indexDirName, strconv.FormatUint(clone.rid, 10))
+	if err := os.Remove(indexPath); err != nil && !os.IsNotExist(err) {
+		c.logger.Warn("Failed to remove the index of the local clone", "path", indexPath, "error", err.Error())
+	}
+	for dir := filepath.Dir(clone.path); strings.HasPrefix(dir, clone.hostDir+string(filepath.Separator)); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// localWork describes the work in the local clone at path that would be lost if it was deleted, or returns an empty
+// string if there is none
+func (c *gitClient) localWork(path string) (string, error) {
+	status, err := utils.ExecProcessInDir(c.logger, path, "git", "status", "--porcelain")
+	if err != nil {
+		return "", fmt.Errorf("failed to run \"git status\": %v", err)
+	}
+	if status != "" {
+		return "uncommitted changes", nil
+	}
+
+	unpushed, err := utils.ExecProcessInDir(c.logger, path, "git", "log", "--branches", "--not", "--remotes", "--format=%H")
+	if err != nil {
+		return "", fmt.Errorf("failed to run \"git log\": %v", err)
+	}
+	if unpushed != "" {
+		return "unpushed commits", nil
+	}
+
+	stashes, err := utils.ExecProcessInDir(c.logger, path, "git", "stash", "list")
+	if err != nil {
+		return "", fmt.Errorf("failed to run \"git stash list\": %v", err)
+	}
+	if stashes != "" {
+		return "stashes", nil
+	}
+	return "", nil
+}
+
+// findLocalClones returns the local clones in hostDir, both those laid out by id and those indexed by id
+func findLocalClones(hostDir string) ([]localClone, error) {
+	clones := make([]localClone, 0)
+
+	entries, err := os.ReadDir(hostDir)
+	if os.IsNotExist(err) {
+		return clones, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to list the local clones in %v: %v", hostDir, err)
+	}
+	for _, entry := range entries {
+		rid, err := strconv.ParseUint(entry.Name(), 10, 64)
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(hostDir, entry.Name())
+		if isLocalClone(path) {
+			clones = append(clones, localClone{hostDir: hostDir, rid: rid, path: path})
+		}
+	}
+
+	indexDir := filepath.Join(hostDir, indexDirName)
+	entries, err = os.ReadDir(indexDir)
+	if os.IsNotExist(err) {
+		return clones, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to list the local clones in %v: %v", indexDir, err)
+	}
+	for _, entry := range entries {
+		rid, err := strconv.ParseUint(entry.Name(), 10, 64)
+		if err != nil {
+			continue
+		}
+		target, err := os.Readlink(filepath.Join(indexDir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		path := filepath.Join(indexDir, target)
+		if path == filepath.Join(hostDir, entry.Name()) {
+			// already found by id
+			continue
+		}
+		if isLocalClone(path) {
+			clones = append(clones, localClone{hostDir: hostDir, rid: rid, path: path})
+		}
+	}
+	return clones, nil
+}
+
+// isLocalClone returns whether there is a git repository at path
+func isLocalClone(path string) bool {
+	_, err := os.Stat(filepath.Join(path, ".git"))
+	return err == nil
+}
+
+func (c *gitClient) StartGarbageCollector(fetchRepositories func() ([]fstree.RepositorySource, error)) func() {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(gcPollInterval)
+		defer ticker.Stop()
+
+		lastRun := time.Now()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+
+			gitConfig := c.currentConfig()
+			if gitConfig.GCInterval <= 0 || time.Since(lastRun) < gitConfig.GCInterval {
+				continue
+			}
+			lastRun = time.Now()
+
+			repositories, err := fetchRepositories()
+			if err != nil {
+				c.logger.Warn("Failed to list the repositories of the forge, skipping garbage collection", "error", err)
+				continue
+			}
+			orphans, err := c.CollectGarbage(repositories, gitConfig.GCAction)
+			if err != nil {
+				c.logger.Warn("Failed to collect the orphaned local clones", "error", err)
+				continue
+			}
+			for _, orphan := range orphans {
+				if orphan.State == fstree.OrphanOrphaned || orphan.State == fstree.OrphanKept {
+					c.logger.Info("Found orphaned local clone", "path", orphan.Path, "state", orphan.State, "reason", orphan.Reason)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-done
+	}
+}
@@ -0,0 +1,100 @@
+package git_test
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/badjware/gitforgefs/config"
+	"github.com/badjware/gitforgefs/fstree"
+	"github.com/badjware/gitforgefs/utils"
+)
+
+type gcRepository struct {
+	id uint64
+}
+
+func (r *gcRepository) GetRepositoryID() uint64  { return r.id }
+func (r *gcRepository) GetCloneURL() string      { return "https://gc.example.com/test.git" }
+func (r *gcRepository) GetDefaultBranch() string { return "main" }
+
+func TestCollectGarbage(t *testing.T) {
+	hostDir := filepath.Join(testGitConfig.CloneLocation, "gc.example.com")
+
+	// clone the repositories, then leave some work in some of them
+	setup := map[uint64]func(path string) error{
+		// still in the forge
+		100: nil,
+		// removed from the forge
+		101: nil,
+		102: func(path string) error {
+			return os.WriteFile(filepath.Join(path, "untracked"), []byte("work"), 0644)
+		},
+		103: func(path string) error {
+			_, err := utils.ExecProcessInDir(slog.Default(), path, "git", "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--allow-empty", "-m", "work")
+			return err
+		},
+	}
+	for id, prepare := range setup {
+		path, err := gitClient.FetchLocalRepositoryPath(&gcRepository{id: id})
+		if err != nil {
+			t.Fatalf("FetchLocalRepositoryPath() returned error: %v", err)
+		}
+		if prepare != nil {
+			if err := prepare(path); err != nil {
+				t.Fatalf("failed to prepare %v: %v", path, err)
+			}
+		}
+	}
+	live := []fstree.RepositorySource{&gcRepository{id: 100}}
+
+	// the steps are applied in order on the same local clones
+	tests := []struct {
+		action   string
+		expected []fstree.OrphanedClone
+	}{
+		{
+			action: config.GCActionReport,
+			expected: []fstree.OrphanedClone{
+				{Path: filepath.Join(hostDir, "101"), State: fstree.OrphanOrphaned},
+				{Path: filepath.Join(hostDir, "102"), State: fstree.OrphanKept, Reason: "uncommitted changes"},
+				{Path: filepath.Join(hostDir, "103"), State: fstree.OrphanKept, Reason: "unpushed commits"},
+			},
+		},
+		{
+			action: config.GCActionDelete,
+			expected: []fstree.OrphanedClone{
+				{Path: filepath.Join(hostDir, "101"), State: fstree.OrphanDeleted},
+				{Path: filepath.Join(hostDir, "102"), State: fstree.OrphanKept, Reason: "uncommitted changes"},
+				{Path: filepath.Join(hostDir, "103"), State: fstree.OrphanKept, Reason: "unpushed commits"},
+			},
+		},
+		{
+			action: config.GCActionDelete,
+			expected: []fstree.OrphanedClone{
+				{Path: filepath.Join(hostDir, "102"), State: fstree.OrphanKept, Reason: "uncommitted changes"},
+				{Path: filepath.Join(hostDir, "103"), State: fstree.OrphanKept, Reason: "unpushed commits"},
+			},
+		},
+	}
+
+	for i, test := range tests {
+		got, err := gitClient.CollectGarbage(live, test.action)
+		if err != nil {
+			t.Fatalf("step %v: CollectGarbage() returned error: %v", i, err)
+		}
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Fatalf("step %v: CollectGarbage(%v) returned %v; expected %v", i, test.action, got, test.expected)
+		}
+	}
+
+	for id, expected := range map[uint64]bool{100: true, 101: false, 102: true, 103: true} {
+		_, err := os.Stat(filepath.Join(hostDir, fmt.Sprint(id)))
+		if (err == nil) != expected {
+			t.Fatalf("local clone %v exists: %v; expected %v", id, err == nil, expected)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/badjware/gitforgefs/config"
+)
+
+const (
+	// indexDirName is the directory of each host holding a symlink to the local clone of each repository, by id
+	indexDirName = ".ids"
+)
+
+// resolveLocalRepositoryPath returns where the local clone of the repository with id rid lives in hostDir, moving
+// the local clone if the repository was renamed or transferred since it was cloned
+func (c *gitClient) resolveLocalRepositoryPath(hostDir string, rid uint64, repositoryPath string, layout string) string {
+	c.layoutMux.Lock()
+	defer c.layoutMux.Unlock()
+
+	idPath := filepath.Join(hostDir, strconv.FormatUint(rid, 10))
+	indexPath := filepath.Join(hostDir, indexDirName, strconv.FormatUint(rid, 10))
+
+	wanted := idPath
+	if layout == config.LayoutPath {
+		if relativePath, ok := cleanRepositoryPath(repositoryPath); ok {
+			wanted = filepath.Join(hostDir, relativePath)
+		}
+	}
+
+	// find where the repository was cloned, if it was
+	current := ""
+	if target, err := os.Readlink(indexPath); err == nil {
+		current = filepath.Join(filepath.Dir(indexPath), target)
+		if _, err := os.Stat(current); err != nil {
+			current = ""
+		}
+	}
+	if current == "" {
+		if _, err := os.Stat(idPath); err == nil {
+			current = idPath
+		}
+	}
+
+	if current != "" && current != wanted {
+		if err := c.moveLocalRepository(hostDir, current, wanted); err != nil {
+			c.logger.Warn("Failed to move the local clone, keeping it in place", "from", current, "to", wanted, "error", err.Error())
+			wanted = current
+		}
+	}
+
+	if wanted == idPath {
+		// the id is the index
+		if err := os.Remove(indexPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			c.logger.Warn("Failed to remove the index of the local clone", "path", indexPath, "error", err.Error())
+		}
+	} else if err := writeIndex(indexPath, wanted); err != nil {
+		c.logger.Warn("Failed to index the local clone", "path", indexPath, "error", err.Error())
+	}
+	return wanted
+}
+
+// moveLocalRepository moves the local clone at src to dst, along with its state
+func (c *gitClient) moveLocalRepository(hostDir string, src string, dst string) error {
+	c.statusMux.Lock()
+	inProgress := c.inProgress(src)
+	c.statusMux.Unlock()
+	if inProgress {
+		return fmt.Errorf("a git operation is in progress")
+	}
+	if _, err := os.Lstat(dst); err == nil {
+		return fmt.Errorf("%v already exists", dst)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err != nil {
+		return err
+	}
+	c.logger.Info("Moved local clone", "from", src, "to", dst)
+
+	c.statusMux.Lock()
+	if status, found := c.statuses[src]; found {
+		delete(c.statuses, src)
+		status.Path = dst
+		c.statuses[dst] = status
+	}
+	c.statusMux.Unlock()
+	c.moveAccess(src, dst)
+
+	// remove the directories of the groups left empty
+	for dir := filepath.Dir(src); strings.HasPrefix(dir, hostDir+string(filepath.Separator)); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// writeIndex makes the symlink at indexPath point to the local clone at target
+func writeIndex(indexPath string, target string) error {
+	relativeTarget, err := filepath.Rel(filepath.Dir(indexPath), target)
+	if err != nil {
+		return err
+	}
+	if current, err := os.Readlink(indexPath); err == nil && current == relativeTarget {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(indexPath), 0755); err != nil {
+		return err
+	}
+	tmpPath := indexPath + ".tmp"
+	os.Remove(tmpPath)
+	if err := os.Symlink(relativeTarget, tmpPath); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, indexPath)
+}
+
+// cleanRepositoryPath returns the path of the repository in its forge as a relative path, if it is safe to use as one
+func cleanRepositoryPath(repositoryPath string) (string, bool) {
+	if repositoryPath == "" {
+		return "", false
+	}
+	for _, segment := range strings.Split(repositoryPath, "/") {
+		if segment == "" || segment == "." || segment == ".." || segment == indexDirName {
+			return "", false
+		}
+	}
+	return filepath.FromSlash(repositoryPath), true
+}
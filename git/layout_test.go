@@ -0,0 +1,60 @@
+package git_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/badjware/gitforgefs/config"
+)
+
+type testPathRepository struct {
+	testRepository
+	path string
+}
+
+func (r *testPathRepository) GetRepositoryPath() string { return r.path }
+
+func TestLayout(t *testing.T) {
+	t.Cleanup(func() { gitClient.UpdateConfig(testGitConfig) })
+	hostDir := filepath.Join(testGitConfig.CloneLocation, "example.com")
+
+	// the steps are applied in order on the same repository
+	tests := []struct {
+		name     string
+		layout   string
+		path     string
+		expected string
+		removed  string
+	}{
+		{name: "ID", layout: config.LayoutID, path: "group/repo", expected: "10"},
+		{name: "Path", layout: config.LayoutPath, path: "group/repo", expected: "group/repo", removed: "10"},
+		{name: "Rename", layout: config.LayoutPath, path: "other-group/sub-group/renamed-repo", expected: "other-group/sub-group/renamed-repo", removed: "group"},
+		{name: "UnsafePath", layout: config.LayoutPath, path: "../escape", expected: "10", removed: "other-group"},
+		{name: "BackToPath", layout: config.LayoutPath, path: "group/repo", expected: "group/repo", removed: "10"},
+	}
+
+	for _, test := range tests {
+		gitConfig := testGitConfig
+		gitConfig.Layout = test.layout
+		gitClient.UpdateConfig(gitConfig)
+
+		repository := &testPathRepository{testRepository: testRepository{id: 10, defaultBranch: "main"}, path: test.path}
+		got, err := gitClient.FetchLocalRepositoryPath(repository)
+		if err != nil {
+			t.Fatalf("%v: FetchLocalRepositoryPath() returned error: %v", test.name, err)
+		}
+		expected := filepath.Join(hostDir, test.expected)
+		if got != expected {
+			t.Fatalf("%v: FetchLocalRepositoryPath() returned %v; expected %v", test.name, got, expected)
+		}
+		if _, err := os.Stat(filepath.Join(got, ".git")); err != nil {
+			t.Fatalf("%v: local clone %v is missing: %v", test.name, got, err)
+		}
+		if test.removed != "" {
+			if _, err := os.Stat(filepath.Join(hostDir, test.removed)); !os.IsNotExist(err) {
+				t.Fatalf("%v: %v is still present after the local clone was moved", test.name, test.removed)
+			}
+		}
+	}
+}
@@ -3,11 +3,36 @@ package git
 import (
 	"fmt"
 	"strconv"
+	"time"
 
+	"github.com/badjware/gitforgefs/fstree"
+	"github.com/badjware/gitforgefs/metrics"
 	"github.com/badjware/gitforgefs/utils"
 )
 
-func (c *gitClient) pull(repoPath string, defaultBranch string) error {
+func (c *gitClient) pull(url string, repoPath string, defaultBranch string, repositoryPath string) (err error) {
+	gitConfig, gitConfigKeys := c.repositoryConfig(repositoryPath)
+	metrics.GitOperationStarted()
+	if !c.startPull(repoPath, url) {
+		c.logger.Info("Skipping the pull of the local clone being evicted or collected", "directory", repoPath)
+		return nil
+	}
+	start := time.Now()
+	defer func() {
+		metrics.ObserveGitOperation("pull", start, err)
+		if err != nil {
+			c.setStatus(repoPath, url, fstree.StatusFailed, err)
+		} else {
+			c.setStatus(repoPath, url, fstree.StatusCloned, nil)
+		}
+		c.requestEviction(repoPath)
+	}()
+
+	// Apply the changes to git.rules since the clone
+	if err := c.setGitConfig(repoPath, gitConfigKeys); err != nil {
+		return err
+	}
+
 	// Check if the local repo is on default branch
 	branchName, err := utils.ExecProcessInDir(
 		c.logger,
@@ -24,13 +49,13 @@ func (c *gitClient) pull(repoPath string, defaultBranch string) error {
 		args := []string{
 			"pull",
 		}
-		if c.GitClientConfig.Depth != 0 {
-			args = append(args, "--depth", strconv.Itoa(c.GitClientConfig.Depth))
+		if gitConfig.Depth != 0 {
+			args = append(args, "--depth", strconv.Itoa(gitConfig.Depth))
 		}
 		args = append(args,
 			"--",
-			c.GitClientConfig.Remote, // repository
-			defaultBranch,            // refspec
+			gitConfig.Remote, // repository
+			defaultBranch,    // refspec
 		)
 
 		_, err = utils.ExecProcessInDir(c.logger, repoPath, "git", args...)
@@ -0,0 +1,97 @@
+package git
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/badjware/gitforgefs/config"
+	"github.com/badjware/gitforgefs/fstree"
+	"github.com/badjware/gitforgefs/utils"
+)
+
+// repositoryPathOf returns the path of the repository in its forge, or an empty string if the forge doesn't expose it
+func repositoryPathOf(source fstree.RepositorySource) string {
+	if pathSource, ok := source.(fstree.RepositoryPathSource); ok {
+		return pathSource.GetRepositoryPath()
+	}
+	return ""
+}
+
+// compileRules compiles the path patterns of rules, by index. The patterns that fail to compile are left nil, they
+// are already rejected when loading the configuration.
+func compileRules(rules []config.GitRule) []*regexp.Regexp {
+	patterns := make([]*regexp.Regexp, len(rules))
+	for i, rule := range rules {
+		if pattern, err := utils.CompilePattern(rule.Path); err == nil {
+			patterns[i] = pattern
+		}
+	}
+	return patterns
+}
+
+// repositoryConfig returns the configuration of the git client for the repository at repositoryPath in its forge, with
+// the git.rules matching it applied in order, along with the extra keys to set with `git config` in its local clone
+func (c *gitClient) repositoryConfig(repositoryPath string) (config.GitClientConfig, map[string]string) {
+	c.configMux.RLock()
+	gitConfig := c.GitClientConfig
+	rulePatterns := c.rulePatterns
+	c.configMux.RUnlock()
+
+	gitConfigKeys := map[string]string{}
+	if repositoryPath == "" {
+		return gitConfig, gitConfigKeys
+	}
+	for i, rule := range gitConfig.Rules {
+		if rulePatterns[i] == nil || !rulePatterns[i].MatchString(repositoryPath) {
+			continue
+		}
+		if rule.Remote != "" {
+			gitConfig.Remote = rule.Remote
+		}
+		if rule.OnClone != "" {
+			gitConfig.OnClone = rule.OnClone
+		}
+		if len(rule.SparseCheckout) != 0 {
+			gitConfig.SparseCheckout = rule.SparseCheckout
+		}
+		if rule.AutoPull != nil {
+			gitConfig.AutoPull = *rule.AutoPull
+		}
+		if rule.Depth != nil {
+			gitConfig.Depth = *rule.Depth
+		}
+		for key, value := range rule.Config {
+			gitConfigKeys[key] = value
+		}
+	}
+	return gitConfig, gitConfigKeys
+}
+
+// sortedKeys returns the keys of gitConfigKeys in a stable order
+func sortedKeys(gitConfigKeys map[string]string) []string {
+	keys := make([]string, 0, len(gitConfigKeys))
+	for key := range gitConfigKeys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// setGitConfig sets the extra keys of git.rules in the local clone at repoPath
+func (c *gitClient) setGitConfig(repoPath string, gitConfigKeys map[string]string) error {
+	for _, key := range sortedKeys(gitConfigKeys) {
+		_, err := utils.ExecProcessInDir(
+			c.logger,
+			repoPath, // workdir
+			"git", "config", "--local",
+			"--",
+			key,                // key
+			gitConfigKeys[key], // value
+		)
+		if err != nil {
+			return fmt.Errorf("failed to set %v in git repo %v: %v", key, repoPath, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package git
+
+import (
+	"sort"
+	"time"
+
+	"github.com/badjware/gitforgefs/fstree"
+)
+
+// setStatus records the state of the local clone at path
+func (c *gitClient) setStatus(path string, url string, state string, err error) {
+	status := fstree.CloneStatus{
+		Path:      path,
+		CloneURL:  url,
+		State:     state,
+		UpdatedAt: time.Now(),
+	}
+	if err != nil {
+		status.Error = err.Error()
+	}
+
+	c.statusMux.Lock()
+	defer c.statusMux.Unlock()
+	c.statuses[path] = status
+}
+
+// inProgress returns whether a git operation is queued or running in the local clone at path, or whether it is being
+// evicted or collected. statusMux must be held.
+func (c *gitClient) inProgress(path string) bool {
+	if c.busy[path] {
+		return true
+	}
+	if _, found := c.cloneDone[path]; found {
+		return true
+	}
+	status, found := c.statuses[path]
+	return found && (status.State == fstree.StatusQueued || status.State == fstree.StatusCloning || status.State == fstree.StatusPulling)
+}
+
+// markBusy marks the local clone at path as being evicted or collected, so that no git operation is started in it
+// meanwhile. It returns false if a git operation is already in progress in it.
+func (c *gitClient) markBusy(path string) bool {
+	c.statusMux.Lock()
+	defer c.statusMux.Unlock()
+	if c.inProgress(path) {
+		return false
+	}
+	c.busy[path] = true
+	return true
+}
+
+// unmarkBusy marks the local clone at path as no longer being evicted or collected
+func (c *gitClient) unmarkBusy(path string) {
+	c.statusMux.Lock()
+	defer c.statusMux.Unlock()
+	delete(c.busy, path)
+}
+
+// isBusy returns whether the local clone at path is being evicted or collected
+func (c *gitClient) isBusy(path string) bool {
+	c.statusMux.Lock()
+	defer c.statusMux.Unlock()
+	return c.busy[path]
+}
+
+// startPull marks the local clone at path as pulling, unless it is being evicted or collected
+func (c *gitClient) startPull(path string, url string) bool {
+	c.statusMux.Lock()
+	defer c.statusMux.Unlock()
+	if c.busy[path] {
+		return false
+	}
+	c.statuses[path] = fstree.CloneStatus{
+		Path:      path,
+		CloneURL:  url,
+		State:     fstree.StatusPulling,
+		UpdatedAt: time.Now(),
+	}
+	return true
+}
+
+// startClone marks the local clone at path as queued, unless its clone is already in progress.
+// It returns a channel closed when the clone completes, and whether the clone needs to be dispatched.
+func (c *gitClient) startClone(path string, url string) (<-chan struct{}, bool) {
+	c.statusMux.Lock()
+	defer c.statusMux.Unlock()
+	if done, found := c.cloneDone[path]; found {
+		return done, false
+	}
+	done := make(chan struct{})
+	c.cloneDone[path] = done
+	c.statuses[path] = fstree.CloneStatus{
+		Path:      path,
+		CloneURL:  url,
+		State:     fstree.StatusQueued,
+		UpdatedAt: time.Now(),
+	}
+	return done, true
+}
+
+// finishClone records the outcome of the clone of the local clone at path and wakes up those waiting on it
+func (c *gitClient) finishClone(path string, url string, err error) {
+	if err != nil {
+		c.setStatus(path, url, fstree.StatusFailed, err)
+	} else {
+		c.setStatus(path, url, fstree.StatusCloned, nil)
+	}
+
+	c.statusMux.Lock()
+	defer c.statusMux.Unlock()
+	if done, found := c.cloneDone[path]; found {
+		close(done)
+		delete(c.cloneDone, path)
+	}
+}
+
+// waitForClone blocks until the clone completes, for at most git.clone_wait_timeout.
+// Once the timeout is reached, the clone continues in the background.
+func (c *gitClient) waitForClone(path string, done <-chan struct{}) {
+	timeout := c.currentConfig().CloneWaitTimeout
+	if timeout <= 0 {
+		return
+	}
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		c.logger.Warn("Timed out waiting for the clone to complete, continuing in the background", "directory", path)
+	}
+}
+
+// getStatus returns the state of the local clone at path, if it is known
+func (c *gitClient) getStatus(path string) (fstree.CloneStatus, bool) {
+	c.statusMux.Lock()
+	defer c.statusMux.Unlock()
+	status, found := c.statuses[path]
+	return status, found
+}
+
+// FetchCloneStatuses returns the state of every local clones accessed since startup, sorted by path
+func (c *gitClient) FetchCloneStatuses() []fstree.CloneStatus {
+	c.statusMux.Lock()
+	statuses := make([]fstree.CloneStatus, 0, len(c.statuses))
+	for _, status := range c.statuses {
+		statuses = append(statuses, status)
+	}
+	c.statusMux.Unlock()
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Path < statuses[j].Path
+	})
+	return statuses
+}
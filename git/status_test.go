@@ -0,0 +1,94 @@
+package git_test
+
+import (
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/badjware/gitforgefs/config"
+	"github.com/badjware/gitforgefs/fstree"
+	"github.com/badjware/gitforgefs/git"
+)
+
+type testRepository struct {
+	id            uint64
+	defaultBranch string
+}
+
+func (r *testRepository) GetRepositoryID() uint64  { return r.id }
+func (r *testRepository) GetCloneURL() string      { return "https://example.com/test.git" }
+func (r *testRepository) GetDefaultBranch() string { return r.defaultBranch }
+
+// testGitConfig is the configuration of gitClient, shared by the tests since the git tasks can only be registered once
+var testGitConfig = config.GitClientConfig{
+	Remote:           "origin",
+	OnClone:          "init",
+	Layout:           config.LayoutID,
+	QueueSize:        10,
+	QueueWorkerCount: 1,
+	// FetchLocalRepositoryPath only returns once the clone completes
+	CloneWaitTimeout: time.Minute,
+}
+
+var gitClient interface {
+	fstree.GitClient
+	UpdateConfig(config.GitClientConfig)
+}
+
+func TestMain(m *testing.M) {
+	cloneLocation, err := os.MkdirTemp("", "gitforgefs-test-")
+	if err != nil {
+		panic(err)
+	}
+	testGitConfig.CloneLocation = cloneLocation
+	gitClient, err = git.NewClient(slog.Default(), testGitConfig)
+	if err != nil {
+		panic(err)
+	}
+
+	code := m.Run()
+	os.RemoveAll(cloneLocation)
+	os.Exit(code)
+}
+
+func TestCloneStatus(t *testing.T) {
+	tests := map[string]struct {
+		input    *testRepository
+		expected string
+	}{
+		"Cloned": {
+			input:    &testRepository{id: 1, defaultBranch: "main"},
+			expected: fstree.StatusCloned,
+		},
+		"Failed": {
+			// git refuses to create a branch with an invalid name
+			input:    &testRepository{id: 2, defaultBranch: "invalid..branch"},
+			expected: fstree.StatusFailed,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			path, err := gitClient.FetchLocalRepositoryPath(test.input)
+			if err != nil {
+				t.Fatalf("FetchLocalRepositoryPath() returned error: %v", err)
+			}
+
+			var status fstree.CloneStatus
+			for _, s := range gitClient.FetchCloneStatuses() {
+				if s.Path == path {
+					status = s
+				}
+			}
+			if status.State != test.expected || (status.State == fstree.StatusFailed) != (status.Error != "") {
+				t.Fatalf("clone of %v has status %v; expected %v", path, status, test.expected)
+			}
+			if _, err := os.Stat(path); test.expected == fstree.StatusCloned && err != nil {
+				t.Fatalf("clone of %v is missing: %v", path, err)
+			}
+		})
+	}
+}
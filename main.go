@@ -5,24 +5,43 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"path/filepath"
 	"strings"
 
+	"github.com/badjware/gitforgefs/cache"
 	"github.com/badjware/gitforgefs/config"
+	"github.com/badjware/gitforgefs/control"
+	"github.com/badjware/gitforgefs/forges/bitbucket"
 	"github.com/badjware/gitforgefs/forges/gitea"
 	"github.com/badjware/gitforgefs/forges/github"
 	"github.com/badjware/gitforgefs/forges/gitlab"
+	"github.com/badjware/gitforgefs/forges/manifest"
 	"github.com/badjware/gitforgefs/fstree"
 	"github.com/badjware/gitforgefs/git"
+	"github.com/badjware/gitforgefs/metrics"
 )
 
 func main() {
 	configPath := flag.String("config", "config.yaml", "The config file")
 	mountoptionsFlag := flag.String("o", "", "Filesystem mount options. See mount.fuse(8)")
 	debug := flag.Bool("debug", false, "Enable debug logging")
+	offline := flag.Bool("offline", false, "Serve the content saved in the metadata cache without ever calling the api of the forges")
 
 	flag.Usage = func() {
 		fmt.Println("USAGE:")
-		fmt.Printf("    %s MOUNTPOINT\n\n", os.Args[0])
+		fmt.Printf("    %s [OPTIONS] MOUNTPOINT\n", os.Args[0])
+		fmt.Printf("    %s [OPTIONS] COMMAND\n\n", os.Args[0])
+		fmt.Println("COMMANDS:")
+		fmt.Println("    status          Show the mountpoint and the state of the local clones")
+		fmt.Println("    refresh PATH    Refresh the content of the group at PATH")
+		fmt.Println("    pull PATH       Pull the local clone of the repository at PATH")
+		fmt.Println("    clone PATH      Clone the repository at PATH and wait for the clone to complete")
+		fmt.Println("    queue           Show the git operations queued or in progress")
+		fmt.Println("    gc [ACTION]     Report the local clones of the repositories no longer in the forge, and delete or archive")
+		fmt.Println("                    them if ACTION is \"delete\" or \"archive\". Only clean clones are deleted or archived")
+		fmt.Println("    unmount         Unmount the filesystem")
+		fmt.Println("    credential OP   Act as a git credential helper handing out the tokens of the forges, see git.credential_helper")
+		fmt.Println()
 		fmt.Println("OPTIONS:")
 		flag.PrintDefaults()
 	}
@@ -37,6 +56,22 @@ func main() {
 	// Get logger
 	logger := slog.Default()
 
+	// Locate the control socket
+	controlSocket := loadedConfig.FS.ControlSocket
+	if controlSocket == "" {
+		controlSocket = filepath.Join(loadedConfig.Git.CloneLocation, ".control.sock")
+	}
+
+	// Answer git as its credential helper
+	if flag.NArg() >= 1 && flag.Arg(0) == credentialCommand {
+		os.Exit(runCredentialHelper(loadedConfig, flag.Args()[1:]))
+	}
+
+	// Send the command to the running filesystem
+	if flag.NArg() >= 1 && control.IsCommand(flag.Arg(0)) {
+		os.Exit(runCommand(controlSocket, flag.Arg(0), flag.Args()[1:]))
+	}
+
 	// Configure mountpoint
 	mountpoint := loadedConfig.FS.Mountpoint
 	if flag.NArg() == 1 {
@@ -59,38 +94,32 @@ func main() {
 	}
 
 	// Create the git client
-	gitClientParam, err := config.MakeGitConfig(loadedConfig)
+	gitClientParam, err := makeGitClientConfig(loadedConfig, *configPath)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 	gitClient, _ := git.NewClient(logger, *gitClientParam)
 
-	var gitForgeClient fstree.GitForge
-	if loadedConfig.FS.Forge == config.ForgeGitlab {
-		// Create the gitlab client
-		gitlabClientConfig, err := config.MakeGitlabConfig(loadedConfig)
-		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
-		}
-		gitForgeClient, _ = gitlab.NewClient(logger, *gitlabClientConfig)
-	} else if loadedConfig.FS.Forge == config.ForgeGithub {
-		// Create the github client
-		githubClientConfig, err := config.MakeGithubConfig(loadedConfig)
-		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
-		}
-		gitForgeClient, _ = github.NewClient(logger, *githubClientConfig)
-	} else if loadedConfig.FS.Forge == config.ForgeGitea {
-		// Create the gitea client
-		giteaClientConfig, err := config.MakeGiteaConfig(loadedConfig)
-		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
-		}
-		gitForgeClient, _ = gitea.NewClient(logger, *giteaClientConfig)
+	// Create the forge clients
+	forgeReloader := newReloader(logger, *configPath, *offline, gitClient)
+	gitForgeClient, err := forgeReloader.Open(loadedConfig)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fsParam := &fstree.FSParam{GitClient: gitClient, GitForge: gitForgeClient, MetadataFiles: loadedConfig.FS.MetadataFiles, ControlSocket: controlSocket, Offline: *offline}
+
+	// Apply the changes of the config file without remounting
+	stopReloader := forgeReloader.Start(fsParam)
+
+	// Start the metrics endpoint
+	if loadedConfig.Metrics.Listen != "" {
+		go func() {
+			if err := metrics.Serve(logger, loadedConfig.Metrics.Listen); err != nil {
+				logger.Error("Failed to serve metrics", "error", err)
+			}
+		}()
 	}
 
 	// Start the filesystem
@@ -98,11 +127,83 @@ func main() {
 		logger,
 		mountpoint,
 		parsedMountoptions,
-		&fstree.FSParam{GitClient: gitClient, GitForge: gitForgeClient},
+		fsParam,
 		*debug,
 	)
+	stopReloader()
+	forgeReloader.Close()
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 }
+
+func runCommand(controlSocket string, command string, args []string) int {
+	request := control.Request{Command: command}
+	switch command {
+	case control.CommandRefresh, control.CommandPull, control.CommandClone:
+		if len(args) != 1 {
+			fmt.Printf("%s requires a single path\n", command)
+			flag.Usage()
+			return 2
+		}
+		// paths are relative to the current directory, like for any other command
+		path, err := filepath.Abs(args[0])
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		request.Path = path
+	case control.CommandGC:
+		if len(args) > 1 {
+			fmt.Printf("%s takes at most one action\n", command)
+			flag.Usage()
+			return 2
+		}
+		request.Action = config.GCActionReport
+		if len(args) == 1 {
+			request.Action = args[0]
+		}
+		if request.Action != config.GCActionReport && request.Action != config.GCActionDelete && request.Action != config.GCActionArchive {
+			fmt.Printf("%s action must be either \"%v\", \"%v\" or \"%v\"\n", command, config.GCActionReport, config.GCActionDelete, config.GCActionArchive)
+			return 2
+		}
+	default:
+		if len(args) != 0 {
+			fmt.Printf("%s takes no argument\n", command)
+			flag.Usage()
+			return 2
+		}
+	}
+
+	output, err := control.Send(controlSocket, request)
+	fmt.Print(output)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	return 0
+}
+
+func newGitForge(logger *slog.Logger, forgeConfig config.ForgeConfig, store *cache.Store) (fstree.GitForge, error) {
+	forgeMetrics := metrics.NewForgeMetrics(forgeConfig.Name)
+	switch forgeConfig.Type {
+	case config.ForgeGitlab:
+		// Create the gitlab client
+		return gitlab.NewClient(logger.With("forge", forgeConfig.Name), forgeConfig.Gitlab, store, forgeMetrics)
+	case config.ForgeGithub:
+		// Create the github client
+		return github.NewClient(logger.With("forge", forgeConfig.Name), forgeConfig.Github, store, forgeMetrics)
+	case config.ForgeGitea:
+		// Create the gitea client
+		return gitea.NewClient(logger.With("forge", forgeConfig.Name), forgeConfig.Gitea, store, forgeMetrics)
+	case config.ForgeBitbucket:
+		// Create the bitbucket client
+		return bitbucket.NewClient(logger.With("forge", forgeConfig.Name), forgeConfig.Bitbucket, store, forgeMetrics)
+	case config.ForgeManifest:
+		// Create the manifest client
+		// the manifest is a local file, so there is nothing to persist
+		return manifest.NewClient(logger.With("forge", forgeConfig.Name), forgeConfig.Manifest)
+	}
+	return nil, fmt.Errorf("unsupported forge: %v", forgeConfig.Type)
+}
@@ -0,0 +1,172 @@
+package metrics
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	namespace = "gitforgefs"
+
+	resultSuccess = "success"
+	resultError   = "error"
+	resultHit     = "hit"
+	resultMiss    = "miss"
+)
+
+var (
+	registry = prometheus.NewRegistry()
+
+	forgeRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "forge_requests_total",
+		Help:      "Number of requests made to the api of the forges.",
+	}, []string{"forge", "endpoint", "result"})
+	forgeRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "forge_request_duration_seconds",
+		Help:      "Duration of the requests made to the api of the forges.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"forge", "endpoint"})
+	cacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_lookups_total",
+		Help:      "Number of lookups of groups, users and organizations in the cache of the forges.",
+	}, []string{"forge", "kind", "result"})
+
+	gitQueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "git_queue_depth",
+		Help:      "Number of git operations waiting in the queue.",
+	})
+	gitOperationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "git_operation_duration_seconds",
+		Help:      "Duration of the git operations.",
+		Buckets:   []float64{0.1, 0.5, 1, 5, 10, 30, 60, 300, 900},
+	}, []string{"operation"})
+	gitOperationFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "git_operation_failures_total",
+		Help:      "Number of git operations that failed.",
+	}, []string{"operation"})
+
+	degraded = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "degraded",
+		Help:      "Whether the last known content is served because the forge is unreachable.",
+	})
+
+	fuseOperations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "fuse_operations_total",
+		Help:      "Number of operations served by the filesystem.",
+	}, []string{"operation"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		forgeRequests,
+		forgeRequestDuration,
+		cacheLookups,
+		gitQueueDepth,
+		gitOperationDuration,
+		gitOperationFailures,
+		degraded,
+		fuseOperations,
+	)
+}
+
+// ForgeMetrics records the metrics of a forge, labeled with the name of the forge.
+// A nil ForgeMetrics is valid and does nothing.
+type ForgeMetrics struct {
+	name string
+}
+
+func NewForgeMetrics(name string) *ForgeMetrics {
+	return &ForgeMetrics{
+		name: name,
+	}
+}
+
+// ObserveRequest records a request to the endpoint of the api of the forge started at start
+func (m *ForgeMetrics) ObserveRequest(endpoint string, start time.Time, err error) {
+	if m == nil {
+		return
+	}
+	result := resultSuccess
+	if err != nil {
+		result = resultError
+	}
+	forgeRequests.WithLabelValues(m.name, endpoint, result).Inc()
+	forgeRequestDuration.WithLabelValues(m.name, endpoint).Observe(time.Since(start).Seconds())
+}
+
+// ObserveCacheLookup records a lookup of a group of the given kind in the cache of the forge
+func (m *ForgeMetrics) ObserveCacheLookup(kind string, hit bool) {
+	if m == nil {
+		return
+	}
+	result := resultMiss
+	if hit {
+		result = resultHit
+	}
+	cacheLookups.WithLabelValues(m.name, kind, result).Inc()
+}
+
+// GitOperationQueued records that a git operation was added to the queue
+func GitOperationQueued() {
+	gitQueueDepth.Inc()
+}
+
+// GitOperationStarted records that a git operation was taken out of the queue
+func GitOperationStarted() {
+	gitQueueDepth.Dec()
+}
+
+// ObserveGitOperation records a git operation started at start
+func ObserveGitOperation(operation string, start time.Time, err error) {
+	gitOperationDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		gitOperationFailures.WithLabelValues(operation).Inc()
+	}
+}
+
+// SetDegraded records whether the last known content is served because the forge is unreachable
+func SetDegraded(isDegraded bool) {
+	if isDegraded {
+		degraded.Set(1)
+	} else {
+		degraded.Set(0)
+	}
+}
+
+// ObserveFUSEOperation records an operation served by the filesystem
+func ObserveFUSEOperation(operation string) {
+	fuseOperations.WithLabelValues(operation).Inc()
+}
+
+// Serve exposes the metrics on /metrics of an http server listening on address. It returns once the server stops.
+func Serve(logger *slog.Logger, address string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+
+	logger.Info("Serving metrics", "address", address)
+	server := &http.Server{
+		Addr:              address,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/badjware/gitforgefs/cache"
+	"github.com/badjware/gitforgefs/config"
+	"github.com/badjware/gitforgefs/forges/composite"
+	"github.com/badjware/gitforgefs/fstree"
+	"github.com/badjware/gitforgefs/utils"
+)
+
+const (
+	// How often the config file is checked for changes
+	configPollInterval = 5 * time.Second
+)
+
+type reloadableGitClient interface {
+	UpdateConfig(config.GitClientConfig)
+}
+
+type compositeGitForge interface {
+	fstree.GitForge
+	SetForges([]composite.Forge) error
+}
+
+// openForge is a forge client along with the config it was created with
+type openForge struct {
+	config       config.ForgeConfig
+	persistCache bool
+	store        *cache.Store
+	gitForge     fstree.GitForge
+}
+
+// reloader applies the changes of the config file to the running filesystem
+type reloader struct {
+	logger     *slog.Logger
+	configPath string
+	// serve the metadata caches instead of the forges
+	offline bool
+
+	gitClient reloadableGitClient
+	param     *fstree.FSParam
+
+	mux             sync.Mutex
+	config          *config.Config
+	forges          map[string]*openForge
+	compositeClient compositeGitForge
+}
+
+func newReloader(logger *slog.Logger, configPath string, offline bool, gitClient reloadableGitClient) *reloader {
+	return &reloader{
+		logger:     logger,
+		configPath: configPath,
+		offline:    offline,
+
+		gitClient: gitClient,
+
+		forges: map[string]*openForge{},
+	}
+}
+
+// Open creates the clients of the forges configured in newConfig and returns the forge to expose in the filesystem
+func (r *reloader) Open(newConfig *config.Config) (fstree.GitForge, error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	return r.openForges(newConfig)
+}
+
+// openForges creates the clients of the forges configured in newConfig and returns the forge to expose in the filesystem.
+// The clients of the forges whose config is unchanged are reused, so they keep their cache.
+// On error, the forges that were open are left untouched.
+func (r *reloader) openForges(newConfig *config.Config) (fstree.GitForge, error) {
+	forgeConfigs, err := config.MakeForgeConfigs(newConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	newForges := make(map[string]*openForge, len(forgeConfigs))
+	forges := make([]composite.Forge, 0, len(forgeConfigs))
+	for _, forgeConfig := range forgeConfigs {
+		forge, found := r.forges[forgeConfig.Name]
+		if !found || !reflect.DeepEqual(forge.config, forgeConfig) || forge.persistCache != newConfig.FS.PersistCache {
+			if found {
+				r.logger.Info("Configuration of the forge changed, recreating its client", "forge", forgeConfig.Name)
+				// the new client is served from the metadata cache saved by the previous one
+				if err := forge.store.Flush(); err != nil {
+					r.logger.Warn(err.Error())
+				}
+			}
+			forge, err = r.openForge(newConfig, forgeConfig)
+			if err != nil {
+				closeForges(r.logger, newForges, r.forges)
+				return nil, err
+			}
+		}
+		newForges[forgeConfig.Name] = forge
+		forges = append(forges, composite.Forge{Name: forgeConfig.Name, GitForge: forge.gitForge})
+	}
+
+	var gitForge fstree.GitForge
+	if len(newConfig.Forges) == 0 {
+		// The single forge configured with fs.forge is exposed at the root of the filesystem
+		gitForge = forges[0].GitForge
+	} else if r.compositeClient != nil {
+		// Keep the top-level directories of the forges that didn't change
+		if err := r.compositeClient.SetForges(forges); err != nil {
+			closeForges(r.logger, newForges, r.forges)
+			return nil, err
+		}
+		gitForge = r.compositeClient
+	} else {
+		// Each forge is exposed as a top-level directory
+		compositeClient, err := composite.NewClient(r.logger, forges)
+		if err != nil {
+			closeForges(r.logger, newForges, r.forges)
+			return nil, err
+		}
+		r.compositeClient = compositeClient
+		gitForge = compositeClient
+	}
+	if len(newConfig.Forges) == 0 {
+		r.compositeClient = nil
+	}
+
+	// close the forges that were removed or recreated
+	closeForges(r.logger, r.forges, newForges)
+	r.config = newConfig
+	r.forges = newForges
+
+	return gitForge, nil
+}
+
+// openForge creates the client of a forge and its metadata cache
+func (r *reloader) openForge(newConfig *config.Config, forgeConfig config.ForgeConfig) (*openForge, error) {
+	var store *cache.Store
+	if newConfig.FS.PersistCache {
+		store = cache.NewStore(r.logger, filepath.Join(newConfig.Git.CloneLocation, ".cache", forgeConfig.Name+".json"))
+	}
+
+	if r.offline && forgeConfig.Type == config.ForgeManifest {
+		// the manifest forge reads a local file, so it is served as usual without touching the metadata cache
+		store = nil
+	} else if r.offline {
+		gitForge, err := cache.NewOfflineForge(r.logger.With("forge", forgeConfig.Name), store)
+		if err != nil {
+			return nil, fmt.Errorf("failed to serve forge %v offline: %v", forgeConfig.Name, err)
+		}
+		// the metadata cache is only read, so it is left untouched
+		return &openForge{
+			config:       forgeConfig,
+			persistCache: newConfig.FS.PersistCache,
+			gitForge:     gitForge,
+		}, nil
+	}
+
+	gitForge, err := newGitForge(r.logger, forgeConfig, store)
+	if err != nil {
+		return nil, err
+	}
+	store.Start()
+
+	return &openForge{
+		config:       forgeConfig,
+		persistCache: newConfig.FS.PersistCache,
+		store:        store,
+		gitForge:     gitForge,
+	}, nil
+}
+
+// closeForges closes the metadata cache and the client of the forges that are not in keep
+func closeForges(logger *slog.Logger, forges map[string]*openForge, keep map[string]*openForge) {
+	for name, forge := range forges {
+		if keptForge, found := keep[name]; found && keptForge == forge {
+			continue
+		}
+		if err := forge.store.Close(); err != nil {
+			logger.Warn(err.Error())
+		}
+		// such as the manifest forge, which stops watching its manifest
+		if closer, ok := forge.gitForge.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				logger.Warn(err.Error())
+			}
+		}
+	}
+}
+
+// Close closes the metadata cache and the client of every forges
+func (r *reloader) Close() {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	closeForges(r.logger, r.forges, nil)
+	r.forges = map[string]*openForge{}
+}
+
+// Reload reads the config file and applies it to the running filesystem. The current config is kept if the new one is invalid.
+func (r *reloader) Reload() {
+	r.logger.Info("Reloading configuration", "path", r.configPath)
+
+	newConfig, err := config.LoadConfig(r.configPath)
+	if err != nil {
+		r.logger.Error("Failed to reload configuration", "error", err)
+		return
+	}
+	gitClientParam, err := makeGitClientConfig(newConfig, r.configPath)
+	if err != nil {
+		r.logger.Error("Failed to reload configuration", "error", err)
+		return
+	}
+
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	// these settings are only read on startup
+	currentFS := r.config.FS
+	if newConfig.FS.Mountpoint != currentFS.Mountpoint ||
+		newConfig.FS.MountOptions != currentFS.MountOptions ||
+		newConfig.FS.MetadataFiles != currentFS.MetadataFiles ||
+		newConfig.FS.ControlSocket != currentFS.ControlSocket ||
+		newConfig.Metrics != r.config.Metrics {
+		r.logger.Warn("Changes to fs.mountpoint, fs.mountoptions, fs.metadata_files, fs.control_socket and metrics require a restart, ignoring them")
+	}
+	// the git client keeps the location of the clones, and so do the metadata caches
+	gitClientConfig := *gitClientParam
+	newConfig.Git.CloneLocation = r.config.Git.CloneLocation
+
+	gitForge, err := r.openForges(newConfig)
+	if err != nil {
+		r.logger.Error("Failed to reload configuration", "error", err)
+		return
+	}
+	r.gitClient.UpdateConfig(gitClientConfig)
+	if err := r.param.ReloadGitForge(gitForge); err != nil {
+		r.logger.Error("Failed to update the top-level directories", "error", err)
+		return
+	}
+	r.logger.Info("Reloaded configuration")
+}
+
+// Start reloads the configuration when the config file changes or when SIGHUP is received. It returns a function to stop.
+func (r *reloader) Start(param *fstree.FSParam) (stop func()) {
+	r.param = param
+
+	stopWatch := utils.WatchFile(r.configPath, configPollInterval, r.Reload)
+
+	done := make(chan struct{})
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case <-signalChan:
+				r.Reload()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signalChan)
+		close(done)
+		stopWatch()
+	}
+}
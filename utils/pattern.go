@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// regexPatternPrefix marks a pattern as a regular expression rather than a glob
+	regexPatternPrefix = "re:"
+)
+
+// CompilePattern compiles a pattern matching the full path of a group or a repository, such as "my-group/my-repo".
+// A pattern prefixed with "re:" is a regular expression matched against the path. Otherwise, it is a glob matching
+// the whole path, where "*" matches any character except "/", "**" matches any character including "/" and "?"
+// matches a single character except "/". A trailing "/**" also matches the path it is appended to.
+func CompilePattern(pattern string) (*regexp.Regexp, error) {
+	if expr, found := strings.CutPrefix(pattern, regexPatternPrefix); found {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %q: %v", expr, err)
+		}
+		return re, nil
+	}
+	if pattern == "" {
+		return nil, fmt.Errorf("empty pattern")
+	}
+
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "/**") && i+3 == len(pattern):
+			expr.WriteString("(/.*)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**/") && i == 0:
+			expr.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			expr.WriteString(".*")
+			i++
+		case pattern[i] == '*':
+			expr.WriteString("[^/]*")
+		case pattern[i] == '?':
+			expr.WriteString("[^/]")
+		default:
+			expr.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.Compile(expr.String())
+}
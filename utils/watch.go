@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"os"
+	"time"
+)
+
+type fileState struct {
+	exists  bool
+	modTime time.Time
+	size    int64
+}
+
+func statFile(path string) fileState {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileState{}
+	}
+	return fileState{
+		exists:  true,
+		modTime: info.ModTime(),
+		size:    info.Size(),
+	}
+}
+
+// WatchFile calls onChange every time the file at path is created, modified or removed, checking it every interval.
+// It returns a function to stop watching the file.
+func WatchFile(path string, interval time.Duration, onChange func()) (stop func()) {
+	done := make(chan struct{})
+	lastState := statFile(path)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				state := statFile(path)
+				if state != lastState {
+					lastState = state
+					onChange()
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+	}
+}